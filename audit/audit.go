@@ -0,0 +1,220 @@
+package audit
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/SpeedyCoder/gokv"
+	"github.com/SpeedyCoder/gokv/encoding"
+)
+
+// Operations of audit log entries.
+const (
+	OpSet    = "set"
+	OpGet    = "get"
+	OpDelete = "delete"
+)
+
+// Entry is an audit log entry.
+type Entry struct {
+	// Time when the operation started.
+	Time time.Time
+	// Operation, one of OpSet, OpGet and OpDelete.
+	Operation string
+	// Key, as returned by Options.Redact.
+	Key string
+	// Identity of the caller, as returned by Options.Identity.
+	Identity string
+	// Hex encoded SHA-256 digest of the marshalled value,
+	// or its HMAC-SHA-256 if Options.DigestKey is set.
+	// Empty for deletions, failed operations and Get calls that didn't find a value,
+	// and for redacted keys if Options.DigestKey isn't set.
+	Digest string
+	// Whether a value was found. Only set for OpGet.
+	Found bool
+	// Error of the operation, nil if it succeeded.
+	Err error
+}
+
+// Logger writes audit log entries.
+// Implementations must be safe for concurrent use.
+type Logger interface {
+	Log(e Entry)
+}
+
+type identityKey struct{}
+
+// WithIdentity returns a context that contains the given identity of the caller,
+// for example a user or service name.
+func WithIdentity(ctx context.Context, identity string) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// IdentityFromContext returns the identity that was added to the context with WithIdentity.
+// It returns "" if the context doesn't contain an identity.
+func IdentityFromContext(ctx context.Context) string {
+	identity, _ := ctx.Value(identityKey{}).(string)
+	return identity
+}
+
+// RedactPrefixes returns a function for Options.Redact that replaces the part
+// after the prefix of keys with one of the given prefixes with "[REDACTED]".
+func RedactPrefixes(prefixes ...string) func(k string) string {
+	return func(k string) string {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(k, prefix) {
+				return prefix + "[REDACTED]"
+			}
+		}
+		return k
+	}
+}
+
+// Options are the options for the audit store.
+type Options struct {
+	// Identity returns the identity of the caller from the context of an operation.
+	// Optional (IdentityFromContext by default).
+	Identity func(ctx context.Context) string
+	// Redact returns the key as it should appear in the log,
+	// for keys that contain sensitive information, e.g. email addresses.
+	// Optional (keys are logged as they are by default).
+	Redact func(k string) string
+	// LogGets enables logging of Get calls in addition to mutations.
+	// Optional (false by default).
+	LogGets bool
+	// Encoding for marshalling values to compute their digest.
+	// For digests that match the stored data it should be the codec of the store.
+	// Optional (encoding.JSON by default).
+	Encoding encoding.Encoding
+	// DigestKey is the secret key for computing the digests as HMAC-SHA-256 instead of SHA-256.
+	// A plain digest of a value with little entropy, e.g. a phone number, can be reversed by hashing all candidates,
+	// so without a key no digest is logged for redacted keys.
+	// Optional (nil by default).
+	DigestKey []byte
+}
+
+// DefaultOptions is an Options object with default values.
+// Identity: IdentityFromContext, Redact: nil, LogGets: false, Encoding: encoding.JSON, DigestKey: nil
+var DefaultOptions = Options{
+	Identity: IdentityFromContext,
+	Encoding: encoding.JSON,
+	// No need to set Redact, LogGets or DigestKey because their Go zero values are fine for that.
+}
+
+// NewStore creates a new gokv.ContextStore that writes an entry to the logger for every Set and Delete
+// (and Get if enabled) of the given store.
+// The entry is written after the operation, including its outcome.
+// The returned store only implements gokv.ContextStore,
+// so other interfaces that the wrapped store implements are hidden and can't bypass the audit log.
+func NewStore(store gokv.ContextStore, logger Logger, options *Options) gokv.ContextStore {
+	result := auditStore{
+		store:  store,
+		logger: logger,
+	}
+	if options != nil {
+		result.options = *options
+	}
+
+	// Set default values
+	if result.options.Identity == nil {
+		result.options.Identity = DefaultOptions.Identity
+	}
+	if result.options.Redact == nil {
+		result.options.Redact = func(k string) string { return k }
+	}
+	if result.options.Encoding == nil {
+		result.options.Encoding = DefaultOptions.Encoding
+	}
+
+	return result
+}
+
+type auditStore struct {
+	store   gokv.ContextStore
+	logger  Logger
+	options Options
+}
+
+func (s auditStore) newEntry(ctx context.Context, op, k string) Entry {
+	return Entry{
+		Time:      time.Now(),
+		Operation: op,
+		Key:       s.options.Redact(k),
+		Identity:  s.options.Identity(ctx),
+	}
+}
+
+// digest returns the hex encoded SHA-256 digest of the marshalled value,
+// or its HMAC-SHA-256 if Options.DigestKey is set.
+// It returns "" if the value can't be marshalled, or if the key is redacted and Options.DigestKey isn't set.
+func (s auditStore) digest(k string, v interface{}) string {
+	if s.options.DigestKey == nil && s.options.Redact(k) != k {
+		return ""
+	}
+	data, err := s.options.Encoding.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	if s.options.DigestKey == nil {
+		sum := sha256.Sum256(data)
+		return hex.EncodeToString(sum[:])
+	}
+	mac := hmac.New(sha256.New, s.options.DigestKey)
+	_, _ = mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Set stores the given value for the given key and writes an audit log entry.
+// The key must not be "" and the value must not be nil.
+func (s auditStore) Set(ctx context.Context, k string, v interface{}) error {
+	entry := s.newEntry(ctx, OpSet, k)
+	entry.Err = s.store.Set(ctx, k, v)
+	if entry.Err == nil {
+		entry.Digest = s.digest(k, v)
+	}
+	s.logger.Log(entry)
+	return entry.Err
+}
+
+// Get retrieves the stored value for the given key.
+// An audit log entry is only written if Options.LogGets is enabled.
+// If no value is found it returns (false, nil).
+// The key must not be "" and the pointer must not be nil.
+func (s auditStore) Get(ctx context.Context, k string, v interface{}) (found bool, err error) {
+	if !s.options.LogGets {
+		return s.store.Get(ctx, k, v)
+	}
+
+	entry := s.newEntry(ctx, OpGet, k)
+	entry.Found, entry.Err = s.store.Get(ctx, k, v)
+	if entry.Found && entry.Err == nil {
+		entry.Digest = s.digest(k, v)
+	}
+	s.logger.Log(entry)
+	return entry.Found, entry.Err
+}
+
+// Delete deletes the stored value for the given key and writes an audit log entry.
+// Deleting a non-existing key-value pair does NOT lead to an error.
+// The key must not be "".
+func (s auditStore) Delete(ctx context.Context, k string) error {
+	entry := s.newEntry(ctx, OpDelete, k)
+	entry.Err = s.store.Delete(ctx, k)
+	s.logger.Log(entry)
+	return entry.Err
+}
+
+// Keys returns an iterator over all keys in the store.
+// Iterating over the keys isn't logged.
+func (s auditStore) Keys(ctx context.Context) gokv.KeysIterator {
+	return s.store.Keys(ctx)
+}
+
+// Close closes the wrapped store.
+func (s auditStore) Close() error {
+	return s.store.Close()
+}
@@ -0,0 +1,190 @@
+package audit_test
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/SpeedyCoder/gokv/audit"
+	"github.com/SpeedyCoder/gokv/internal/ctxconv"
+	"github.com/SpeedyCoder/gokv/internal/test"
+)
+
+// TestStore tests if reading from, writing to and deleting from the store works properly.
+func TestStore(t *testing.T) {
+	store, path := test.NewBboltStore(t, nil)
+	defer test.CleanUp(store, path)
+
+	logger := &memoryLogger{}
+	test.Store(ctxconv.ToStore(audit.NewStore(store, logger, &audit.Options{LogGets: true})), t)
+}
+
+// TestEntries tests if the expected entries are logged.
+func TestEntries(t *testing.T) {
+	assert := require.New(t)
+	inner, path := test.NewBboltStore(t, nil)
+	defer test.CleanUp(inner, path)
+	logger := &memoryLogger{}
+	store := audit.NewStore(inner, logger, &audit.Options{
+		Redact: audit.RedactPrefixes("secret/"),
+	})
+
+	ctx := audit.WithIdentity(context.Background(), "alice")
+	assert.NoError(store.Set(ctx, "foo", test.Foo{Bar: "baz"}))
+	assert.NoError(store.Set(ctx, "secret/foo", test.Foo{Bar: "baz"}))
+	// Gets aren't logged by default
+	_, err := store.Get(ctx, "foo", new(test.Foo))
+	assert.NoError(err)
+	assert.NoError(store.Delete(context.Background(), "foo"))
+	assert.Error(store.Delete(ctx, ""))
+
+	entries := logger.Entries()
+	assert.Len(entries, 4)
+
+	assert.Equal(audit.OpSet, entries[0].Operation)
+	assert.Equal("foo", entries[0].Key)
+	assert.Equal("alice", entries[0].Identity)
+	assert.Len(entries[0].Digest, 64)
+	assert.False(entries[0].Time.IsZero())
+	assert.NoError(entries[0].Err)
+
+	assert.Equal("secret/[REDACTED]", entries[1].Key)
+	// No plain digest for redacted keys
+	assert.Empty(entries[1].Digest)
+
+	assert.Equal(audit.OpDelete, entries[2].Operation)
+	assert.Equal("", entries[2].Identity)
+	assert.Empty(entries[2].Digest)
+
+	assert.Equal(audit.OpDelete, entries[3].Operation)
+	assert.Error(entries[3].Err)
+}
+
+// TestDigestKey tests if the digests are computed with the key, including the ones of redacted keys.
+func TestDigestKey(t *testing.T) {
+	assert := require.New(t)
+	inner, path := test.NewBboltStore(t, nil)
+	defer test.CleanUp(inner, path)
+	logger := &memoryLogger{}
+	store := audit.NewStore(inner, logger, &audit.Options{
+		Redact:    audit.RedactPrefixes("secret/"),
+		DigestKey: []byte("key"),
+	})
+	plainLogger := &memoryLogger{}
+	plainStore := audit.NewStore(inner, plainLogger, nil)
+
+	ctx := context.Background()
+	assert.NoError(store.Set(ctx, "foo", test.Foo{Bar: "baz"}))
+	assert.NoError(store.Set(ctx, "secret/foo", test.Foo{Bar: "baz"}))
+	assert.NoError(plainStore.Set(ctx, "foo", test.Foo{Bar: "baz"}))
+
+	entries := logger.Entries()
+	assert.Len(entries, 2)
+	assert.Len(entries[0].Digest, 64)
+	assert.Equal(entries[0].Digest, entries[1].Digest)
+	assert.NotEqual(plainLogger.Entries()[0].Digest, entries[0].Digest)
+}
+
+// TestGets tests if Gets are logged if enabled.
+func TestGets(t *testing.T) {
+	assert := require.New(t)
+	inner, path := test.NewBboltStore(t, nil)
+	defer test.CleanUp(inner, path)
+	logger := &memoryLogger{}
+	store := audit.NewStore(inner, logger, &audit.Options{
+		LogGets: true,
+		Identity: func(ctx context.Context) string {
+			return "static"
+		},
+	})
+
+	ctx := context.Background()
+	assert.NoError(store.Set(ctx, "foo", test.Foo{Bar: "baz"}))
+	found, err := store.Get(ctx, "foo", new(test.Foo))
+	assert.NoError(err)
+	assert.True(found)
+	found, err = store.Get(ctx, "bar", new(test.Foo))
+	assert.NoError(err)
+	assert.False(found)
+
+	entries := logger.Entries()
+	assert.Len(entries, 3)
+	assert.Equal("static", entries[0].Identity)
+	assert.Equal(audit.OpGet, entries[1].Operation)
+	assert.True(entries[1].Found)
+	assert.Equal(entries[0].Digest, entries[1].Digest)
+	assert.Equal(audit.OpGet, entries[2].Operation)
+	assert.False(entries[2].Found)
+	assert.Empty(entries[2].Digest)
+}
+
+// TestStdLogger tests the format of the standard library logger.
+func TestStdLogger(t *testing.T) {
+	assert := require.New(t)
+	inner, path := test.NewBboltStore(t, nil)
+	defer test.CleanUp(inner, path)
+	buf := &bytes.Buffer{}
+	store := audit.NewStore(inner, audit.NewStdLogger(log.New(buf, "", 0)), &audit.Options{LogGets: true})
+
+	ctx := audit.WithIdentity(context.Background(), "alice")
+	assert.NoError(store.Set(ctx, "foo", "bar"))
+	_, err := store.Get(ctx, "baz", new(string))
+	assert.NoError(err)
+	assert.Error(store.Delete(ctx, ""))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(lines, 3)
+	assert.Contains(lines[0], `op=set key="foo" identity="alice" digest=`)
+	assert.True(strings.HasSuffix(lines[0], " outcome=success"))
+	assert.Contains(lines[1], `op=get key="baz" identity="alice" found=false outcome=success`)
+	assert.Contains(lines[2], `op=delete key="" identity="alice" outcome="error: `)
+}
+
+// TestZapLogger tests if the zap logger writes the entries as structured fields.
+func TestZapLogger(t *testing.T) {
+	assert := require.New(t)
+	inner, path := test.NewBboltStore(t, nil)
+	defer test.CleanUp(inner, path)
+	core, logs := observer.New(zapcore.InfoLevel)
+	store := audit.NewStore(inner, audit.NewZapLogger(zap.New(core)), nil)
+
+	ctx := audit.WithIdentity(context.Background(), "alice")
+	assert.NoError(store.Set(ctx, "foo", "bar"))
+	assert.Error(store.Delete(ctx, ""))
+
+	entries := logs.AllUntimed()
+	assert.Len(entries, 2)
+	assert.Equal(zapcore.InfoLevel, entries[0].Level)
+	fields := entries[0].ContextMap()
+	assert.Equal("set", fields["op"])
+	assert.Equal("foo", fields["key"])
+	assert.Equal("alice", fields["identity"])
+	assert.NotEmpty(fields["digest"])
+	assert.Equal(zapcore.WarnLevel, entries[1].Level)
+	assert.Contains(entries[1].ContextMap(), "error")
+}
+
+type memoryLogger struct {
+	lock    sync.Mutex
+	entries []audit.Entry
+}
+
+func (l *memoryLogger) Log(e audit.Entry) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.entries = append(l.entries, e)
+}
+
+func (l *memoryLogger) Entries() []audit.Entry {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return append([]audit.Entry(nil), l.entries...)
+}
@@ -0,0 +1,15 @@
+/*
+Package audit contains a wrapper that writes an audit log entry for every mutation of a `gokv.ContextStore`.
+
+Each entry contains the operation, the key, the identity of the caller, the time,
+a digest of the value and the outcome of the operation.
+Reads can be logged as well.
+The caller identity is taken from the context, see `WithIdentity()`.
+Keys of sensitive values can be redacted, see `Options.Redact`.
+The digest is a plain SHA-256, which can be reversed for values with little entropy by hashing all candidates,
+so it's omitted for redacted keys unless a secret key for an HMAC is set, see `Options.DigestKey`.
+
+Entries are written to a Logger, with implementations for the standard library's `log` package
+(`NewStdLogger()`) and for [zap](https://github.com/uber-go/zap) (`NewZapLogger()`).
+*/
+package audit
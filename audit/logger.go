@@ -0,0 +1,73 @@
+package audit
+
+import (
+	"log"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// NewStdLogger creates a Logger that writes entries as single lines to the given standard library logger.
+// The format is:
+//
+//	gokv audit: time=<RFC 3339> op=<op> key=<quoted key> identity=<quoted identity> digest=<digest> found=<bool> outcome=<success or error message>
+//
+// found is only written for OpGet, digest only if it's not empty.
+func NewStdLogger(l *log.Logger) Logger {
+	return stdLogger{l: l}
+}
+
+type stdLogger struct {
+	l *log.Logger
+}
+
+func (l stdLogger) Log(e Entry) {
+	format := "gokv audit: time=%s op=%s key=%q identity=%q"
+	args := []interface{}{e.Time.UTC().Format(time.RFC3339Nano), e.Operation, e.Key, e.Identity}
+	if e.Digest != "" {
+		format += " digest=%s"
+		args = append(args, e.Digest)
+	}
+	if e.Operation == OpGet {
+		format += " found=%t"
+		args = append(args, e.Found)
+	}
+	if e.Err != nil {
+		format += " outcome=%q"
+		args = append(args, "error: "+e.Err.Error())
+	} else {
+		format += " outcome=success"
+	}
+	l.l.Printf(format, args...)
+}
+
+// NewZapLogger creates a Logger that writes entries to the given zap logger,
+// with the entry's fields as structured fields.
+// Successful operations are logged with level info, failed ones with level warn.
+func NewZapLogger(l *zap.Logger) Logger {
+	return zapLogger{l: l}
+}
+
+type zapLogger struct {
+	l *zap.Logger
+}
+
+func (l zapLogger) Log(e Entry) {
+	fields := []zap.Field{
+		zap.Time("time", e.Time),
+		zap.String("op", e.Operation),
+		zap.String("key", e.Key),
+		zap.String("identity", e.Identity),
+	}
+	if e.Digest != "" {
+		fields = append(fields, zap.String("digest", e.Digest))
+	}
+	if e.Operation == OpGet {
+		fields = append(fields, zap.Bool("found", e.Found))
+	}
+	if e.Err != nil {
+		l.l.Warn("gokv audit", append(fields, zap.Error(e.Err))...)
+		return
+	}
+	l.l.Info("gokv audit", fields...)
+}
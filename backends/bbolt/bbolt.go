@@ -1,7 +1,10 @@
 package bbolt
 
 import (
+	"bytes"
 	"context"
+	"encoding/binary"
+	"sync"
 
 	bolt "github.com/etcd-io/bbolt"
 
@@ -10,6 +13,7 @@ import (
 	"github.com/SpeedyCoder/gokv/internal/check"
 	"github.com/SpeedyCoder/gokv/internal/ctxconv"
 	"github.com/SpeedyCoder/gokv/internal/iterator"
+	"github.com/SpeedyCoder/gokv/internal/pubsub"
 )
 
 // Options are the options for the bbolt store.
@@ -74,7 +78,11 @@ func NewContextStore(options *Options) (gokv.ContextStore, error) {
 		if err != nil {
 			return err
 		}
-		return nil
+		_, err = tx.CreateBucketIfNotExists(revisionsBucketName(options.BucketName))
+		if err != nil {
+			return err
+		}
+		return sweepStreams(tx, options.BucketName)
 	})
 	if err != nil {
 		return result, err
@@ -83,6 +91,8 @@ func NewContextStore(options *Options) (gokv.ContextStore, error) {
 	result.db = db
 	result.bucketName = options.BucketName
 	result.codec = options.Encoding
+	result.writeLock = new(sync.Mutex)
+	result.hub = pubsub.NewHub()
 
 	return result, nil
 }
@@ -91,12 +101,104 @@ type store struct {
 	db         *bolt.DB
 	bucketName string
 	codec      encoding.Encoding
+	// writeLock is held during write transactions, see update().
+	writeLock *sync.Mutex
+	hub       *pubsub.Hub
+}
+
+// writeTx is a write transaction that records the changes it makes, so they can be
+// published to watchers after the transaction was committed.
+type writeTx struct {
+	tx *bolt.Tx
+	b  *bolt.Bucket
+	// revisions is the bucket with the revision of each key, see revision().
+	revisions *bolt.Bucket
+	events    []gokv.Event
+	// streams is the name of the bucket for streamed values, see Put().
+	streams []byte
+}
+
+func (w *writeTx) put(k string, data []byte) error {
+	if err := w.b.Put([]byte(k), data); err != nil {
+		return err
+	}
+	return w.changed(k, data)
+}
+
+// changed bumps the revision of the given key and records an EventPut for it.
+func (w *writeTx) changed(k string, data []byte) error {
+	// The sequence of the bucket is never reset, so a key never gets the same revision twice,
+	// even if it's deleted and written again.
+	revision, err := w.revisions.NextSequence()
+	if err != nil {
+		return err
+	}
+	if err := w.revisions.Put([]byte(k), uint64ToBytes(revision)); err != nil {
+		return err
+	}
+	w.events = append(w.events, gokv.Event{
+		Type:     gokv.EventPut,
+		Key:      k,
+		Value:    data,
+		Revision: uint64(w.tx.ID()),
+	})
+	return nil
+}
+
+func (w *writeTx) delete(k string) error {
+	streamDeleted, err := w.deleteStream(k)
+	if err != nil {
+		return err
+	}
+	// Don't report deletions of non-existing key-value pairs.
+	if w.b.Get([]byte(k)) == nil && !streamDeleted {
+		return nil
+	}
+	if err := w.b.Delete([]byte(k)); err != nil {
+		return err
+	}
+	if err := w.revisions.Delete([]byte(k)); err != nil {
+		return err
+	}
+	w.events = append(w.events, gokv.Event{
+		Type:     gokv.EventDelete,
+		Key:      k,
+		Revision: uint64(w.tx.ID()),
+	})
+	return nil
+}
+
+// update runs fn in a write transaction and publishes its changes after the commit.
+// bbolt only allows one write transaction at a time anyway,
+// so holding writeLock doesn't reduce concurrency but makes sure
+// that changes are published in the order they were committed.
+func (s store) update(fn func(w *writeTx) error) error {
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+
+	w := &writeTx{}
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		w.tx = tx
+		w.b = tx.Bucket([]byte(s.bucketName))
+		w.revisions = tx.Bucket(revisionsBucketName(s.bucketName))
+		w.events = nil
+		w.streams = s.streamsBucketName()
+		return fn(w)
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, e := range w.events {
+		s.hub.Publish(e)
+	}
+	return nil
 }
 
 // Set stores the given value for the given key.
 // Values are automatically marshalled to JSON or gob (depending on the configuration).
 // The key must not be "" and the value must not be nil.
-func (s store) Set(_ context.Context, k string, v interface{}) error {
+func (s store) Set(ctx context.Context, k string, v interface{}) error {
 	if err := check.KeyAndValue(k, v); err != nil {
 		return err
 	}
@@ -106,10 +208,10 @@ func (s store) Set(_ context.Context, k string, v interface{}) error {
 	if err != nil {
 		return err
 	}
+	gokv.ReportValueSize(ctx, len(data))
 
-	err = s.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(s.bucketName))
-		return b.Put([]byte(k), data)
+	err = s.update(func(w *writeTx) error {
+		return w.put(k, data)
 	})
 	if err != nil {
 		return err
@@ -123,7 +225,7 @@ func (s store) Set(_ context.Context, k string, v interface{}) error {
 // that v points to with the values of the retrieved object's values.
 // If no value is found it returns (false, nil).
 // The key must not be "" and the pointer must not be nil.
-func (s store) Get(_ context.Context, k string, v interface{}) (found bool, err error) {
+func (s store) Get(ctx context.Context, k string, v interface{}) (found bool, err error) {
 	if err := check.KeyAndValue(k, v); err != nil {
 		return false, err
 	}
@@ -151,6 +253,7 @@ func (s store) Get(_ context.Context, k string, v interface{}) (found bool, err
 	if data == nil {
 		return false, nil
 	}
+	gokv.ReportValueSize(ctx, len(data))
 
 	return true, s.codec.Unmarshal(data, v)
 }
@@ -163,10 +266,250 @@ func (s store) Delete(_ context.Context, k string) error {
 		return err
 	}
 
-	return s.db.Update(func(tx *bolt.Tx) error {
+	return s.update(func(w *writeTx) error {
+		return w.delete(k)
+	})
+}
+
+// SetBytes stores the given data for the given key without marshalling it.
+// The key must not be "" and the data must not be nil.
+func (s store) SetBytes(_ context.Context, k string, data []byte) error {
+	if err := check.KeyAndData(k, data); err != nil {
+		return err
+	}
+
+	// The data is passed on to watchers, so the caller must be free to modify it afterwards.
+	data = append([]byte{}, data...)
+	return s.update(func(w *writeTx) error {
+		return w.put(k, data)
+	})
+}
+
+// GetBytes retrieves the data for the given key without unmarshalling it.
+// If no value is found it returns (nil, false, nil).
+// The key must not be "".
+func (s store) GetBytes(_ context.Context, k string) (data []byte, found bool, err error) {
+	if err := check.Key(k); err != nil {
+		return nil, false, err
+	}
+
+	err = s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(s.bucketName))
+		// See Get() for why the data must be copied.
+		if txData := b.Get([]byte(k)); txData != nil {
+			data = append([]byte{}, txData...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return data, data != nil, nil
+}
+
+// SetMany stores all given key-value pairs in a single transaction.
+// Values are automatically marshalled to JSON or gob (depending on the configuration).
+// Keys must not be "" and values must not be nil.
+func (s store) SetMany(_ context.Context, kvs map[string]interface{}) error {
+	// Marshal everything before starting the transaction,
+	// so the exclusive write lock is held as short as possible.
+	data := make(map[string][]byte, len(kvs))
+	for k, v := range kvs {
+		if err := check.KeyAndValue(k, v); err != nil {
+			return err
+		}
+		vData, err := s.codec.Marshal(v)
+		if err != nil {
+			return err
+		}
+		data[k] = vData
+	}
+
+	return s.update(func(w *writeTx) error {
+		for k, vData := range data {
+			if err := w.put(k, vData); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetMany retrieves the stored values for the given keys in a single transaction.
+// For each found key newValue is called to create the pointer that the value gets unmarshalled into.
+// The returned map only contains entries for keys that were found.
+// Keys must not be "".
+func (s store) GetMany(_ context.Context, keys []string, newValue func() interface{}) (map[string]interface{}, error) {
+	for _, k := range keys {
+		if err := check.Key(k); err != nil {
+			return nil, err
+		}
+	}
+
+	data := make(map[string][]byte, len(keys))
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(s.bucketName))
+		for _, k := range keys {
+			// See Get() for why the data must be copied.
+			if txData := b.Get([]byte(k)); txData != nil {
+				data[k] = append([]byte{}, txData...)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]interface{}, len(data))
+	for k, vData := range data {
+		v := newValue()
+		if err := check.KeyAndValue(k, v); err != nil {
+			return nil, err
+		}
+		if err := s.codec.Unmarshal(vData, v); err != nil {
+			return nil, err
+		}
+		result[k] = v
+	}
+	return result, nil
+}
+
+// DeleteMany deletes the stored values for the given keys in a single transaction.
+// Deleting non-existing key-value pairs does NOT lead to an error.
+// Keys must not be "".
+func (s store) DeleteMany(_ context.Context, keys []string) error {
+	for _, k := range keys {
+		if err := check.Key(k); err != nil {
+			return err
+		}
+	}
+
+	return s.update(func(w *writeTx) error {
+		for _, k := range keys {
+			if err := w.delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetWithVersion retrieves the stored value for the given key together with its version.
+// The version is the revision of the key, which changes with every write of the key,
+// even if the same value is written again.
+// If no value is found it returns (false, 0, nil).
+// The key must not be "" and the pointer must not be nil.
+func (s store) GetWithVersion(_ context.Context, k string, v interface{}) (found bool, version uint64, err error) {
+	if err := check.KeyAndValue(k, v); err != nil {
+		return false, 0, err
+	}
+
+	var data []byte
+	err = s.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(s.bucketName))
-		return b.Delete([]byte(k))
+		// See Get() for why the data must be copied.
+		if txData := b.Get([]byte(k)); txData != nil {
+			data = append([]byte{}, txData...)
+			version = revision(tx.Bucket(revisionsBucketName(s.bucketName)), k)
+		}
+		return nil
+	})
+	if err != nil || data == nil {
+		return false, 0, err
+	}
+
+	return true, version, s.codec.Unmarshal(data, v)
+}
+
+// SetIfNotExists stores the given value for the given key if no value is stored for the key yet.
+// The check and the write happen in the same transaction.
+// The key must not be "" and the value must not be nil.
+func (s store) SetIfNotExists(_ context.Context, k string, v interface{}) (stored bool, err error) {
+	if err := check.KeyAndValue(k, v); err != nil {
+		return false, err
+	}
+
+	data, err := s.codec.Marshal(v)
+	if err != nil {
+		return false, err
+	}
+
+	err = s.update(func(w *writeTx) error {
+		if w.b.Get([]byte(k)) != nil {
+			return nil
+		}
+		stored = true
+		return w.put(k, data)
 	})
+	if err != nil {
+		return false, err
+	}
+	return stored, nil
+}
+
+// CompareAndSwap stores the given value for the given key if the version of the
+// currently stored value is the expected one.
+// The check and the write happen in the same transaction.
+// The key must not be "" and the value must not be nil.
+func (s store) CompareAndSwap(_ context.Context, k string, expectedVersion uint64, v interface{}) (swapped bool, err error) {
+	if err := check.KeyAndValue(k, v); err != nil {
+		return false, err
+	}
+
+	data, err := s.codec.Marshal(v)
+	if err != nil {
+		return false, err
+	}
+
+	err = s.update(func(w *writeTx) error {
+		if w.b.Get([]byte(k)) == nil || revision(w.revisions, k) != expectedVersion {
+			return nil
+		}
+		swapped = true
+		return w.put(k, data)
+	})
+	if err != nil {
+		return false, err
+	}
+	return swapped, nil
+}
+
+// CompareAndDelete deletes the stored value for the given key if the version of the
+// currently stored value is the expected one.
+// The check and the deletion happen in the same transaction.
+// The key must not be "".
+func (s store) CompareAndDelete(_ context.Context, k string, expectedVersion uint64) (deleted bool, err error) {
+	if err := check.Key(k); err != nil {
+		return false, err
+	}
+
+	err = s.update(func(w *writeTx) error {
+		if w.b.Get([]byte(k)) == nil || revision(w.revisions, k) != expectedVersion {
+			return nil
+		}
+		deleted = true
+		return w.delete(k)
+	})
+	if err != nil {
+		return false, err
+	}
+	return deleted, nil
+}
+
+// revisionsBucketName returns the name of the top-level bucket for the revisions of the keys in the given bucket.
+func revisionsBucketName(bucketName string) []byte {
+	return []byte("gokv-revisions/" + bucketName)
+}
+
+// revision returns the revision of the given key in the revisions bucket.
+// Values that were written before revisions were tracked have revision 0.
+func revision(revisions *bolt.Bucket, k string) uint64 {
+	data := revisions.Get([]byte(k))
+	if len(data) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(data)
 }
 
 func (s store) Keys(ctx context.Context) gokv.KeysIterator {
@@ -182,8 +525,129 @@ func (s store) Keys(ctx context.Context) gokv.KeysIterator {
 	return it
 }
 
+// KeysWithPrefix returns an iterator over all keys that start with the given prefix, in byte-sorted order.
+func (s store) KeysWithPrefix(ctx context.Context, prefix string) gokv.KeysIterator {
+	return s.keysFrom(ctx, []byte(prefix), func(k []byte) bool {
+		return bytes.HasPrefix(k, []byte(prefix))
+	})
+}
+
+// KeysInRange returns an iterator over all keys k with start <= k < end, in byte-sorted order.
+// An empty end means there's no upper bound.
+func (s store) KeysInRange(ctx context.Context, start, end string) gokv.KeysIterator {
+	return s.keysFrom(ctx, []byte(start), func(k []byte) bool {
+		return end == "" || bytes.Compare(k, []byte(end)) < 0
+	})
+}
+
+// keysFrom iterates over the keys starting at the first key >= seek
+// until the first key for which inRange returns false.
+func (s store) keysFrom(ctx context.Context, seek []byte, inRange func(k []byte) bool) gokv.KeysIterator {
+	it := iterator.New(ctx)
+	go func() {
+		it.Close(s.db.View(func(tx *bolt.Tx) error {
+			c := tx.Bucket([]byte(s.bucketName)).Cursor()
+			for k, _ := c.Seek(seek); k != nil && inRange(k); k, _ = c.Next() {
+				if err := it.Write(string(k)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}))
+	}()
+	return it
+}
+
+// Items returns an iterator over all key-value pairs, read in a single transaction.
+// For each key-value pair newValue is called to create the pointer that the value gets unmarshalled into.
+func (s store) Items(ctx context.Context, newValue func() interface{}) gokv.ItemsIterator {
+	it := iterator.NewItems(ctx)
+	go func() {
+		it.Close(s.db.View(func(tx *bolt.Tx) error {
+			b := tx.Bucket([]byte(s.bucketName))
+			return b.ForEach(func(k, txData []byte) error {
+				v := newValue()
+				if err := check.KeyAndValue(string(k), v); err != nil {
+					return err
+				}
+				// See Get() for why the data must be copied.
+				data := append([]byte{}, txData...)
+				if err := s.codec.Unmarshal(data, v); err != nil {
+					return err
+				}
+				return it.Write(gokv.Item{Key: string(k), Value: v})
+			})
+		}))
+	}()
+	return it
+}
+
+// Update runs fn in a single write transaction.
+// If fn returns nil the transaction is committed, otherwise it's rolled back.
+// bbolt only allows one write transaction at a time, so there are no conflicts
+// and fn is called exactly once.
+func (s store) Update(_ context.Context, fn func(tx gokv.Tx) error) error {
+	return s.update(func(w *writeTx) error {
+		return fn(boltTx{w: w, codec: s.codec})
+	})
+}
+
+// boltTx is the gokv.Tx implementation of the store.
+type boltTx struct {
+	w     *writeTx
+	codec encoding.Encoding
+}
+
+// Set stores the given value for the given key in the transaction.
+func (t boltTx) Set(k string, v interface{}) error {
+	if err := check.KeyAndValue(k, v); err != nil {
+		return err
+	}
+
+	data, err := t.codec.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return t.w.put(k, data)
+}
+
+// Get retrieves the stored value for the given key in the transaction.
+// Changes made earlier in the transaction are visible.
+func (t boltTx) Get(k string, v interface{}) (found bool, err error) {
+	if err := check.KeyAndValue(k, v); err != nil {
+		return false, err
+	}
+
+	txData := t.w.b.Get([]byte(k))
+	if txData == nil {
+		return false, nil
+	}
+	// See store.Get() for why the data must be copied.
+	data := append([]byte{}, txData...)
+	return true, t.codec.Unmarshal(data, v)
+}
+
+// Delete deletes the stored value for the given key in the transaction.
+func (t boltTx) Delete(k string) error {
+	if err := check.Key(k); err != nil {
+		return err
+	}
+
+	return t.w.delete(k)
+}
+
+// Watch returns a channel to which an event is written for every change
+// of a key-value pair whose key starts with the given prefix.
+// The revision of an event is the ID of the transaction that made the change.
+// Only changes made via this store are reported.
+// The channel is closed when ctx is done or the store is closed.
+func (s store) Watch(ctx context.Context, prefix string) <-chan gokv.Event {
+	return s.hub.Subscribe(ctx, prefix)
+}
+
 // Close closes the store.
 // It must be called to make sure that all open transactions finish and to release all DB resources.
 func (s store) Close() error {
+	s.hub.Close()
 	return s.db.Close()
 }
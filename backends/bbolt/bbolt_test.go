@@ -1,14 +1,19 @@
 package bbolt_test
 
 import (
+	"context"
 	"io/ioutil"
 	"log"
 	"os"
+	"strings"
 	"testing"
 
+	bolt "github.com/etcd-io/bbolt"
+
 	"github.com/SpeedyCoder/gokv"
 	"github.com/SpeedyCoder/gokv/backends/bbolt"
 	"github.com/SpeedyCoder/gokv/encoding"
+	"github.com/SpeedyCoder/gokv/internal/ctxconv"
 	"github.com/SpeedyCoder/gokv/internal/test"
 )
 
@@ -45,6 +50,27 @@ func TestTypes(t *testing.T) {
 		defer cleanUp(store, path)
 		test.Types(store, t)
 	})
+
+	// Test with MessagePack
+	t.Run("MessagePack", func(t *testing.T) {
+		store, path := createStore(t, encoding.MsgPack)
+		defer cleanUp(store, path)
+		test.Types(store, t)
+	})
+
+	// Test with CBOR
+	t.Run("CBOR", func(t *testing.T) {
+		store, path := createStore(t, encoding.CBOR)
+		defer cleanUp(store, path)
+		test.Types(store, t)
+	})
+
+	// Test with YAML
+	t.Run("YAML", func(t *testing.T) {
+		store, path := createStore(t, encoding.YAML)
+		defer cleanUp(store, path)
+		test.Types(store, t)
+	})
 }
 
 // TestStoreConcurrent launches a bunch of goroutines that concurrently work with one store.
@@ -168,6 +194,287 @@ func TestNonExistingDir(t *testing.T) {
 	}
 }
 
+// TestRaw tests the native implementation of the gokv.RawStore methods.
+func TestRaw(t *testing.T) {
+	store, path := createContextStore(t, encoding.JSON)
+	defer cleanUp(ctxconv.ToStore(store), path)
+	rawStore, ok := store.(gokv.RawStore)
+	if !ok {
+		t.Fatal("Expected the store to implement gokv.RawStore")
+	}
+	test.RawStore(rawStore, t)
+
+	// Data that's stored raw can be read with the codec and vice versa
+	ctx := context.Background()
+	err := rawStore.SetBytes(ctx, "foo", []byte(`{"Bar":"baz"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual := new(test.Foo)
+	found, err := store.Get(ctx, "foo", actual)
+	if err != nil || !found || actual.Bar != "baz" {
+		t.Errorf("Expected to find {Bar: baz}, but was: %v, %v, %v", *actual, found, err)
+	}
+	err = store.Set(ctx, "foo", test.Foo{Bar: "qux"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _, err := rawStore.GetBytes(ctx, "foo")
+	if err != nil || string(data) != `{"Bar":"qux"}` {
+		t.Errorf("Expected: {\"Bar\":\"qux\"}, but was: %s, %v", data, err)
+	}
+}
+
+// TestStream tests the native implementation of the gokv.StreamStore methods.
+// Streamed values must not interfere with values stored with Set().
+func TestStream(t *testing.T) {
+	store, path := createContextStore(t, encoding.JSON)
+	defer cleanUp(ctxconv.ToStore(store), path)
+	streamStore, ok := store.(gokv.StreamStore)
+	if !ok {
+		t.Fatal("Expected the store to implement gokv.StreamStore")
+	}
+	test.StreamStore(streamStore, t)
+
+	ctx := context.Background()
+	err := streamStore.Put(ctx, "foo", strings.NewReader("bar"), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Store(ctxconv.ToStore(store), t)
+	err = store.Delete(ctx, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = streamStore.Open(ctx, "foo")
+	if err != gokv.ErrNotFound {
+		t.Errorf("Expected gokv.ErrNotFound, but was: %v", err)
+	}
+}
+
+// TestStreamSize tests if Put stops reading once the reader returned more data than expected.
+func TestStreamSize(t *testing.T) {
+	store, path := createContextStore(t, encoding.JSON)
+	defer cleanUp(ctxconv.ToStore(store), path)
+	streamStore := store.(gokv.StreamStore)
+
+	r := &endlessReader{}
+	err := streamStore.Put(context.Background(), "foo", r, 3)
+	if err == nil {
+		t.Error("An error was expected, but none was returned")
+	}
+	if r.read > 4 {
+		t.Errorf("Expected at most 4 bytes to be read, but %d were", r.read)
+	}
+	_, err = streamStore.Open(context.Background(), "foo")
+	if err != gokv.ErrNotFound {
+		t.Errorf("Expected gokv.ErrNotFound, but was: %v", err)
+	}
+}
+
+// TestStreamOrphans tests if chunks of streams that were never completed
+// are deleted when the store is opened.
+func TestStreamOrphans(t *testing.T) {
+	store, path := createContextStore(t, encoding.JSON)
+	streamStore := store.(gokv.StreamStore)
+	ctx := context.Background()
+	err := streamStore.Put(ctx, "foo", strings.NewReader("bar"), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Chunks without manifest, like after the process exited during Put()
+	streams := []byte("gokv-streams/" + bbolt.DefaultBucketName)
+	orphan := []byte("orphan")
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.Bucket(streams).Bucket([]byte("chunks")).CreateBucket(orphan)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte("chunk"), []byte("baz"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store, err = bbolt.NewContextStore(&bbolt.Options{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := store.(gokv.StreamStore).Open(ctx, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil || string(data) != "bar" {
+		t.Errorf("Expected: bar, but was: %s, %v", data, err)
+	}
+	err = store.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err = bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+	defer db.Close()
+	err = db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket(streams).Bucket([]byte("chunks")).Bucket(orphan) != nil {
+			t.Error("The orphaned chunks weren't deleted")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestStreamChanges tests if streaming a value changes the version of the key and is published to watchers.
+func TestStreamChanges(t *testing.T) {
+	store, path := createContextStore(t, encoding.JSON)
+	defer cleanUp(ctxconv.ToStore(store), path)
+	streamStore := store.(gokv.StreamStore)
+	conditionalStore := store.(gokv.ConditionalStore)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := store.(gokv.Watcher).Watch(ctx, "")
+
+	err := store.Set(ctx, "foo", "bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, version, err := conditionalStore.GetWithVersion(ctx, "foo", new(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = streamStore.Put(ctx, "foo", strings.NewReader("baz"), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	swapped, err := conditionalStore.CompareAndSwap(ctx, "foo", version, "qux")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if swapped {
+		t.Error("Expected the version to change when a value is streamed for the key")
+	}
+	// Deleting a key with only a streamed value is reported as well
+	err = streamStore.Put(ctx, "stream", strings.NewReader("baz"), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.Delete(ctx, "stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []gokv.Event{
+		{Type: gokv.EventPut, Key: "foo", Value: []byte(`"bar"`)},
+		{Type: gokv.EventPut, Key: "foo"},
+		{Type: gokv.EventPut, Key: "stream"},
+		{Type: gokv.EventDelete, Key: "stream"},
+	}
+	for _, e := range expected {
+		actual := <-events
+		if actual.Type != e.Type || actual.Key != e.Key || string(actual.Value) != string(e.Value) {
+			t.Errorf("Expected: %+v, but was: %+v", e, actual)
+		}
+	}
+}
+
+// endlessReader returns zeros forever and counts how many it returned.
+type endlessReader struct {
+	read int
+}
+
+func (r *endlessReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	r.read += len(p)
+	return len(p), nil
+}
+
+// TestBatch tests the native implementation of the gokv.BatchStore methods.
+func TestBatch(t *testing.T) {
+	store, path := createContextStore(t, encoding.JSON)
+	defer cleanUp(ctxconv.ToStore(store), path)
+	batchStore, ok := store.(gokv.BatchStore)
+	if !ok {
+		t.Fatal("Expected the store to implement gokv.BatchStore")
+	}
+	test.BatchStore(batchStore, t)
+}
+
+// TestConditional tests the native implementation of the gokv.ConditionalStore methods.
+func TestConditional(t *testing.T) {
+	store, path := createContextStore(t, encoding.JSON)
+	defer cleanUp(ctxconv.ToStore(store), path)
+	conditionalStore, ok := store.(gokv.ConditionalStore)
+	if !ok {
+		t.Fatal("Expected the store to implement gokv.ConditionalStore")
+	}
+	test.ConditionalStore(conditionalStore, t)
+}
+
+// TestRange tests the native implementation of the gokv.RangeStore methods.
+func TestRange(t *testing.T) {
+	store, path := createContextStore(t, encoding.JSON)
+	defer cleanUp(ctxconv.ToStore(store), path)
+	rangeStore, ok := store.(gokv.RangeStore)
+	if !ok {
+		t.Fatal("Expected the store to implement gokv.RangeStore")
+	}
+	test.RangeStore(rangeStore, t)
+}
+
+// TestItems tests the native implementation of the gokv.ItemsStore methods.
+func TestItems(t *testing.T) {
+	store, path := createContextStore(t, encoding.JSON)
+	defer cleanUp(ctxconv.ToStore(store), path)
+	itemsStore, ok := store.(gokv.ItemsStore)
+	if !ok {
+		t.Fatal("Expected the store to implement gokv.ItemsStore")
+	}
+	test.ItemsStore(itemsStore, t)
+}
+
+// TestWatch tests if changes are published to watchers.
+func TestWatch(t *testing.T) {
+	store, path := createContextStore(t, encoding.JSON)
+	defer cleanUp(ctxconv.ToStore(store), path)
+	watcher, ok := store.(gokv.Watcher)
+	if !ok {
+		t.Fatal("Expected the store to implement gokv.Watcher")
+	}
+	test.Watcher(watcher, t)
+}
+
+// TestTransactional tests the native implementation of gokv.Transactional.
+func TestTransactional(t *testing.T) {
+	store, path := createContextStore(t, encoding.JSON)
+	defer cleanUp(ctxconv.ToStore(store), path)
+	txStore, ok := store.(gokv.Transactional)
+	if !ok {
+		t.Fatal("Expected the store to implement gokv.Transactional")
+	}
+	test.Transactional(txStore, t)
+}
+
 func createStore(t *testing.T, codec encoding.Encoding) (gokv.Store, string) {
 	path := generateRandomTempDbPath(t)
 	options := bbolt.Options{
@@ -181,6 +488,19 @@ func createStore(t *testing.T, codec encoding.Encoding) (gokv.Store, string) {
 	return store, path
 }
 
+func createContextStore(t *testing.T, codec encoding.Encoding) (gokv.ContextStore, string) {
+	path := generateRandomTempDbPath(t)
+	options := bbolt.Options{
+		Path:     path,
+		Encoding: codec,
+	}
+	store, err := bbolt.NewContextStore(&options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store, path
+}
+
 func generateRandomTempDbPath(t *testing.T) string {
 	path, err := ioutil.TempDir(os.TempDir(), "bbolt")
 	if err != nil {
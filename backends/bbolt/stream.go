@@ -0,0 +1,299 @@
+package bbolt
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	bolt "github.com/etcd-io/bbolt"
+
+	"github.com/SpeedyCoder/gokv"
+	"github.com/SpeedyCoder/gokv/internal/check"
+	"github.com/SpeedyCoder/gokv/internal/stream"
+)
+
+const (
+	// streamChunkSize is the size of the chunks that streamed values are split into.
+	streamChunkSize = 64 * 1024
+	// streamChunksPerTx is the number of chunks that are written per transaction,
+	// so that large values don't have to be kept in memory until they're committed.
+	streamChunksPerTx = 64
+)
+
+var (
+	// streamManifests is the bucket that maps keys to the ID and size of their stream.
+	streamManifests = []byte("manifests")
+	// streamChunks is the bucket that contains a bucket with the chunks of each stream.
+	streamChunks = []byte("chunks")
+
+	errStreamReplaced = errors.New("the stream was replaced or deleted while reading it")
+	errStreamClosed   = errors.New("the stream is closed")
+)
+
+// streamsBucketName returns the name of the top-level bucket for the streams of the store.
+// Streams aren't stored in the store's bucket, so they don't show up in Keys().
+func (s store) streamsBucketName() []byte {
+	return streamsBucketName(s.bucketName)
+}
+
+func streamsBucketName(bucketName string) []byte {
+	return []byte("gokv-streams/" + bucketName)
+}
+
+// sweepStreams deletes the chunks of streams that no manifest refers to.
+// They're left behind when the process exits while a value is being streamed.
+// It must only be called while no value is being streamed, i.e. when opening the store.
+func sweepStreams(tx *bolt.Tx, bucketName string) error {
+	streams := tx.Bucket(streamsBucketName(bucketName))
+	if streams == nil {
+		return nil
+	}
+	referenced := make(map[string]bool)
+	err := streams.Bucket(streamManifests).ForEach(func(_, manifest []byte) error {
+		referenced[string(manifest[:8])] = true
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// Buckets must not be deleted while iterating over them
+	chunks := streams.Bucket(streamChunks)
+	var orphans [][]byte
+	err = chunks.ForEach(func(id, _ []byte) error {
+		if !referenced[string(id)] {
+			orphans = append(orphans, append([]byte{}, id...))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, id := range orphans {
+		if err := chunks.DeleteBucket(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Put stores the data that's read from r until io.EOF for the given key.
+// The data is split into chunks, which are written in multiple transactions,
+// so the value doesn't have to fit into memory.
+// The value only becomes visible to Open() when all chunks are written.
+// Chunks of values that were never completed, e.g. because the process exited,
+// are deleted when the store is opened the next time.
+// Streamed values are independent of the values stored with Set(),
+// but Delete() deletes both.
+// Like Set(), Put() changes the revision of the key, which is the version of the value stored with Set(),
+// and publishes a gokv.EventPut to watchers, without the value, because it might not fit into memory.
+// The key must not be "" and the reader must not be nil.
+func (s store) Put(ctx context.Context, k string, r io.Reader, size int64) error {
+	if err := check.KeyAndReader(k, r); err != nil {
+		return err
+	}
+
+	var id []byte
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		streams, err := tx.CreateBucketIfNotExists(s.streamsBucketName())
+		if err != nil {
+			return err
+		}
+		if _, err := streams.CreateBucketIfNotExists(streamManifests); err != nil {
+			return err
+		}
+		chunks, err := streams.CreateBucketIfNotExists(streamChunks)
+		if err != nil {
+			return err
+		}
+		seq, err := chunks.NextSequence()
+		if err != nil {
+			return err
+		}
+		id = uint64ToBytes(seq)
+		_, err = chunks.CreateBucket(id)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	written, err := s.writeChunks(id, stream.NewReader(ctx, r, size))
+	if err != nil {
+		// Best effort, the error of writing the chunks is more important
+		_ = s.db.Update(func(tx *bolt.Tx) error {
+			return tx.Bucket(s.streamsBucketName()).Bucket(streamChunks).DeleteBucket(id)
+		})
+		return err
+	}
+
+	manifest := append(append([]byte{}, id...), uint64ToBytes(uint64(written))...)
+	return s.update(func(w *writeTx) error {
+		if _, err := w.deleteStream(k); err != nil {
+			return err
+		}
+		if err := w.tx.Bucket(w.streams).Bucket(streamManifests).Put([]byte(k), manifest); err != nil {
+			return err
+		}
+		return w.changed(k, nil)
+	})
+}
+
+// writeChunks reads r until io.EOF and writes the data into the chunks bucket with the given ID.
+// Reading from r happens outside of transactions, so a slow reader doesn't block other writes.
+func (s store) writeChunks(id []byte, r io.Reader) (written int64, err error) {
+	var index uint64
+	for done := false; !done; {
+		chunks := make([][]byte, 0, streamChunksPerTx)
+		for len(chunks) < streamChunksPerTx && !done {
+			chunk := make([]byte, streamChunkSize)
+			n, err := io.ReadFull(r, chunk)
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				done = true
+			} else if err != nil {
+				return written, err
+			}
+			if n > 0 {
+				chunks = append(chunks, chunk[:n])
+				written += int64(n)
+			}
+		}
+
+		err := s.db.Update(func(tx *bolt.Tx) error {
+			b := tx.Bucket(s.streamsBucketName()).Bucket(streamChunks).Bucket(id)
+			if b == nil {
+				return errStreamReplaced
+			}
+			for _, chunk := range chunks {
+				if err := b.Put(uint64ToBytes(index), chunk); err != nil {
+					return err
+				}
+				index++
+			}
+			return nil
+		})
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// Open returns a reader for the value that was streamed for the given key.
+// The chunks are read one at a time, each in its own transaction.
+// If the value is replaced or deleted while it's being read, the reader returns an error.
+// If no value is found it returns gokv.ErrNotFound.
+// The key must not be "".
+func (s store) Open(ctx context.Context, k string) (io.ReadCloser, error) {
+	if err := check.Key(k); err != nil {
+		return nil, err
+	}
+
+	var manifest []byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		streams := tx.Bucket(s.streamsBucketName())
+		if streams == nil {
+			return nil
+		}
+		// See Get() for why the data must be copied.
+		if txManifest := streams.Bucket(streamManifests).Get([]byte(k)); txManifest != nil {
+			manifest = append([]byte{}, txManifest...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if manifest == nil {
+		return nil, gokv.ErrNotFound
+	}
+
+	return &streamReader{
+		s:         s,
+		ctx:       ctx,
+		id:        manifest[:8],
+		remaining: int64(binary.BigEndian.Uint64(manifest[8:])),
+	}, nil
+}
+
+// deleteStream deletes the stream of the given key, if there is one.
+// It returns whether there was one.
+func (w *writeTx) deleteStream(k string) (deleted bool, err error) {
+	streams := w.tx.Bucket(w.streams)
+	if streams == nil {
+		return false, nil
+	}
+	manifests := streams.Bucket(streamManifests)
+	manifest := manifests.Get([]byte(k))
+	if manifest == nil {
+		return false, nil
+	}
+	id := append([]byte{}, manifest[:8]...)
+	if err := streams.Bucket(streamChunks).DeleteBucket(id); err != nil {
+		return false, err
+	}
+	return true, manifests.Delete([]byte(k))
+}
+
+// streamReader reads the chunks of a stream.
+type streamReader struct {
+	s         store
+	ctx       context.Context
+	id        []byte
+	next      uint64
+	buf       []byte
+	remaining int64
+	closed    bool
+}
+
+// Read reads from the current chunk and reads the next chunk when the current one is exhausted.
+func (r *streamReader) Read(p []byte) (int, error) {
+	if r.closed {
+		return 0, errStreamClosed
+	}
+	if len(r.buf) == 0 {
+		if r.remaining == 0 {
+			return 0, io.EOF
+		}
+		if err := r.ctx.Err(); err != nil {
+			return 0, err
+		}
+		err := r.s.db.View(func(tx *bolt.Tx) error {
+			b := tx.Bucket(r.s.streamsBucketName()).Bucket(streamChunks).Bucket(r.id)
+			if b == nil {
+				return errStreamReplaced
+			}
+			chunk := b.Get(uint64ToBytes(r.next))
+			if chunk == nil {
+				return errStreamReplaced
+			}
+			// See Get() for why the data must be copied.
+			r.buf = append([]byte{}, chunk...)
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+		r.next++
+	}
+
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	r.remaining -= int64(n)
+	return n, nil
+}
+
+// Close releases the buffered chunk. Reading after closing leads to an error.
+func (r *streamReader) Close() error {
+	r.closed = true
+	r.buf = nil
+	return nil
+}
+
+// uint64ToBytes returns the big endian representation of v,
+// which keeps the keys of chunks in the order of their index.
+func uint64ToBytes(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
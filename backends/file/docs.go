@@ -0,0 +1,6 @@
+/*
+Package file contains an implementation of the `gokv.ContextStore` interface for local files.
+Each key-value pair is a file with the key as name and the value as content.
+The store also implements `gokv.StreamStore` for large values, which are written to a temporary file first, so they replace the file of the key atomically.
+*/
+package file
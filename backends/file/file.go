@@ -1,19 +1,32 @@
 package file
 
 import (
+	"context"
+	"io"
 	"io/ioutil"
 	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 
+	"github.com/SpeedyCoder/gokv"
 	"github.com/SpeedyCoder/gokv/encoding"
 	"github.com/SpeedyCoder/gokv/internal/check"
+	"github.com/SpeedyCoder/gokv/internal/iterator"
+	"github.com/SpeedyCoder/gokv/internal/stream"
 )
 
+// tempFilePattern is the pattern for the names of the temporary files that Put() writes to.
+// "%" is always escaped in keys, and "%tm" isn't a valid escape sequence,
+// so temporary files can't be mistaken for the files of keys.
+const tempFilePattern = "%tmp-"
+
 var defaultFilenameExtension = "json"
 
-// Store is a gokv.Store implementation for storing key-value pairs as files.
+// Store is a gokv.ContextStore implementation for storing key-value pairs as files.
+// It also implements gokv.StreamStore.
+// File operations can't be canceled, so the context is only used for iterating over the keys.
 type Store struct {
 	// For locking the locks map
 	// (no two goroutines may create a lock for a filename that doesn't have a lock yet).
@@ -28,7 +41,7 @@ type Store struct {
 // Set stores the given value for the given key.
 // Values are automatically marshalled to JSON or gob (depending on the configuration).
 // The key must not be "" and the value must not be nil.
-func (s Store) Set(k string, v interface{}) error {
+func (s Store) Set(_ context.Context, k string, v interface{}) error {
 	if err := check.KeyAndValue(k, v); err != nil {
 		return err
 	}
@@ -43,11 +56,7 @@ func (s Store) Set(k string, v interface{}) error {
 	// Prepare file lock.
 	lock := s.prepFileLock(escapedKey)
 
-	filename := escapedKey
-	if s.filenameExtension != "" {
-		filename += "." + s.filenameExtension
-	}
-	filePath := filepath.Clean(s.directory + "/" + filename)
+	filePath := s.filePath(escapedKey)
 
 	// File lock and file handling.
 	lock.Lock()
@@ -61,7 +70,7 @@ func (s Store) Set(k string, v interface{}) error {
 // that v points to with the values of the retrieved object's values.
 // If no value is found it returns (false, nil).
 // The key must not be "" and the pointer must not be nil.
-func (s Store) Get(k string, v interface{}) (found bool, err error) {
+func (s Store) Get(_ context.Context, k string, v interface{}) (found bool, err error) {
 	if err := check.KeyAndValue(k, v); err != nil {
 		return false, err
 	}
@@ -71,11 +80,7 @@ func (s Store) Get(k string, v interface{}) (found bool, err error) {
 	// Prepare file lock.
 	lock := s.prepFileLock(escapedKey)
 
-	filename := escapedKey
-	if s.filenameExtension != "" {
-		filename += "." + s.filenameExtension
-	}
-	filePath := filepath.Clean(s.directory + "/" + filename)
+	filePath := s.filePath(escapedKey)
 
 	// File lock and file handling.
 	lock.RLock()
@@ -95,7 +100,7 @@ func (s Store) Get(k string, v interface{}) (found bool, err error) {
 // Delete deletes the stored value for the given key.
 // Deleting a non-existing key-value pair does NOT lead to an error.
 // The key must not be "".
-func (s Store) Delete(k string) error {
+func (s Store) Delete(_ context.Context, k string) error {
 	if err := check.Key(k); err != nil {
 		return err
 	}
@@ -105,11 +110,7 @@ func (s Store) Delete(k string) error {
 	// Prepare file lock.
 	lock := s.prepFileLock(escapedKey)
 
-	filename := escapedKey
-	if s.filenameExtension != "" {
-		filename += "." + s.filenameExtension
-	}
-	filePath := filepath.Clean(s.directory + "/" + filename)
+	filePath := s.filePath(escapedKey)
 
 	// File lock and file handling.
 	lock.Lock()
@@ -121,6 +122,129 @@ func (s Store) Delete(k string) error {
 	return err
 }
 
+// Put stores the data that's read from r until io.EOF for the given key.
+// The data is written to a temporary file in the directory, which replaces the file of the key
+// only when all data was written, so Get() and Open() never see a partially written value.
+// Streamed values are stored in the same files as the values stored with Set(),
+// so Get() unmarshals streamed values with the codec and Open() returns the marshalled values stored with Set().
+// Temporary files are only left behind when the process exits during Put().
+// The key must not be "" and the reader must not be nil.
+func (s Store) Put(ctx context.Context, k string, r io.Reader, size int64) error {
+	if err := check.KeyAndReader(k, r); err != nil {
+		return err
+	}
+
+	// The temporary file must be in the same directory, because renaming isn't atomic across file systems.
+	tempFile, err := ioutil.TempFile(s.directory, tempFilePattern)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(tempFile, stream.NewReader(ctx, r, size))
+	if err == nil {
+		// Otherwise the rename could be persisted before the data after a crash.
+		err = tempFile.Sync()
+	}
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		// Best effort, the error of writing the file is more important
+		_ = os.Remove(tempFile.Name())
+		return err
+	}
+
+	escapedKey := url.PathEscape(k)
+
+	// Prepare file lock.
+	lock := s.prepFileLock(escapedKey)
+
+	// File lock and file handling.
+	lock.Lock()
+	defer lock.Unlock()
+	if err := os.Rename(tempFile.Name(), s.filePath(escapedKey)); err != nil {
+		_ = os.Remove(tempFile.Name())
+		return err
+	}
+	return nil
+}
+
+// Open returns a reader for the file of the given key.
+// Values that are streamed while the file is being read don't affect the reader,
+// because Put() replaces the file instead of writing to it, unlike Set().
+// If no value is found it returns gokv.ErrNotFound.
+// The key must not be "".
+func (s Store) Open(_ context.Context, k string) (io.ReadCloser, error) {
+	if err := check.Key(k); err != nil {
+		return nil, err
+	}
+
+	escapedKey := url.PathEscape(k)
+
+	// Prepare file lock.
+	lock := s.prepFileLock(escapedKey)
+
+	// File lock and file handling.
+	lock.RLock()
+	f, err := os.Open(s.filePath(escapedKey))
+	lock.RUnlock()
+	if os.IsNotExist(err) {
+		return nil, gokv.ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Keys returns an iterator over all keys in the directory, in lexical order of the filenames.
+// Files that don't belong to a key, like files with another filename extension, are skipped.
+func (s Store) Keys(ctx context.Context) gokv.KeysIterator {
+	it := iterator.New(ctx)
+	go func() {
+		infos, err := ioutil.ReadDir(s.directory)
+		if err != nil {
+			it.Close(err)
+			return
+		}
+		for _, info := range infos {
+			k, ok := s.key(info.Name())
+			if !ok || info.IsDir() {
+				continue
+			}
+			if err = it.Write(k); err != nil {
+				break
+			}
+		}
+		it.Close(err)
+	}()
+	return it
+}
+
+// filePath returns the path of the file for the given escaped key.
+func (s Store) filePath(escapedKey string) string {
+	filename := escapedKey
+	if s.filenameExtension != "" {
+		filename += "." + s.filenameExtension
+	}
+	return filepath.Clean(s.directory + "/" + filename)
+}
+
+// key returns the key that's stored in the file with the given name.
+// It returns false if the file doesn't belong to a key.
+func (s Store) key(filename string) (string, bool) {
+	if s.filenameExtension != "" {
+		if !strings.HasSuffix(filename, "."+s.filenameExtension) {
+			return "", false
+		}
+		filename = strings.TrimSuffix(filename, "."+s.filenameExtension)
+	}
+	// Files of keys have the escaped key as name, so other names can't be unescaped or differ when escaped again.
+	k, err := url.PathUnescape(filename)
+	if err != nil || k == "" || url.PathEscape(k) != filename {
+		return "", false
+	}
+	return k, true
+}
+
 // Close closes the store.
 // When called, some resources of the store are left for garbage collection.
 func (s Store) Close() error {
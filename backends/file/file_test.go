@@ -1,13 +1,18 @@
 package file_test
 
 import (
+	"context"
 	"io/ioutil"
 	"log"
 	"os"
+	"sort"
+	"strings"
 	"testing"
 
 	"github.com/SpeedyCoder/gokv"
+	"github.com/SpeedyCoder/gokv/backends/file"
 	"github.com/SpeedyCoder/gokv/encoding"
+	"github.com/SpeedyCoder/gokv/internal/ctxconv"
 	"github.com/SpeedyCoder/gokv/internal/test"
 )
 
@@ -143,19 +148,97 @@ func TestClose(t *testing.T) {
 	}
 }
 
-func createStore(t *testing.T, codec encoding.Encoding) (Store, string) {
+// TestKeys tests if Keys returns the keys of all values, but no other files.
+func TestKeys(t *testing.T) {
+	store, path := createStore(t, encoding.JSON)
+	defer cleanUp(store, path)
+	keys := []string{"foo", "a.json", "with/slash", "100%"}
+	for _, k := range keys {
+		err := store.Set(k, "bar")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Files that don't belong to a key
+	for _, name := range []string{"other.txt", "a b.json", "%zz.json"} {
+		err := ioutil.WriteFile(path+"/"+name, nil, 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var actual []string
+	it := store.Keys()
+	for k := range it.Ch() {
+		actual = append(actual, k)
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(keys)
+	sort.Strings(actual)
+	if len(actual) != len(keys) {
+		t.Fatalf("Expected: %v, but was: %v", keys, actual)
+	}
+	for i := range keys {
+		if actual[i] != keys[i] {
+			t.Errorf("Expected: %v, but was: %v", keys, actual)
+		}
+	}
+
+}
+
+// TestStream tests the implementation of the gokv.StreamStore methods.
+// Streamed values are stored in the same files as values stored with Set().
+func TestStream(t *testing.T) {
+	path := generateRandomTempDBpath(t)
+	store, err := file.NewStore(file.Options{Directory: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanUp(ctxconv.ToStore(store), path)
+	test.StreamStore(store, t)
+
+	ctx := context.Background()
+	err = store.Put(ctx, "foo", strings.NewReader(`"bar"`), 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actual string
+	found, err := store.Get(ctx, "foo", &actual)
+	if err != nil || !found || actual != "bar" {
+		t.Errorf("Expected: bar, but was: %v, %v, %v", actual, found, err)
+	}
+
+	// No temporary files are left behind, even when Put fails
+	err = store.Put(ctx, "foo", strings.NewReader("baz"), 4)
+	if err == nil {
+		t.Error("An error was expected, but none was returned")
+	}
+	infos, err := ioutil.ReadDir(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, info := range infos {
+		if info.Name() != "foo.json" {
+			t.Errorf("Unexpected file: %v", info.Name())
+		}
+	}
+}
+
+func createStore(t *testing.T, codec encoding.Encoding) (gokv.Store, string) {
 	path := generateRandomTempDBpath(t)
-	options := Options{
+	options := file.Options{
 		Directory: path,
 		// Setting no FileNameEnding leads to all Codecs writing ".json" files,
 		// but that doesn't matter to the functionality of gokv.
 		Codec: codec,
 	}
-	store, err := NewStore(options)
+	store, err := file.NewStore(options)
 	if err != nil {
 		t.Fatal(err)
 	}
-	return store, path
+	return ctxconv.ToStore(store), path
 }
 
 func generateRandomTempDBpath(t *testing.T) string {
@@ -120,12 +120,17 @@ func NewClient(options Options) (Client, error) {
 	if err != nil {
 		return result, err
 	}
+	keysStmt, err := db.Prepare("SELECT k FROM " + options.TableName)
+	if err != nil {
+		return result, err
+	}
 
 	c := sql.Client{
 		C:          db,
 		UpsertStmt: upsertStmt,
 		GetStmt:    getStmt,
 		DeleteStmt: deleteStmt,
+		KeysStmt:   keysStmt,
 		Codec:      options.Codec,
 	}
 
@@ -11,6 +11,7 @@ import (
 	"github.com/aws/aws-sdk-go/aws/session"
 	awsdynamodb "github.com/aws/aws-sdk-go/service/dynamodb"
 
+	"github.com/SpeedyCoder/gokv"
 	"github.com/SpeedyCoder/gokv/encoding"
 	"github.com/SpeedyCoder/gokv/internal/check"
 )
@@ -32,6 +33,11 @@ type Client struct {
 // Values are automatically marshalled to JSON or gob (depending on the configuration).
 // The key must not be "" and the value must not be nil.
 func (c Client) Set(k string, v interface{}) error {
+	return c.SetContext(context.Background(), k, v)
+}
+
+// SetContext is like Set, but uses the given context for the request.
+func (c Client) SetContext(ctx context.Context, k string, v interface{}) error {
 	if err := check.KeyAndValue(k, v); err != nil {
 		return err
 	}
@@ -41,6 +47,7 @@ func (c Client) Set(k string, v interface{}) error {
 	if err != nil {
 		return err
 	}
+	gokv.ReportValueSize(ctx, len(data))
 
 	item := make(map[string]*awsdynamodb.AttributeValue)
 	item[keyAttrName] = &awsdynamodb.AttributeValue{
@@ -53,7 +60,7 @@ func (c Client) Set(k string, v interface{}) error {
 		TableName: &c.tableName,
 		Item:      item,
 	}
-	_, err = c.c.PutItem(&putItemInput)
+	_, err = c.c.PutItemWithContext(ctx, &putItemInput)
 	if err != nil {
 		return err
 	}
@@ -67,6 +74,11 @@ func (c Client) Set(k string, v interface{}) error {
 // If no value is found it returns (false, nil).
 // The key must not be "" and the pointer must not be nil.
 func (c Client) Get(k string, v interface{}) (found bool, err error) {
+	return c.GetContext(context.Background(), k, v)
+}
+
+// GetContext is like Get, but uses the given context for the request.
+func (c Client) GetContext(ctx context.Context, k string, v interface{}) (found bool, err error) {
 	if err := check.KeyAndValue(k, v); err != nil {
 		return false, err
 	}
@@ -79,7 +91,7 @@ func (c Client) Get(k string, v interface{}) (found bool, err error) {
 		TableName: &c.tableName,
 		Key:       key,
 	}
-	getItemOutput, err := c.c.GetItem(&getItemInput)
+	getItemOutput, err := c.c.GetItemWithContext(ctx, &getItemInput)
 	if err != nil {
 		return false, err
 	} else if getItemOutput.Item == nil {
@@ -93,6 +105,7 @@ func (c Client) Get(k string, v interface{}) (found bool, err error) {
 		return false, nil
 	}
 	data := attributeVal.B
+	gokv.ReportValueSize(ctx, len(data))
 
 	return true, c.codec.Unmarshal(data, v)
 }
@@ -101,6 +114,11 @@ func (c Client) Get(k string, v interface{}) (found bool, err error) {
 // Deleting a non-existing key-value pair does NOT lead to an error.
 // The key must not be "".
 func (c Client) Delete(k string) error {
+	return c.DeleteContext(context.Background(), k)
+}
+
+// DeleteContext is like Delete, but uses the given context for the request.
+func (c Client) DeleteContext(ctx context.Context, k string) error {
 	if err := check.Key(k); err != nil {
 		return err
 	}
@@ -113,7 +131,7 @@ func (c Client) Delete(k string) error {
 		TableName: &c.tableName,
 		Key:       key,
 	}
-	_, err := c.c.DeleteItem(&deleteItemInput)
+	_, err := c.c.DeleteItemWithContext(ctx, &deleteItemInput)
 	return err
 }
 
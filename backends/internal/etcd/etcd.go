@@ -7,6 +7,7 @@ import (
 
 	"go.etcd.io/etcd/clientv3"
 
+	"github.com/SpeedyCoder/gokv"
 	"github.com/SpeedyCoder/gokv/encoding"
 	"github.com/SpeedyCoder/gokv/internal/check"
 )
@@ -24,6 +25,11 @@ type Client struct {
 // Values are automatically marshalled to JSON or gob (depending on the configuration).
 // The key must not be "" and the value must not be nil.
 func (c Client) Set(k string, v interface{}) error {
+	return c.SetContext(context.Background(), k, v)
+}
+
+// SetContext is like Set, but uses the given context for the request.
+func (c Client) SetContext(ctx context.Context, k string, v interface{}) error {
 	if err := check.KeyAndValue(k, v); err != nil {
 		return err
 	}
@@ -33,8 +39,9 @@ func (c Client) Set(k string, v interface{}) error {
 	if err != nil {
 		return err
 	}
+	gokv.ReportValueSize(ctx, len(data))
 
-	ctxWithTimeout, cancel := context.WithTimeout(context.Background(), c.timeOut)
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, c.timeOut)
 	defer cancel()
 	_, err = c.c.Put(ctxWithTimeout, k, string(data))
 	if err != nil {
@@ -51,11 +58,16 @@ func (c Client) Set(k string, v interface{}) error {
 // If no value is found it returns (false, nil).
 // The key must not be "" and the pointer must not be nil.
 func (c Client) Get(k string, v interface{}) (found bool, err error) {
+	return c.GetContext(context.Background(), k, v)
+}
+
+// GetContext is like Get, but uses the given context for the request.
+func (c Client) GetContext(ctx context.Context, k string, v interface{}) (found bool, err error) {
 	if err := check.KeyAndValue(k, v); err != nil {
 		return false, err
 	}
 
-	ctxWithTimeout, cancel := context.WithTimeout(context.Background(), c.timeOut)
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, c.timeOut)
 	defer cancel()
 	getRes, err := c.c.Get(ctxWithTimeout, k)
 	if err != nil {
@@ -67,6 +79,7 @@ func (c Client) Get(k string, v interface{}) (found bool, err error) {
 		return false, nil
 	}
 	data := kvs[0].Value
+	gokv.ReportValueSize(ctx, len(data))
 
 	return true, c.codec.Unmarshal(data, v)
 }
@@ -75,11 +88,16 @@ func (c Client) Get(k string, v interface{}) (found bool, err error) {
 // Deleting a non-existing key-value pair does NOT lead to an error.
 // The key must not be "".
 func (c Client) Delete(k string) error {
+	return c.DeleteContext(context.Background(), k)
+}
+
+// DeleteContext is like Delete, but uses the given context for the request.
+func (c Client) DeleteContext(ctx context.Context, k string) error {
 	if err := check.Key(k); err != nil {
 		return err
 	}
 
-	ctxWithTimeout, cancel := context.WithTimeout(context.Background(), c.timeOut)
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, c.timeOut)
 	defer cancel()
 	_, err := c.c.Delete(ctxWithTimeout, k)
 	return err
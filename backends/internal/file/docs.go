@@ -1,5 +0,0 @@
-/*
-Package file contains an implementation of the `gokv.Store` interface for local files.
-Each key-value pair is a file with the key as name and the value as content.
-*/
-package file
@@ -1,4 +0,0 @@
-/*
-Package postgresql contains an implementation of the `gokv.Store` interface for PostgreSQL.
-*/
-package postgresql
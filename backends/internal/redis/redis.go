@@ -1,8 +1,11 @@
 package redis
 
 import (
+	"context"
+
 	"github.com/go-redis/redis"
 
+	"github.com/SpeedyCoder/gokv"
 	"github.com/SpeedyCoder/gokv/encoding"
 	"github.com/SpeedyCoder/gokv/internal/check"
 )
@@ -17,6 +20,11 @@ type Client struct {
 // Values are automatically marshalled to JSON or gob (depending on the configuration).
 // The key must not be "" and the value must not be nil.
 func (c Client) Set(k string, v interface{}) error {
+	return c.SetContext(context.Background(), k, v)
+}
+
+// SetContext is like Set, but uses the given context for the request.
+func (c Client) SetContext(ctx context.Context, k string, v interface{}) error {
 	if err := check.KeyAndValue(k, v); err != nil {
 		return err
 	}
@@ -29,8 +37,9 @@ func (c Client) Set(k string, v interface{}) error {
 	if err != nil {
 		return err
 	}
+	gokv.ReportValueSize(ctx, len(data))
 
-	err = c.c.Set(k, string(data), 0).Err()
+	err = c.c.WithContext(ctx).Set(k, string(data), 0).Err()
 	if err != nil {
 		return err
 	}
@@ -44,17 +53,23 @@ func (c Client) Set(k string, v interface{}) error {
 // If no value is found it returns (false, nil).
 // The key must not be "" and the pointer must not be nil.
 func (c Client) Get(k string, v interface{}) (found bool, err error) {
+	return c.GetContext(context.Background(), k, v)
+}
+
+// GetContext is like Get, but uses the given context for the request.
+func (c Client) GetContext(ctx context.Context, k string, v interface{}) (found bool, err error) {
 	if err := check.KeyAndValue(k, v); err != nil {
 		return false, err
 	}
 
-	dataString, err := c.c.Get(k).Result()
+	dataString, err := c.c.WithContext(ctx).Get(k).Result()
 	if err != nil {
 		if err == redis.Nil {
 			return false, nil
 		}
 		return false, err
 	}
+	gokv.ReportValueSize(ctx, len(dataString))
 
 	return true, c.codec.Unmarshal([]byte(dataString), v)
 }
@@ -63,11 +78,16 @@ func (c Client) Get(k string, v interface{}) (found bool, err error) {
 // Deleting a non-existing key-value pair does NOT lead to an error.
 // The key must not be "".
 func (c Client) Delete(k string) error {
+	return c.DeleteContext(context.Background(), k)
+}
+
+// DeleteContext is like Delete, but uses the given context for the request.
+func (c Client) DeleteContext(ctx context.Context, k string) error {
 	if err := check.Key(k); err != nil {
 		return err
 	}
 
-	_, err := c.c.Del(k).Result()
+	_, err := c.c.WithContext(ctx).Del(k).Result()
 	return err
 }
 
@@ -1,4 +0,0 @@
-/*
-Package s3 contains an implementation of the `gokv.Store` interface for Amazon S3 and other S3-compatible services.
-*/
-package s3
@@ -0,0 +1,10 @@
+/*
+Package mongodb contains an implementation of the `gokv.ContextStore` interface for MongoDB.
+The client also implements `gokv.RawStore`, so values can be copied between stores without decoding them.
+It doesn't implement the other optional interfaces like `gokv.BatchStore` or `gokv.ConditionalStore` natively.
+For expiry, batches, range scans and watches use the wrappers in the packages `expiry`, `batch`, `scan` and `watch`.
+
+Note: If you use a sharded cluster, you must use "_id" as the shard key!
+You should also use hashed sharding as opposed to ranged sharding to enable more evenly distributed data no matter how your key looks like.
+*/
+package mongodb
@@ -1,12 +1,16 @@
 package mongodb
 
 import (
+	"context"
 	"time"
 
 	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
 
+	"github.com/SpeedyCoder/gokv"
 	"github.com/SpeedyCoder/gokv/encoding"
 	"github.com/SpeedyCoder/gokv/internal/check"
+	"github.com/SpeedyCoder/gokv/internal/iterator"
 )
 
 // item is the document that's stored in the MongoDB collection.
@@ -39,7 +43,9 @@ type item struct {
 	V []byte // "v" will be used as field name
 }
 
-// Client is a gokv.Store implementation for MongoDB.
+// Client is a gokv.ContextStore implementation for MongoDB.
+// It also implements gokv.RawStore.
+// mgo doesn't support contexts, so they're only used for iterating over the keys.
 type Client struct {
 	c *mgo.Collection
 	// Only needed for closing.
@@ -50,7 +56,7 @@ type Client struct {
 // Set stores the given value for the given key.
 // Values are automatically marshalled to JSON or gob (depending on the configuration).
 // The key must not be "" and the value must not be nil.
-func (c Client) Set(k string, v interface{}) error {
+func (c Client) Set(ctx context.Context, k string, v interface{}) error {
 	if err := check.KeyAndValue(k, v); err != nil {
 		return err
 	}
@@ -60,19 +66,9 @@ func (c Client) Set(k string, v interface{}) error {
 	if err != nil {
 		return err
 	}
+	gokv.ReportValueSize(ctx, len(data))
 
-	item := item{
-		// K needs to be specified, otherwise an update operation (on an existing document) would lead to the "_id" being overwritten by "",
-		// which 1) we don't want of course and 2) leads to an error anyway.
-		K: k,
-		V: data,
-	}
-	_, err = c.c.UpsertId(k, item)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return c.SetBytes(ctx, k, data)
 }
 
 // Get retrieves the stored value for the given key.
@@ -81,20 +77,16 @@ func (c Client) Set(k string, v interface{}) error {
 // that v points to with the values of the retrieved object's values.
 // If no value is found it returns (false, nil).
 // The key must not be "" and the pointer must not be nil.
-func (c Client) Get(k string, v interface{}) (found bool, err error) {
+func (c Client) Get(ctx context.Context, k string, v interface{}) (found bool, err error) {
 	if err := check.KeyAndValue(k, v); err != nil {
 		return false, err
 	}
 
-	item := new(item)
-	err = c.c.FindId(k).One(item)
-	// If no value was found return false
-	if err == mgo.ErrNotFound {
-		return false, nil
-	} else if err != nil {
-		return false, err
+	data, found, err := c.GetBytes(ctx, k)
+	if err != nil || !found {
+		return found, err
 	}
-	data := item.V
+	gokv.ReportValueSize(ctx, len(data))
 
 	return true, c.codec.Unmarshal(data, v)
 }
@@ -102,7 +94,7 @@ func (c Client) Get(k string, v interface{}) (found bool, err error) {
 // Delete deletes the stored value for the given key.
 // Deleting a non-existing key-value pair does NOT lead to an error.
 // The key must not be "".
-func (c Client) Delete(k string) error {
+func (c Client) Delete(_ context.Context, k string) error {
 	if err := check.Key(k); err != nil {
 		return err
 	}
@@ -114,6 +106,66 @@ func (c Client) Delete(k string) error {
 	return nil
 }
 
+// SetBytes stores the given data for the given key without marshalling it.
+// The key must not be "" and the data must not be nil.
+func (c Client) SetBytes(_ context.Context, k string, data []byte) error {
+	if err := check.KeyAndData(k, data); err != nil {
+		return err
+	}
+
+	item := item{
+		// K needs to be specified, otherwise an update operation (on an existing document) would lead to the "_id" being overwritten by "",
+		// which 1) we don't want of course and 2) leads to an error anyway.
+		K: k,
+		V: data,
+	}
+	_, err := c.c.UpsertId(k, item)
+	return err
+}
+
+// GetBytes retrieves the data for the given key without unmarshalling it.
+// If no value is found it returns (nil, false, nil).
+// The key must not be "".
+func (c Client) GetBytes(_ context.Context, k string) (data []byte, found bool, err error) {
+	if err := check.Key(k); err != nil {
+		return nil, false, err
+	}
+
+	item := new(item)
+	err = c.c.FindId(k).One(item)
+	// If no value was found return false
+	if err == mgo.ErrNotFound {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	// Found data is never nil, even if it was stored empty
+	if item.V == nil {
+		item.V = []byte{}
+	}
+	return item.V, true, nil
+}
+
+// Keys returns an iterator over all keys in the collection, in no particular order.
+func (c Client) Keys(ctx context.Context) gokv.KeysIterator {
+	it := iterator.New(ctx)
+	go func() {
+		iter := c.c.Find(nil).Select(bson.M{"_id": 1}).Iter()
+		var item item
+		var err error
+		for iter.Next(&item) {
+			if err = it.Write(item.K); err != nil {
+				break
+			}
+		}
+		if closeErr := iter.Close(); err == nil {
+			err = closeErr
+		}
+		it.Close(err)
+	}()
+	return it
+}
+
 // Close closes the client.
 // It must be called to release any open resources.
 func (c Client) Close() error {
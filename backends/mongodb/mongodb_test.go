@@ -5,9 +5,13 @@ import (
 	"testing"
 	"time"
 
+	"github.com/globalsign/mgo"
+
+	"github.com/SpeedyCoder/gokv"
+	"github.com/SpeedyCoder/gokv/backends/mongodb"
 	"github.com/SpeedyCoder/gokv/encoding"
+	"github.com/SpeedyCoder/gokv/internal/ctxconv"
 	"github.com/SpeedyCoder/gokv/internal/test"
-	"github.com/globalsign/mgo"
 )
 
 // TestClient tests if reading from, writing to and deleting from the store works properly.
@@ -98,10 +102,10 @@ func TestErrors(t *testing.T) {
 	}
 
 	// Test bad connection string
-	options := Options{
+	options := mongodb.Options{
 		ConnectionString: "forceError!",
 	}
-	_, err = NewClient(options)
+	_, err = mongodb.NewClient(options)
 	if err.Error() != "no reachable servers" {
 		t.Errorf(`Expected a "no reachable servers" error, but instead got: %v`, err)
 	}
@@ -185,6 +189,22 @@ func TestClose(t *testing.T) {
 	}
 }
 
+// TestRaw tests the native implementation of the gokv.RawStore methods.
+//
+// Note: This test is only executed if the initial connection to MongoDB works.
+func TestRaw(t *testing.T) {
+	if !checkConnection() {
+		t.Skip("No connection to MongoDB could be established. Probably not running in a proper test environment.")
+	}
+
+	client, err := mongodb.NewClient(mongodb.DefaultOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	test.RawStore(client, t)
+}
+
 // checkConnection returns true if a connection could be made, false otherwise.
 func checkConnection() bool {
 	session, err := mgo.DialWithTimeout("localhost", 2*time.Second)
@@ -200,13 +220,13 @@ func checkConnection() bool {
 	return true
 }
 
-func createClient(t *testing.T, codec encoding.Encoding) Client {
-	options := Options{
+func createClient(t *testing.T, codec encoding.Encoding) gokv.Store {
+	options := mongodb.Options{
 		Codec: codec,
 	}
-	client, err := NewClient(options)
+	client, err := mongodb.NewClient(options)
 	if err != nil {
 		t.Fatal(err)
 	}
-	return client
+	return ctxconv.ToStore(client)
 }
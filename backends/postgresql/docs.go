@@ -0,0 +1,8 @@
+/*
+Package postgresql contains an implementation of the `gokv.ContextStore` interface for PostgreSQL.
+The client also implements `gokv.RawStore`, so values can be copied between stores without decoding them,
+and `gokv.StreamStore`, which stores large values as chunks in a separate table, all inserted in one transaction.
+It doesn't implement the other optional interfaces like `gokv.BatchStore` or `gokv.ConditionalStore` natively.
+For expiry, batches, range scans and watches use the wrappers in the packages `expiry`, `batch`, `scan` and `watch`.
+*/
+package postgresql
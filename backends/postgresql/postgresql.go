@@ -1,7 +1,9 @@
 package postgresql
 
 import (
+	"context"
 	gosql "database/sql"
+	"io"
 
 	// Usually a blank import is enough as it calls the package's init() function and loads the driver,
 	// but we'll use the package's ParseDNS() function so we make this an actual import.
@@ -13,11 +15,55 @@ import (
 
 const defaultDBname = "gokv"
 
-// Client is a gokv.Store implementation for PostgreSQL.
+// Client is a gokv.ContextStore implementation for PostgreSQL.
+// It also implements gokv.RawStore and gokv.StreamStore.
 type Client struct {
 	*sql.Client
 }
 
+// Set stores the given value for the given key.
+// Values are automatically marshalled to JSON or gob (depending on the configuration).
+// The key must not be "" and the value must not be nil.
+func (c Client) Set(ctx context.Context, k string, v interface{}) error {
+	return c.Client.SetContext(ctx, k, v)
+}
+
+// Get retrieves the stored value for the given key.
+// You need to pass a pointer to the value, so in case of a struct
+// the automatic unmarshalling can populate the fields of the object
+// that v points to with the values of the retrieved object's values.
+// If no value is found it returns (false, nil).
+// The key must not be "" and the pointer must not be nil.
+func (c Client) Get(ctx context.Context, k string, v interface{}) (found bool, err error) {
+	return c.Client.GetContext(ctx, k, v)
+}
+
+// Delete deletes the stored value for the given key.
+// Deleting a non-existing key-value pair does NOT lead to an error.
+// The key must not be "".
+func (c Client) Delete(ctx context.Context, k string) error {
+	return c.Client.DeleteContext(ctx, k)
+}
+
+// Put stores the data that's read from r until io.EOF for the given key.
+// The data is split into chunks, which are inserted into the table "<TableName>_chunks" in one transaction,
+// so the value doesn't have to fit into memory and only becomes visible to Open() when all chunks are written.
+// Streamed values are independent of the values stored with Set(),
+// but Delete() deletes both.
+// The key must not be "" and the reader must not be nil.
+func (c Client) Put(ctx context.Context, k string, r io.Reader, size int64) error {
+	return c.Client.PutStream(ctx, k, r, size)
+}
+
+// Open returns a reader for the value that was streamed for the given key.
+// The chunks are selected one at a time.
+// If the value is replaced or deleted while it's being read, the reader returns an error.
+// If no value is found it returns gokv.ErrNotFound.
+// The key must not be "".
+func (c Client) Open(ctx context.Context, k string) (io.ReadCloser, error) {
+	return c.Client.OpenStream(ctx, k)
+}
+
 // Options are the options for the PostgreSQL client.
 type Options struct {
 	// Connection URL.
@@ -34,6 +80,7 @@ type Options struct {
 	// this is NOT the "same host" (except when running with `--network host`)).
 	ConnectionURL string
 	// Name of the table in which the key-value pairs are stored.
+	// Streamed values are stored in the tables "<TableName>_streams" and "<TableName>_chunks".
 	// Optional ("Item" by default).
 	TableName string
 	// Limits the number of open connections to the PostgreSQL server.
@@ -112,12 +159,22 @@ func NewClient(options Options) (Client, error) {
 	if err != nil {
 		return result, err
 	}
+	keysStmt, err := db.Prepare("SELECT k FROM " + options.TableName)
+	if err != nil {
+		return result, err
+	}
+	streamStmts, err := prepareStreamStmts(db, options.TableName)
+	if err != nil {
+		return result, err
+	}
 
 	c := sql.Client{
 		C:          db,
 		UpsertStmt: upsertStmt,
 		GetStmt:    getStmt,
 		DeleteStmt: deleteStmt,
+		KeysStmt:   keysStmt,
+		Streams:    streamStmts,
 		Codec:      options.Codec,
 	}
 
@@ -125,3 +182,41 @@ func NewClient(options Options) (Client, error) {
 
 	return result, nil
 }
+
+// prepareStreamStmts creates the tables for streamed values if they don't exist yet
+// and prepares the statements for them.
+func prepareStreamStmts(db *gosql.DB, tableName string) (*sql.StreamStmts, error) {
+	streamsTable := tableName + "_streams"
+	chunksTable := tableName + "_chunks"
+	idSequence := tableName + "_stream_ids"
+	ddl := []string{
+		"CREATE SEQUENCE IF NOT EXISTS " + idSequence,
+		"CREATE TABLE IF NOT EXISTS " + streamsTable + " (k TEXT PRIMARY KEY, id BIGINT NOT NULL, size BIGINT NOT NULL)",
+		"CREATE TABLE IF NOT EXISTS " + chunksTable + " (k TEXT, id BIGINT, n INTEGER, data BYTEA NOT NULL, PRIMARY KEY (k, id, n))",
+	}
+	for _, stmt := range ddl {
+		if _, err := db.Exec(stmt); err != nil {
+			return nil, err
+		}
+	}
+
+	// Prepared statements are closed when the DB is closed, so they don't have to be closed on errors.
+	var err error
+	stmts := sql.StreamStmts{}
+	prepare := func(stmt **gosql.Stmt, query string) {
+		if err == nil {
+			*stmt, err = db.Prepare(query)
+		}
+	}
+	prepare(&stmts.NextIDStmt, "SELECT nextval('"+idSequence+"')")
+	prepare(&stmts.InsertChunkStmt, "INSERT INTO "+chunksTable+" (k, id, n, data) VALUES ($1, $2, $3, $4)")
+	prepare(&stmts.GetChunkStmt, "SELECT data FROM "+chunksTable+" WHERE k = $1 AND id = $2 AND n = $3")
+	prepare(&stmts.UpsertStmt, "INSERT INTO "+streamsTable+" (k, id, size) VALUES ($1, $2, $3) ON CONFLICT (k) DO UPDATE SET id = $2, size = $3")
+	prepare(&stmts.GetStmt, "SELECT id, size FROM "+streamsTable+" WHERE k = $1")
+	prepare(&stmts.DeleteStmt, "DELETE FROM "+streamsTable+" WHERE k = $1")
+	prepare(&stmts.DeleteChunksStmt, "DELETE FROM "+chunksTable+" WHERE k = $1 AND id <> $2")
+	if err != nil {
+		return nil, err
+	}
+	return &stmts, nil
+}
@@ -0,0 +1,5 @@
+/*
+Package s3 contains an implementation of the `gokv.ContextStore` interface for Amazon S3 and other S3-compatible services.
+The client also implements `gokv.StreamStore` for large values, which are uploaded with a multipart upload.
+*/
+package s3
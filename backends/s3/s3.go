@@ -2,7 +2,9 @@ package s3
 
 import (
 	"bytes"
+	"context"
 	"errors"
+	"io"
 	"io/ioutil"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -10,14 +12,20 @@ import (
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	awss3 "github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 
+	"github.com/SpeedyCoder/gokv"
 	"github.com/SpeedyCoder/gokv/encoding"
 	"github.com/SpeedyCoder/gokv/internal/check"
+	"github.com/SpeedyCoder/gokv/internal/iterator"
+	"github.com/SpeedyCoder/gokv/internal/stream"
 )
 
-// Client is a gokv.Store implementation for S3.
+// Client is a gokv.ContextStore implementation for S3.
+// It also implements gokv.StreamStore.
 type Client struct {
 	c          *awss3.S3
+	uploader   *s3manager.Uploader
 	bucketName string
 	codec      encoding.Encoding
 }
@@ -25,7 +33,7 @@ type Client struct {
 // Set stores the given value for the given key.
 // Values are automatically marshalled to JSON or gob (depending on the configuration).
 // The key must not be "" and the value must not be nil.
-func (c Client) Set(k string, v interface{}) error {
+func (c Client) Set(ctx context.Context, k string, v interface{}) error {
 	if err := check.KeyAndValue(k, v); err != nil {
 		return err
 	}
@@ -41,7 +49,7 @@ func (c Client) Set(k string, v interface{}) error {
 		Bucket: &c.bucketName,
 		Key:    &k,
 	}
-	_, err = c.c.PutObject(&pubObjectInput)
+	_, err = c.c.PutObjectWithContext(ctx, &pubObjectInput)
 	if err != nil {
 		return err
 	}
@@ -55,7 +63,7 @@ func (c Client) Set(k string, v interface{}) error {
 // that v points to with the values of the retrieved object's values.
 // If no value is found it returns (false, nil).
 // The key must not be "" and the pointer must not be nil.
-func (c Client) Get(k string, v interface{}) (found bool, err error) {
+func (c Client) Get(ctx context.Context, k string, v interface{}) (found bool, err error) {
 	if err := check.KeyAndValue(k, v); err != nil {
 		return false, err
 	}
@@ -64,7 +72,7 @@ func (c Client) Get(k string, v interface{}) (found bool, err error) {
 		Bucket: &c.bucketName,
 		Key:    &k,
 	}
-	getObjectOutput, err := c.c.GetObject(&getObjectInput)
+	getObjectOutput, err := c.c.GetObjectWithContext(ctx, &getObjectInput)
 	if err != nil {
 		aerr, ok := err.(awserr.Error)
 		if ok && aerr.Code() == awss3.ErrCodeNoSuchKey {
@@ -90,7 +98,7 @@ func (c Client) Get(k string, v interface{}) (found bool, err error) {
 // Delete deletes the stored value for the given key.
 // Deleting a non-existing key-value pair does NOT lead to an error.
 // The key must not be "".
-func (c Client) Delete(k string) error {
+func (c Client) Delete(ctx context.Context, k string) error {
 	if err := check.Key(k); err != nil {
 		return err
 	}
@@ -99,10 +107,88 @@ func (c Client) Delete(k string) error {
 		Bucket: &c.bucketName,
 		Key:    &k,
 	}
-	_, err := c.c.DeleteObject(&deleteObjectInput)
+	_, err := c.c.DeleteObjectWithContext(ctx, &deleteObjectInput)
 	return err
 }
 
+// Put stores the data that's read from r until io.EOF for the given key.
+// The data is uploaded in parts with a multipart upload, so the value doesn't have to fit into memory.
+// If the upload fails, the uploaded parts are deleted and the previous value is kept.
+// Streamed values are stored in the same objects as the values stored with Set(),
+// so Get() unmarshals streamed values with the codec and Open() returns the marshalled values stored with Set().
+// The key must not be "" and the reader must not be nil.
+func (c Client) Put(ctx context.Context, k string, r io.Reader, size int64) error {
+	if err := check.KeyAndReader(k, r); err != nil {
+		return err
+	}
+
+	uploadInput := s3manager.UploadInput{
+		Body:   stream.NewReader(ctx, r, size),
+		Bucket: &c.bucketName,
+		Key:    &k,
+	}
+	_, err := c.uploader.UploadWithContext(ctx, &uploadInput, func(u *s3manager.Uploader) {
+		// The uploader can't determine the size of the wrapped reader,
+		// so the parts must be made bigger for values that would need too many of them.
+		if size/u.PartSize >= int64(u.MaxUploadParts) {
+			u.PartSize = size/int64(u.MaxUploadParts) + 1
+		}
+	})
+	return err
+}
+
+// Open returns a reader for the object of the given key.
+// The object is downloaded while reading from the returned reader.
+// If no value is found it returns gokv.ErrNotFound.
+// The key must not be "".
+func (c Client) Open(ctx context.Context, k string) (io.ReadCloser, error) {
+	if err := check.Key(k); err != nil {
+		return nil, err
+	}
+
+	getObjectInput := awss3.GetObjectInput{
+		Bucket: &c.bucketName,
+		Key:    &k,
+	}
+	getObjectOutput, err := c.c.GetObjectWithContext(ctx, &getObjectInput)
+	if err != nil {
+		aerr, ok := err.(awserr.Error)
+		if ok && aerr.Code() == awss3.ErrCodeNoSuchKey {
+			return nil, gokv.ErrNotFound
+		}
+		return nil, err
+	}
+	if getObjectOutput.Body == nil {
+		// Same as in Get()
+		return nil, gokv.ErrNotFound
+	}
+	return getObjectOutput.Body, nil
+}
+
+// Keys returns an iterator over all keys in the bucket, in the lexical order that S3 lists them in.
+func (c Client) Keys(ctx context.Context) gokv.KeysIterator {
+	it := iterator.New(ctx)
+	go func() {
+		listObjectsInput := awss3.ListObjectsV2Input{
+			Bucket: &c.bucketName,
+		}
+		var writeErr error
+		err := c.c.ListObjectsV2PagesWithContext(ctx, &listObjectsInput, func(page *awss3.ListObjectsV2Output, _ bool) bool {
+			for _, object := range page.Contents {
+				if writeErr = it.Write(*object.Key); writeErr != nil {
+					return false
+				}
+			}
+			return true
+		})
+		if writeErr != nil {
+			err = writeErr
+		}
+		it.Close(err)
+	}()
+	return it
+}
+
 // Close closes the client.
 // In the S3 implementation this doesn't have any effect.
 func (c Client) Close() error {
@@ -233,6 +319,7 @@ func NewClient(options Options) (Client, error) {
 	}
 
 	result.c = svc
+	result.uploader = s3manager.NewUploaderWithClient(svc)
 	result.bucketName = options.BucketName
 	result.codec = options.Codec
 
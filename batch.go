@@ -0,0 +1,24 @@
+package gokv
+
+import "context"
+
+// BatchStore is a ContextStore that can set, get and delete multiple key-value pairs at once.
+// Implementations for network-based stores can use this to save round trips.
+// For all other stores the batch package provides a wrapper that fans out
+// over the single key methods.
+type BatchStore interface {
+	ContextStore
+	// SetMany stores all given key-value pairs.
+	// Keys must not be "" and values must not be nil.
+	SetMany(ctx context.Context, kvs map[string]interface{}) error
+	// GetMany retrieves the values for the given keys.
+	// For each found key newValue is called to create the pointer
+	// that the value gets unmarshalled into.
+	// The returned map only contains entries for keys that were found.
+	// Keys must not be "" and newValue must not return nil.
+	GetMany(ctx context.Context, keys []string, newValue func() interface{}) (map[string]interface{}, error)
+	// DeleteMany deletes the stored values for the given keys.
+	// Deleting non-existing key-value pairs does NOT lead to an error.
+	// Keys must not be "".
+	DeleteMany(ctx context.Context, keys []string) error
+}
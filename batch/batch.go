@@ -0,0 +1,154 @@
+package batch
+
+import (
+	"context"
+	"sync"
+
+	"github.com/SpeedyCoder/gokv"
+	"github.com/SpeedyCoder/gokv/internal/check"
+)
+
+// Options are the options for the batch store.
+type Options struct {
+	// Maximum number of concurrent calls to the wrapped store per batch.
+	// Optional (10 by default).
+	MaxConcurrency int
+}
+
+const (
+	DefaultMaxConcurrency = 10
+)
+
+// NewStore creates a new gokv.BatchStore.
+// If the given store already implements gokv.BatchStore, it's returned unchanged.
+// Otherwise the batch methods call the single key methods of the given store concurrently.
+// In that case a batch is not atomic: when an error occurs some of the operations
+// might have been executed already.
+func NewStore(store gokv.ContextStore, options *Options) gokv.BatchStore {
+	if s, ok := store.(gokv.BatchStore); ok {
+		return s
+	}
+
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+
+	// Set default values
+	if opts.MaxConcurrency <= 0 {
+		opts.MaxConcurrency = DefaultMaxConcurrency
+	}
+
+	return batchStore{
+		ContextStore:   store,
+		maxConcurrency: opts.MaxConcurrency,
+	}
+}
+
+type batchStore struct {
+	gokv.ContextStore
+	maxConcurrency int
+}
+
+// SetMany stores all given key-value pairs.
+// Keys must not be "" and values must not be nil.
+func (s batchStore) SetMany(ctx context.Context, kvs map[string]interface{}) error {
+	keys := make([]string, 0, len(kvs))
+	for k, v := range kvs {
+		if err := check.KeyAndValue(k, v); err != nil {
+			return err
+		}
+		keys = append(keys, k)
+	}
+
+	return s.forEach(ctx, keys, func(ctx context.Context, k string) error {
+		return s.Set(ctx, k, kvs[k])
+	})
+}
+
+// GetMany retrieves the stored values for the given keys.
+// For each key newValue is called to create the pointer that the value gets unmarshalled into.
+// The returned map only contains entries for keys that were found.
+// Keys must not be "".
+func (s batchStore) GetMany(ctx context.Context, keys []string, newValue func() interface{}) (map[string]interface{}, error) {
+	for _, k := range keys {
+		if err := check.Key(k); err != nil {
+			return nil, err
+		}
+	}
+
+	result := make(map[string]interface{}, len(keys))
+	resultLock := sync.Mutex{}
+	err := s.forEach(ctx, keys, func(ctx context.Context, k string) error {
+		v := newValue()
+		found, err := s.Get(ctx, k, v)
+		if err != nil || !found {
+			return err
+		}
+		resultLock.Lock()
+		defer resultLock.Unlock()
+		result[k] = v
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// DeleteMany deletes the stored values for the given keys.
+// Deleting non-existing key-value pairs does NOT lead to an error.
+// Keys must not be "".
+func (s batchStore) DeleteMany(ctx context.Context, keys []string) error {
+	for _, k := range keys {
+		if err := check.Key(k); err != nil {
+			return err
+		}
+	}
+
+	return s.forEach(ctx, keys, s.Delete)
+}
+
+// forEach calls f for all keys with at most maxConcurrency calls running at the same time.
+// After the first error no new calls are started and the context passed to
+// running calls is canceled. The first error is returned.
+func (s batchStore) forEach(ctx context.Context, keys []string, f func(context.Context, string) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		firstErr error
+		errOnce  sync.Once
+		wg       sync.WaitGroup
+	)
+	sem := make(chan struct{}, s.maxConcurrency)
+
+	for _, k := range keys {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		// Both cases can be ready, so the context is checked in either case
+		if err := ctx.Err(); err != nil {
+			errOnce.Do(func() { firstErr = err })
+			break
+		}
+
+		wg.Add(1)
+		go func(k string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := f(ctx, k); err != nil {
+				errOnce.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}(k)
+	}
+	wg.Wait()
+
+	return firstErr
+}
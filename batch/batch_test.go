@@ -0,0 +1,77 @@
+package batch_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/SpeedyCoder/gokv"
+	"github.com/SpeedyCoder/gokv/batch"
+	"github.com/SpeedyCoder/gokv/internal/ctxconv"
+	"github.com/SpeedyCoder/gokv/internal/test"
+)
+
+// TestStore tests if reading from, writing to and deleting from the store works properly.
+func TestStore(t *testing.T) {
+	store, path := createStore(t, nil)
+	defer test.CleanUp(store, path)
+	test.Store(ctxconv.ToStore(store), t)
+}
+
+// TestBatch tests the batch methods of the fallback implementation.
+func TestBatch(t *testing.T) {
+	t.Run("default concurrency", func(t *testing.T) {
+		store, path := createStore(t, nil)
+		defer test.CleanUp(store, path)
+		test.BatchStore(store, t)
+	})
+
+	t.Run("no concurrency", func(t *testing.T) {
+		store, path := createStore(t, &batch.Options{MaxConcurrency: 1})
+		defer test.CleanUp(store, path)
+		test.BatchStore(store, t)
+	})
+}
+
+// TestCanceled tests if the batch methods fail with a context that's already canceled.
+func TestCanceled(t *testing.T) {
+	store, path := createStore(t, nil)
+	defer test.CleanUp(store, path)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	kvs := map[string]interface{}{"foo": "bar", "baz": "qux"}
+	if err := store.SetMany(ctx, kvs); err != context.Canceled {
+		t.Errorf("Expected %v, but was: %v", context.Canceled, err)
+	}
+	if _, err := store.GetMany(ctx, []string{"foo", "baz"}, func() interface{} { return new(string) }); err != context.Canceled {
+		t.Errorf("Expected %v, but was: %v", context.Canceled, err)
+	}
+	if err := store.DeleteMany(ctx, []string{"foo", "baz"}); err != context.Canceled {
+		t.Errorf("Expected %v, but was: %v", context.Canceled, err)
+	}
+
+	// Nothing was stored
+	for k := range kvs {
+		found, err := store.Get(context.Background(), k, new(string))
+		if err != nil || found {
+			t.Errorf("Expected %q not to be found, but was: %v, %v", k, found, err)
+		}
+	}
+}
+
+// TestNative tests if a store that already implements gokv.BatchStore is returned as is.
+func TestNative(t *testing.T) {
+	store, path := createStore(t, nil)
+	defer test.CleanUp(store, path)
+	if batch.NewStore(store, nil) != store {
+		t.Error("Expected the store to be returned unchanged")
+	}
+}
+
+// createStore creates a store that doesn't implement gokv.BatchStore natively.
+func createStore(t *testing.T, options *batch.Options) (gokv.BatchStore, string) {
+	inner, path := test.NewBboltStore(t, nil)
+	// The bbolt store implements gokv.BatchStore, so hide its batch methods.
+	inner = ctxconv.ToContextStore(ctxconv.ToStore(inner))
+	return batch.NewStore(inner, options), path
+}
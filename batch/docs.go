@@ -0,0 +1,8 @@
+/*
+Package batch contains a wrapper that adds the `gokv.BatchStore` methods to any `gokv.ContextStore`.
+
+Stores that implement `gokv.BatchStore` natively are used as they are.
+For all other stores the batch methods fan out over the single key methods
+with a bounded number of concurrent calls.
+*/
+package batch
@@ -0,0 +1,317 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/SpeedyCoder/gokv"
+	"github.com/SpeedyCoder/gokv/internal/iterator"
+)
+
+// State is the state of a circuit breaker.
+type State int
+
+const (
+	// Closed means operations are passed to the wrapped store.
+	Closed State = iota
+	// Open means operations fail right away or are served by the fallback store.
+	Open
+	// HalfOpen means a limited number of trial operations are passed to the wrapped store.
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// ErrOpen is returned when the breaker is open and there's no fallback store,
+// or for writes while the breaker is open.
+var ErrOpen = errors.New("the circuit breaker is open")
+
+// Options are the options for the circuit breaker.
+type Options struct {
+	// Ratio of failed operations within a window above which the breaker opens.
+	// Optional (0.5 by default).
+	FailureRatio float64
+	// Minimum number of operations within a window before the breaker can open,
+	// so that a single failure doesn't open it.
+	// Optional (20 by default).
+	MinRequests int
+	// Length of the window in which operations are counted.
+	// Optional (10s by default).
+	Window time.Duration
+	// Operations that take longer than this are counted as failed, even if they succeed.
+	// 0 means the latency isn't taken into account.
+	// Optional (0 by default).
+	SlowCallDuration time.Duration
+	// How long the breaker stays open before it becomes half-open.
+	// Also how long trial operations in the half-open state may take:
+	// If they didn't complete by then, the breaker opens again.
+	// Optional (30s by default).
+	OpenTimeout time.Duration
+	// Number of successful trial operations in the half-open state after which the breaker closes.
+	// Other operations in the half-open state are treated like in the open state.
+	// Optional (1 by default).
+	HalfOpenRequests int
+	// IsFailure decides which errors are counted as failures, e.g. retry.Transient.
+	// Errors that aren't failures count as successful operations.
+	// Operations that were canceled by the caller aren't counted at all.
+	// Optional (all errors by default).
+	IsFailure func(err error) bool
+	// Fallback serves Get and Keys while the breaker is open.
+	// Set and Delete aren't written to the fallback store, they fail with ErrOpen.
+	// Optional (nil by default).
+	Fallback gokv.ContextStore
+	// OnStateChange is called after each change of the state.
+	// It's called while the breaker is locked, so it must not use the store.
+	// Optional (nothing is done by default).
+	OnStateChange func(from, to State)
+}
+
+// Default values for the options.
+const (
+	DefaultFailureRatio     = 0.5
+	DefaultMinRequests      = 20
+	DefaultWindow           = 10 * time.Second
+	DefaultOpenTimeout      = 30 * time.Second
+	DefaultHalfOpenRequests = 1
+)
+
+// NewStore creates a new gokv.ContextStore that protects the given store with a circuit breaker.
+// Close() closes the wrapped store and the fallback store.
+func NewStore(store gokv.ContextStore, options *Options) gokv.ContextStore {
+	result := &breakerStore{
+		store: store,
+	}
+	if options != nil {
+		result.options = *options
+	}
+
+	// Set default values
+	if result.options.FailureRatio <= 0 {
+		result.options.FailureRatio = DefaultFailureRatio
+	}
+	if result.options.MinRequests <= 0 {
+		result.options.MinRequests = DefaultMinRequests
+	}
+	if result.options.Window <= 0 {
+		result.options.Window = DefaultWindow
+	}
+	if result.options.OpenTimeout <= 0 {
+		result.options.OpenTimeout = DefaultOpenTimeout
+	}
+	if result.options.HalfOpenRequests <= 0 {
+		result.options.HalfOpenRequests = DefaultHalfOpenRequests
+	}
+	if result.options.IsFailure == nil {
+		result.options.IsFailure = func(error) bool {
+			return true
+		}
+	}
+	if result.options.OnStateChange == nil {
+		result.options.OnStateChange = func(State, State) {}
+	}
+
+	result.windowStart = time.Now()
+	return result
+}
+
+type breakerStore struct {
+	store   gokv.ContextStore
+	options Options
+
+	lock        sync.Mutex
+	state       State
+	generation  uint64
+	windowStart time.Time
+	requests    int
+	failures    int
+	openedAt    time.Time
+	trials      int
+	successes   int
+	// trialAt is when the last trial operation in the half-open state was allowed.
+	trialAt time.Time
+}
+
+// allow reports whether an operation may be passed to the wrapped store.
+// The returned generation must be passed to done.
+func (s *breakerStore) allow() (generation uint64, ok bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := time.Now()
+	switch s.state {
+	case Open:
+		if now.Sub(s.openedAt) < s.options.OpenTimeout {
+			return 0, false
+		}
+		s.setState(HalfOpen, now)
+		fallthrough
+	case HalfOpen:
+		if s.trials >= s.options.HalfOpenRequests {
+			// Trials that don't complete would keep the breaker half-open forever
+			if now.Sub(s.trialAt) >= s.options.OpenTimeout {
+				s.setState(Open, now)
+			}
+			return 0, false
+		}
+		s.trials++
+		s.trialAt = now
+	default:
+		if now.Sub(s.windowStart) >= s.options.Window {
+			s.windowStart = now
+			s.requests = 0
+			s.failures = 0
+		}
+	}
+	return s.generation, true
+}
+
+// done records the outcome of an operation that was allowed.
+// Outcomes of operations that were allowed before the last state change are ignored.
+// Operations that were canceled by the caller say nothing about the wrapped store,
+// so they're ignored as well, but free their trial slot in the half-open state.
+func (s *breakerStore) done(ctx context.Context, generation uint64, start time.Time, err error) {
+	canceled := err != nil && (err == context.Canceled || ctx.Err() == context.Canceled)
+	failed := (err != nil && s.options.IsFailure(err)) ||
+		(s.options.SlowCallDuration > 0 && time.Since(start) > s.options.SlowCallDuration)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if generation != s.generation {
+		return
+	}
+	if canceled {
+		if s.state == HalfOpen {
+			s.trials--
+		}
+		return
+	}
+
+	now := time.Now()
+	switch s.state {
+	case HalfOpen:
+		if failed {
+			s.setState(Open, now)
+			return
+		}
+		s.successes++
+		if s.successes >= s.options.HalfOpenRequests {
+			s.setState(Closed, now)
+		}
+	case Closed:
+		s.requests++
+		if failed {
+			s.failures++
+		}
+		if s.requests >= s.options.MinRequests &&
+			float64(s.failures)/float64(s.requests) > s.options.FailureRatio {
+			s.setState(Open, now)
+		}
+	}
+}
+
+// setState changes the state and resets the counters. The lock must be held.
+func (s *breakerStore) setState(state State, now time.Time) {
+	from := s.state
+	s.state = state
+	s.generation++
+	s.windowStart = now
+	s.requests = 0
+	s.failures = 0
+	s.trials = 0
+	s.successes = 0
+	if state == Open {
+		s.openedAt = now
+	}
+	s.options.OnStateChange(from, state)
+}
+
+// Set stores the given value for the given key.
+// It fails with ErrOpen while the breaker is open.
+// The key must not be "" and the value must not be nil.
+func (s *breakerStore) Set(ctx context.Context, k string, v interface{}) error {
+	generation, ok := s.allow()
+	if !ok {
+		return ErrOpen
+	}
+	start := time.Now()
+	err := s.store.Set(ctx, k, v)
+	s.done(ctx, generation, start, err)
+	return err
+}
+
+// Get retrieves the stored value for the given key.
+// While the breaker is open the value is retrieved from the fallback store,
+// or it fails with ErrOpen if there's none.
+// If no value is found it returns (false, nil).
+// The key must not be "" and the pointer must not be nil.
+func (s *breakerStore) Get(ctx context.Context, k string, v interface{}) (found bool, err error) {
+	generation, ok := s.allow()
+	if !ok {
+		if s.options.Fallback == nil {
+			return false, ErrOpen
+		}
+		return s.options.Fallback.Get(ctx, k, v)
+	}
+	start := time.Now()
+	found, err = s.store.Get(ctx, k, v)
+	s.done(ctx, generation, start, err)
+	return found, err
+}
+
+// Delete deletes the stored value for the given key.
+// It fails with ErrOpen while the breaker is open.
+// Deleting a non-existing key-value pair does NOT lead to an error.
+// The key must not be "".
+func (s *breakerStore) Delete(ctx context.Context, k string) error {
+	generation, ok := s.allow()
+	if !ok {
+		return ErrOpen
+	}
+	start := time.Now()
+	err := s.store.Delete(ctx, k)
+	s.done(ctx, generation, start, err)
+	return err
+}
+
+// Keys returns an iterator over all keys in the store.
+// While the breaker is open the keys of the fallback store are returned,
+// or the iterator fails with ErrOpen if there's none.
+// The outcome of the iteration is counted when it's done, its latency isn't taken into account.
+func (s *breakerStore) Keys(ctx context.Context) gokv.KeysIterator {
+	generation, ok := s.allow()
+	if !ok {
+		if s.options.Fallback == nil {
+			it := iterator.New(ctx)
+			it.Close(ErrOpen)
+			return it
+		}
+		return s.options.Fallback.Keys(ctx)
+	}
+	return iterator.Observe(ctx, s.store.Keys(ctx), func(_ int, err error) {
+		// Iterations usually take a long time, so they are never slow
+		s.done(ctx, generation, time.Now(), err)
+	})
+}
+
+// Close closes the wrapped store and the fallback store.
+func (s *breakerStore) Close() error {
+	err := s.store.Close()
+	if s.options.Fallback != nil {
+		if fallbackErr := s.options.Fallback.Close(); err == nil {
+			err = fallbackErr
+		}
+	}
+	return err
+}
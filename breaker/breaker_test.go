@@ -0,0 +1,205 @@
+package breaker_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/SpeedyCoder/gokv"
+	"github.com/SpeedyCoder/gokv/breaker"
+	"github.com/SpeedyCoder/gokv/internal/ctxconv"
+	"github.com/SpeedyCoder/gokv/internal/test"
+)
+
+// TestStore tests if reading from, writing to and deleting from the store works properly.
+func TestStore(t *testing.T) {
+	store, path := test.NewBboltStore(t, nil)
+	defer test.CleanUp(store, path)
+
+	test.Store(ctxconv.ToStore(breaker.NewStore(store, nil)), t)
+}
+
+// TestStates tests the transitions between the states and the fallback store.
+func TestStates(t *testing.T) {
+	assert := require.New(t)
+	inner, path := test.NewBboltStore(t, nil)
+	defer os.RemoveAll(path)
+	fallback, fallbackPath := test.NewBboltStore(t, nil)
+	defer os.RemoveAll(fallbackPath)
+	failing := &failingStore{ContextStore: inner}
+	var changes []string
+	var changesLock sync.Mutex
+	store := breaker.NewStore(failing, &breaker.Options{
+		MinRequests: 4,
+		OpenTimeout: 50 * time.Millisecond,
+		Fallback:    fallback,
+		OnStateChange: func(from, to breaker.State) {
+			changesLock.Lock()
+			defer changesLock.Unlock()
+			changes = append(changes, from.String()+"->"+to.String())
+		},
+	})
+	defer store.Close()
+	ctx := context.Background()
+	assert.NoError(store.Set(ctx, "foo", test.Foo{Bar: "primary"}))
+	assert.NoError(fallback.Set(ctx, "foo", test.Foo{Bar: "fallback"}))
+
+	// 3 of 4 operations fail, so the breaker opens
+	failing.setErr(errors.New("unavailable"))
+	for i := 0; i < 3; i++ {
+		_, err := store.Get(ctx, "foo", new(test.Foo))
+		assert.Error(err)
+	}
+	assert.Equal([]string{"closed->open"}, changes)
+
+	// Open: writes fail and reads are served by the fallback store
+	assert.Equal(breaker.ErrOpen, store.Set(ctx, "foo", test.Foo{Bar: "baz"}))
+	assert.Equal(breaker.ErrOpen, store.Delete(ctx, "foo"))
+	actual := new(test.Foo)
+	found, err := store.Get(ctx, "foo", actual)
+	assert.NoError(err)
+	assert.True(found)
+	assert.Equal("fallback", actual.Bar)
+
+	// Half-open: a failed trial opens the breaker again
+	time.Sleep(60 * time.Millisecond)
+	_, err = store.Get(ctx, "foo", new(test.Foo))
+	assert.EqualError(err, "unavailable")
+	assert.Equal([]string{"closed->open", "open->half-open", "half-open->open"}, changes)
+
+	// Half-open: a successful trial closes the breaker
+	time.Sleep(60 * time.Millisecond)
+	failing.setErr(nil)
+	found, err = store.Get(ctx, "foo", actual)
+	assert.NoError(err)
+	assert.True(found)
+	assert.Equal("primary", actual.Bar)
+	assert.Equal([]string{"closed->open", "open->half-open", "half-open->open", "open->half-open", "half-open->closed"}, changes)
+}
+
+// TestHalfOpenTrials tests if trials that don't complete open the breaker again
+// and if canceled trials are ignored.
+func TestHalfOpenTrials(t *testing.T) {
+	assert := require.New(t)
+	inner, path := test.NewBboltStore(t, nil)
+	defer test.CleanUp(inner, path)
+	failing := &failingStore{ContextStore: inner, err: errors.New("unavailable")}
+	var changes []string
+	var changesLock sync.Mutex
+	store := breaker.NewStore(failing, &breaker.Options{
+		MinRequests: 1,
+		OpenTimeout: 50 * time.Millisecond,
+		OnStateChange: func(from, to breaker.State) {
+			changesLock.Lock()
+			defer changesLock.Unlock()
+			changes = append(changes, from.String()+"->"+to.String())
+		},
+	})
+	ctx := context.Background()
+	assert.NoError(inner.Set(ctx, "foo", "bar"))
+	assert.Error(store.Set(ctx, "foo", "bar"))
+	failing.setErr(nil)
+
+	// The trial is an iteration that's never finished
+	time.Sleep(60 * time.Millisecond)
+	iterCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	store.Keys(iterCtx)
+	assert.Equal(breaker.ErrOpen, store.Set(ctx, "foo", "bar"))
+	time.Sleep(60 * time.Millisecond)
+	assert.Equal(breaker.ErrOpen, store.Set(ctx, "foo", "bar"))
+	assert.Equal([]string{"closed->open", "open->half-open", "half-open->open"}, changes)
+
+	// A canceled trial neither closes nor opens the breaker and frees its slot
+	time.Sleep(60 * time.Millisecond)
+	canceledCtx, cancelTrial := context.WithCancel(ctx)
+	cancelTrial()
+	assert.Equal(context.Canceled, store.Set(canceledCtx, "foo", "bar"))
+	assert.Equal([]string{"closed->open", "open->half-open", "half-open->open", "open->half-open"}, changes)
+	assert.NoError(store.Set(ctx, "foo", "bar"))
+	assert.Equal([]string{"closed->open", "open->half-open", "half-open->open", "open->half-open", "half-open->closed"}, changes)
+}
+
+// TestSlowCalls tests if slow operations open the breaker.
+func TestSlowCalls(t *testing.T) {
+	assert := require.New(t)
+	inner, path := test.NewBboltStore(t, nil)
+	defer test.CleanUp(inner, path)
+	failing := &failingStore{ContextStore: inner, delay: 10 * time.Millisecond}
+	store := breaker.NewStore(failing, &breaker.Options{
+		MinRequests:      2,
+		SlowCallDuration: 5 * time.Millisecond,
+	})
+	ctx := context.Background()
+
+	assert.NoError(store.Set(ctx, "foo", "bar"))
+	assert.NoError(store.Set(ctx, "foo", "bar"))
+	assert.Equal(breaker.ErrOpen, store.Set(ctx, "foo", "bar"))
+	_, err := store.Get(ctx, "foo", new(string))
+	assert.Equal(breaker.ErrOpen, err)
+	it := store.Keys(ctx)
+	for range it.Ch() {
+	}
+	assert.Equal(breaker.ErrOpen, it.Err())
+}
+
+// TestIsFailure tests if errors that aren't failures don't open the breaker.
+func TestIsFailure(t *testing.T) {
+	assert := require.New(t)
+	inner, path := test.NewBboltStore(t, nil)
+	defer test.CleanUp(inner, path)
+	failing := &failingStore{ContextStore: inner, err: errors.New("invalid")}
+	store := breaker.NewStore(failing, &breaker.Options{
+		MinRequests: 1,
+		IsFailure: func(err error) bool {
+			return err.Error() != "invalid"
+		},
+	})
+
+	for i := 0; i < 10; i++ {
+		assert.EqualError(store.Set(context.Background(), "foo", "bar"), "invalid")
+	}
+}
+
+// failingStore fails all Set and Get calls with the given error and delays them.
+type failingStore struct {
+	gokv.ContextStore
+	lock  sync.Mutex
+	err   error
+	delay time.Duration
+}
+
+func (s *failingStore) setErr(err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.err = err
+}
+
+func (s *failingStore) next() error {
+	time.Sleep(s.delay)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.err
+}
+
+func (s *failingStore) Set(ctx context.Context, k string, v interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := s.next(); err != nil {
+		return err
+	}
+	return s.ContextStore.Set(ctx, k, v)
+}
+
+func (s *failingStore) Get(ctx context.Context, k string, v interface{}) (bool, error) {
+	if err := s.next(); err != nil {
+		return false, err
+	}
+	return s.ContextStore.Get(ctx, k, v)
+}
@@ -0,0 +1,13 @@
+/*
+Package breaker contains a circuit breaker wrapper for a `gokv.ContextStore`.
+
+The breaker starts closed and passes all operations to the wrapped store.
+When the ratio of failed or slow operations within a window exceeds a threshold it opens
+and operations fail right away with `breaker.ErrOpen` instead of waiting for a degraded backend,
+or are served by a fallback store if one is configured, e.g. a local `bbolt` snapshot.
+After a timeout the breaker becomes half-open and lets a few trial operations through:
+If they succeed it closes again, otherwise it opens again.
+
+State changes are passed to a callback, e.g. for alerting.
+*/
+package breaker
@@ -0,0 +1,591 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/SpeedyCoder/gokv"
+	"github.com/SpeedyCoder/gokv/encoding"
+	"github.com/SpeedyCoder/gokv/expiry"
+	"github.com/SpeedyCoder/gokv/internal/check"
+	"github.com/SpeedyCoder/gokv/internal/iterator"
+)
+
+// Mode determines how writes are propagated to the two stores.
+type Mode int
+
+const (
+	// WriteThrough writes to the durable store first and then to the fast store.
+	WriteThrough Mode = iota
+	// ReadThrough writes only to the durable store and invalidates the value in the fast store.
+	// The fast store is only populated by reads.
+	ReadThrough
+	// WriteBehind writes to the fast store and queues the write to the durable store,
+	// which is executed asynchronously. Writes for the same key are coalesced.
+	// Values must not be modified after they were passed to Set,
+	// because they're written to the durable store asynchronously.
+	// Until then Get serves copies of them, which are made with Options.Codec.
+	// Errors of the asynchronous writes are passed to Options.ErrorHandler.
+	WriteBehind
+)
+
+// Options are the options for the cache store.
+type Options struct {
+	// How writes are handled.
+	// Optional (WriteThrough by default).
+	Mode Mode
+	// Time-to-live of the values in the fast store. 0 means they don't expire.
+	// If the fast store doesn't implement gokv.ExpiringStore it's wrapped with the expiry package.
+	// Optional (0 by default).
+	TTL time.Duration
+	// How long it's remembered that a key wasn't found in the durable store,
+	// so that repeated reads of missing keys don't hit the durable store.
+	// These entries are kept in memory of the current process. 0 disables negative caching.
+	// Optional (0 by default).
+	NegativeTTL time.Duration
+	// Maximum number of keys with pending writes in WriteBehind mode.
+	// When it's reached Set and Delete block until there's space again.
+	// Optional (1000 by default).
+	QueueSize int
+	// ErrorHandler is called for errors that can't be returned to the caller,
+	// e.g. errors of asynchronous writes in WriteBehind mode
+	// or errors when populating the fast store during a read.
+	// Optional (errors are ignored by default).
+	ErrorHandler func(k string, err error)
+	// Encoding format that's used in WriteBehind mode to copy the values of pending writes
+	// into the values that are passed to Get, which should be the one of the durable store.
+	// Optional (encoding.JSON by default).
+	Codec encoding.Encoding
+}
+
+const (
+	DefaultQueueSize = 1000
+)
+
+var (
+	// errClosed is returned when writing to a store that's already closed.
+	errClosed = errors.New("the store is closed")
+	// errNoPointer is returned when the value that's passed to Get isn't a pointer.
+	errNoPointer = errors.New("the value must be a pointer")
+)
+
+// negativeSweepSize is the number of negative cache entries above which expired ones are removed.
+const negativeSweepSize = 10000
+
+// writeSeqStripes is the number of write sequence numbers, see cacheStore.writeSeqs.
+const writeSeqStripes = 256
+
+// NewStore creates a new gokv.ContextStore that caches the values of the durable store in the fast store.
+// Both stores are closed when the returned store is closed.
+func NewStore(fast, durable gokv.ContextStore, options *Options) gokv.ContextStore {
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+
+	// Set default values
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = DefaultQueueSize
+	}
+	if opts.ErrorHandler == nil {
+		opts.ErrorHandler = func(string, error) {}
+	}
+	if opts.Codec == nil {
+		opts.Codec = encoding.JSON
+	}
+
+	s := &cacheStore{
+		fast:         fast,
+		durable:      durable,
+		mode:         opts.Mode,
+		ttl:          opts.TTL,
+		negativeTTL:  opts.NegativeTTL,
+		errorHandler: opts.ErrorHandler,
+		codec:        opts.Codec,
+		negative:     make(map[string]time.Time),
+	}
+	if opts.TTL > 0 {
+		s.expiringFast = expiry.NewStore(fast, nil)
+		s.fast = s.expiringFast
+	}
+	if opts.Mode == WriteBehind {
+		s.queue = make(chan string, opts.QueueSize)
+		s.pending = make(map[string]pendingWrite)
+		s.inFlight = make(map[string]pendingWrite)
+		s.flushed = make(chan struct{})
+		s.closing = make(chan struct{})
+		go s.writeBehind()
+	}
+	return s
+}
+
+type cacheStore struct {
+	fast         gokv.ContextStore
+	expiringFast gokv.ExpiringStore
+	durable      gokv.ContextStore
+	mode         Mode
+	ttl          time.Duration
+	negativeTTL  time.Duration
+	errorHandler func(k string, err error)
+	codec        encoding.Encoding
+
+	negativeLock sync.Mutex
+	negative     map[string]time.Time
+
+	// refillLock protects writeSeqs. It's never held during calls to the fast or the durable store.
+	refillLock sync.Mutex
+	// writeSeqs is incremented after each write to the durable store, for the stripe of the key.
+	// A read only refills the fast store if the number didn't change since the read started,
+	// otherwise it could put an outdated value or miss into the fast store after the write invalidated it.
+	// If the number changes while the fast store is refilled, the value is removed from the fast store again.
+	// Keys share a number with the other keys of their stripe, so that it doesn't grow with the number of keys,
+	// which only causes a refill to be skipped unnecessarily now and then.
+	writeSeqs [writeSeqStripes]uint64
+
+	// Only used in WriteBehind mode.
+	// The queue contains each key with a pending write once,
+	// the latest write for the key is in pending.
+	pendingLock sync.Mutex
+	pending     map[string]pendingWrite
+	// inFlight contains the writes that were taken from the queue and are being executed,
+	// so they're still served by Get until the durable store has them.
+	inFlight map[string]pendingWrite
+	seq      uint64
+	queue    chan string
+	flushed  chan struct{}
+	// closing is closed when Close is called, so sends to a full queue can give up,
+	// see enqueue().
+	closing   chan struct{}
+	closeOnce sync.Once
+	// closeLock is held for reading while sending to the queue,
+	// so the queue isn't closed during a send.
+	closeLock sync.RWMutex
+	closed    bool
+}
+
+type pendingWrite struct {
+	v      interface{}
+	delete bool
+	// seq identifies the write, see enqueue().
+	seq uint64
+}
+
+// get stores a copy of the value of the write in v, which must be a pointer.
+// The value is copied by marshalling and unmarshalling it with the given codec,
+// so v doesn't share any data with the value that's going to be written to the durable store,
+// and it's converted like it would be when it's read from the durable store.
+func (w pendingWrite) get(v interface{}, codec encoding.Encoding) (found bool, err error) {
+	if w.delete {
+		return false, nil
+	}
+	if reflect.ValueOf(v).Kind() != reflect.Ptr {
+		return false, errNoPointer
+	}
+	data, err := codec.Marshal(w.v)
+	if err != nil {
+		return false, err
+	}
+	return true, codec.Unmarshal(data, v)
+}
+
+// Set stores the given value for the given key according to the configured Mode.
+// The key must not be "" and the value must not be nil.
+func (s *cacheStore) Set(ctx context.Context, k string, v interface{}) error {
+	if err := check.KeyAndValue(k, v); err != nil {
+		return err
+	}
+
+	switch s.mode {
+	case ReadThrough:
+		if err := s.durable.Set(ctx, k, v); err != nil {
+			return err
+		}
+		s.invalidate(k)
+		return s.fast.Delete(ctx, k)
+	case WriteBehind:
+		// Enqueue first, so a concurrent Get can't put the old value from the durable store
+		// into the fast store after the new value was written to it, see refill().
+		if err := s.enqueue(ctx, k, pendingWrite{v: v}); err != nil {
+			return err
+		}
+		return s.writeFast(ctx, k, v)
+	default:
+		if err := s.durable.Set(ctx, k, v); err != nil {
+			return err
+		}
+		return s.writeFast(ctx, k, v)
+	}
+}
+
+// Get retrieves the stored value for the given key from the fast store,
+// or from the durable store if it's not in the fast store.
+// In the latter case the value is stored in the fast store.
+// In WriteBehind mode a pending write for the key is served instead of the value of the durable store.
+// The key must not be "" and the pointer must not be nil.
+func (s *cacheStore) Get(ctx context.Context, k string, v interface{}) (found bool, err error) {
+	if err := check.KeyAndValue(k, v); err != nil {
+		return false, err
+	}
+
+	found, err = s.fast.Get(ctx, k, v)
+	if err != nil || found {
+		return found, err
+	}
+
+	// The sequence number must be read before checking for a pending write,
+	// otherwise a write could be enqueued and executed in between without being noticed by refill().
+	seq := s.writeSeq(k)
+	if w, ok := s.pendingWrite(k); ok {
+		return w.get(v, s.codec)
+	}
+	if s.isMissing(k) {
+		return false, nil
+	}
+
+	found, err = s.durable.Get(ctx, k, v)
+	if err != nil {
+		return false, err
+	}
+	return s.refill(ctx, k, v, found, seq)
+}
+
+// refill stores the value that was read from the durable store in the fast store,
+// or remembers that it's missing.
+// In WriteBehind mode the durable store can be outdated, so if a write for the key
+// was enqueued in the meantime, that write is served and the fast store isn't touched.
+// Otherwise the fast store isn't touched if the key was written since the read started,
+// i.e. if its write sequence number isn't seq anymore. The value that was read is returned nonetheless.
+// No lock is held while writing to the fast store, so a write of the key can happen at the same time.
+// Set and Delete increment the number before changing the fast store, so if the number changed
+// after the value was written to the fast store, it might have overwritten a newer value and is deleted again.
+func (s *cacheStore) refill(ctx context.Context, k string, v interface{}, found bool, seq uint64) (bool, error) {
+	if w, ok := s.pendingWrite(k); ok {
+		return w.get(v, s.codec)
+	}
+
+	if !found {
+		// Remembering the miss is cheap, so it's done while the number can't change.
+		s.refillLock.Lock()
+		defer s.refillLock.Unlock()
+		if s.writeSeqs[stripe(k)] == seq {
+			s.rememberMissing(k)
+		}
+		return false, nil
+	}
+
+	if s.writeSeq(k) != seq {
+		return true, nil
+	}
+	if err := s.setFast(ctx, k, v); err != nil {
+		s.errorHandler(k, err)
+		return true, nil
+	}
+	if s.writeSeq(k) != seq {
+		if err := s.fast.Delete(ctx, k); err != nil {
+			s.errorHandler(k, err)
+		}
+	}
+	return true, nil
+}
+
+// Delete deletes the stored value for the given key from both stores.
+// In WriteBehind mode the deletion from the durable store is queued.
+// Deleting a non-existing key-value pair does NOT lead to an error.
+// The key must not be "".
+func (s *cacheStore) Delete(ctx context.Context, k string) error {
+	if err := check.Key(k); err != nil {
+		return err
+	}
+
+	if s.mode == WriteBehind {
+		// Enqueue first for the same reason as in Set
+		if err := s.enqueue(ctx, k, pendingWrite{delete: true}); err != nil {
+			return err
+		}
+		s.invalidate(k)
+		return s.fast.Delete(ctx, k)
+	}
+
+	if err := s.durable.Delete(ctx, k); err != nil {
+		return err
+	}
+	s.invalidate(k)
+	return s.fast.Delete(ctx, k)
+}
+
+// Keys returns an iterator over all keys of the durable store.
+// In WriteBehind mode the keys with pending writes are taken into account as well.
+func (s *cacheStore) Keys(ctx context.Context) gokv.KeysIterator {
+	if s.mode != WriteBehind {
+		return s.durable.Keys(ctx)
+	}
+
+	// Whether the pending write for a key is a deletion
+	s.pendingLock.Lock()
+	pending := make(map[string]bool, len(s.pending)+len(s.inFlight))
+	for k, w := range s.inFlight {
+		pending[k] = w.delete
+	}
+	for k, w := range s.pending {
+		pending[k] = w.delete
+	}
+	s.pendingLock.Unlock()
+
+	it := iterator.New(ctx)
+	go func() {
+		for k, isDelete := range pending {
+			if isDelete {
+				continue
+			}
+			if err := it.Write(k); err != nil {
+				it.Close(err)
+				return
+			}
+		}
+
+		keys := s.durable.Keys(ctx)
+		for k := range keys.Ch() {
+			if _, ok := pending[k]; ok {
+				continue
+			}
+			if err := it.Write(k); err != nil {
+				// Drain the keys so the iterator doesn't block forever.
+				go func() {
+					for range keys.Ch() {
+					}
+				}()
+				it.Close(err)
+				return
+			}
+		}
+		it.Close(keys.Err())
+	}()
+	return it
+}
+
+// Close executes all pending writes and closes both stores.
+func (s *cacheStore) Close() error {
+	if s.mode == WriteBehind {
+		s.closeOnce.Do(func() {
+			close(s.closing)
+		})
+		s.closeLock.Lock()
+		if !s.closed {
+			s.closed = true
+			close(s.queue)
+		}
+		s.closeLock.Unlock()
+		<-s.flushed
+	}
+
+	fastErr := s.fast.Close()
+	if err := s.durable.Close(); err != nil {
+		return err
+	}
+	return fastErr
+}
+
+// writeFast invalidates the given key and stores v in the fast store, after v was written to the durable store,
+// or queued for it.
+// A concurrent write of the same key can invalidate it after this one, but write to the fast store before it,
+// so if the write sequence number changed after the fast store was written, the value is deleted from it again,
+// like in refill().
+func (s *cacheStore) writeFast(ctx context.Context, k string, v interface{}) error {
+	seq := s.invalidate(k)
+	if err := s.setFast(ctx, k, v); err != nil {
+		return err
+	}
+	if s.writeSeq(k) != seq {
+		return s.fast.Delete(ctx, k)
+	}
+	return nil
+}
+
+func (s *cacheStore) setFast(ctx context.Context, k string, v interface{}) error {
+	if s.expiringFast != nil {
+		return s.expiringFast.SetWithTTL(ctx, k, v, s.ttl)
+	}
+	return s.fast.Set(ctx, k, v)
+}
+
+func (s *cacheStore) enqueue(ctx context.Context, k string, w pendingWrite) error {
+	s.closeLock.RLock()
+	defer s.closeLock.RUnlock()
+	if s.closed {
+		return errClosed
+	}
+
+	s.pendingLock.Lock()
+	_, queued := s.pending[k]
+	s.seq++
+	w.seq = s.seq
+	s.pending[k] = w
+	s.pendingLock.Unlock()
+	if queued {
+		// The key is already in the queue, the worker picks up the latest write.
+		return nil
+	}
+
+	select {
+	case s.queue <- k:
+		return nil
+	case <-s.closing:
+		// The write stays pending, writeBehind() executes it after the queue was closed.
+		return nil
+	case <-ctx.Done():
+	}
+
+	s.pendingLock.Lock()
+	latest := s.pending[k]
+	if latest.seq == w.seq {
+		delete(s.pending, k)
+		s.pendingLock.Unlock()
+		return ctx.Err()
+	}
+	s.pendingLock.Unlock()
+	// A newer write for the key was coalesced into this one in the meantime,
+	// so the key must be queued nonetheless. Its caller has already returned,
+	// so only Close can interrupt this.
+	select {
+	case s.queue <- k:
+	case <-s.closing:
+	}
+	return nil
+}
+
+// writeBehind executes the queued writes until the queue is closed.
+// Then it executes the writes that are still pending because Close interrupted their enqueue().
+func (s *cacheStore) writeBehind() {
+	defer close(s.flushed)
+	ctx := context.Background()
+	for k := range s.queue {
+		s.flush(ctx, k)
+	}
+
+	// Once the queue is closed no writes are enqueued anymore.
+	s.pendingLock.Lock()
+	keys := make([]string, 0, len(s.pending))
+	for k := range s.pending {
+		keys = append(keys, k)
+	}
+	s.pendingLock.Unlock()
+	for _, k := range keys {
+		s.flush(ctx, k)
+	}
+}
+
+// flush executes the pending write for the given key, if there is one.
+func (s *cacheStore) flush(ctx context.Context, k string) {
+	s.pendingLock.Lock()
+	w, ok := s.pending[k]
+	delete(s.pending, k)
+	if ok {
+		s.inFlight[k] = w
+	}
+	s.pendingLock.Unlock()
+	if !ok {
+		return
+	}
+
+	var err error
+	if w.delete {
+		err = s.durable.Delete(ctx, k)
+	} else {
+		err = s.durable.Set(ctx, k, w.v)
+	}
+	if err != nil {
+		s.errorHandler(k, err)
+	}
+
+	s.pendingLock.Lock()
+	delete(s.inFlight, k)
+	s.pendingLock.Unlock()
+}
+
+// pendingWrite returns the latest write for the given key that isn't in the durable store yet.
+func (s *cacheStore) pendingWrite(k string) (pendingWrite, bool) {
+	if s.mode != WriteBehind {
+		return pendingWrite{}, false
+	}
+	s.pendingLock.Lock()
+	defer s.pendingLock.Unlock()
+	if w, ok := s.pending[k]; ok {
+		return w, true
+	}
+	w, ok := s.inFlight[k]
+	return w, ok
+}
+
+// writeSeq returns the write sequence number of the given key, see cacheStore.writeSeqs.
+func (s *cacheStore) writeSeq(k string) uint64 {
+	s.refillLock.Lock()
+	defer s.refillLock.Unlock()
+	return s.writeSeqs[stripe(k)]
+}
+
+// invalidate must be called after the given key was written to the durable store, or queued for it,
+// and before the fast store is changed.
+// It increments the write sequence number of the key, so that reads that started before don't refill the fast store,
+// and forgets that the key is missing.
+// It returns the new write sequence number of the key.
+func (s *cacheStore) invalidate(k string) uint64 {
+	s.refillLock.Lock()
+	defer s.refillLock.Unlock()
+	s.writeSeqs[stripe(k)]++
+	s.forgetMissing(k)
+	return s.writeSeqs[stripe(k)]
+}
+
+// stripe returns the index of the write sequence number of the given key.
+func stripe(k string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(k))
+	return int(h.Sum32() % writeSeqStripes)
+}
+
+func (s *cacheStore) isMissing(k string) bool {
+	if s.negativeTTL <= 0 {
+		return false
+	}
+	s.negativeLock.Lock()
+	defer s.negativeLock.Unlock()
+	expiresAt, ok := s.negative[k]
+	if !ok {
+		return false
+	}
+	if time.Now().After(expiresAt) {
+		delete(s.negative, k)
+		return false
+	}
+	return true
+}
+
+func (s *cacheStore) rememberMissing(k string) {
+	if s.negativeTTL <= 0 {
+		return
+	}
+	s.negativeLock.Lock()
+	defer s.negativeLock.Unlock()
+	now := time.Now()
+	if len(s.negative) >= negativeSweepSize {
+		for key, expiresAt := range s.negative {
+			if now.After(expiresAt) {
+				delete(s.negative, key)
+			}
+		}
+	}
+	s.negative[k] = now.Add(s.negativeTTL)
+}
+
+func (s *cacheStore) forgetMissing(k string) {
+	if s.negativeTTL <= 0 {
+		return
+	}
+	s.negativeLock.Lock()
+	defer s.negativeLock.Unlock()
+	delete(s.negative, k)
+}
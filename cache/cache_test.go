@@ -0,0 +1,510 @@
+package cache_test
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/SpeedyCoder/gokv"
+	"github.com/SpeedyCoder/gokv/backends/bbolt"
+	"github.com/SpeedyCoder/gokv/cache"
+	"github.com/SpeedyCoder/gokv/internal/ctxconv"
+	"github.com/SpeedyCoder/gokv/internal/test"
+)
+
+// TestStore tests if reading from, writing to and deleting from the store works properly in all modes.
+func TestStore(t *testing.T) {
+	modes := map[string]cache.Mode{
+		"write-through": cache.WriteThrough,
+		"read-through":  cache.ReadThrough,
+		"write-behind":  cache.WriteBehind,
+	}
+	for name, mode := range modes {
+		t.Run(name, func(t *testing.T) {
+			store, _, _, paths := createStore(t, &cache.Options{Mode: mode})
+			defer test.CleanUp(store, paths...)
+			test.Store(ctxconv.ToStore(store), t)
+			test.Types(ctxconv.ToStore(store), t)
+		})
+	}
+}
+
+// TestStoreConcurrent launches a bunch of goroutines that concurrently work with one store.
+func TestStoreConcurrent(t *testing.T) {
+	store, _, _, paths := createStore(t, &cache.Options{Mode: cache.WriteBehind})
+	defer test.CleanUp(store, paths...)
+
+	goroutineCount := 1000
+
+	test.ConcurrentInteractions(t, goroutineCount, ctxconv.ToStore(store))
+}
+
+// TestReadThrough tests if values are read from the durable store and cached in the fast store.
+func TestReadThrough(t *testing.T) {
+	assert := require.New(t)
+	ctx := context.Background()
+	store, fast, durable, paths := createStore(t, &cache.Options{Mode: cache.ReadThrough})
+	defer test.CleanUp(store, paths...)
+
+	err := store.Set(ctx, "foo", test.Foo{Bar: "baz"})
+	assert.NoError(err)
+	// In ReadThrough mode writes don't populate the fast store
+	found, err := fast.Get(ctx, "foo", new(test.Foo))
+	assert.NoError(err)
+	assert.False(found, "A value was found, but no value was expected")
+
+	// Reads do
+	actual := new(test.Foo)
+	found, err = store.Get(ctx, "foo", actual)
+	assert.NoError(err)
+	assert.True(found, "No value was found, but should have been")
+	assert.Equal(test.Foo{Bar: "baz"}, *actual)
+	found, err = fast.Get(ctx, "foo", new(test.Foo))
+	assert.NoError(err)
+	assert.True(found, "No value was found, but should have been")
+
+	// Now the value is served from the fast store
+	err = durable.Delete(ctx, "foo")
+	assert.NoError(err)
+	found, err = store.Get(ctx, "foo", actual)
+	assert.NoError(err)
+	assert.True(found, "No value was found, but should have been")
+
+	// Writes invalidate the fast store
+	err = store.Set(ctx, "foo", test.Foo{Bar: "qux"})
+	assert.NoError(err)
+	found, err = store.Get(ctx, "foo", actual)
+	assert.NoError(err)
+	assert.True(found, "No value was found, but should have been")
+	assert.Equal(test.Foo{Bar: "qux"}, *actual)
+
+	// Deletes invalidate the fast store
+	err = store.Delete(ctx, "foo")
+	assert.NoError(err)
+	found, err = fast.Get(ctx, "foo", new(test.Foo))
+	assert.NoError(err)
+	assert.False(found, "A value was found, but no value was expected")
+}
+
+// TestNegativeCaching tests if missing keys are remembered for the configured time.
+func TestNegativeCaching(t *testing.T) {
+	assert := require.New(t)
+	ctx := context.Background()
+	options := cache.Options{
+		NegativeTTL: 500 * time.Millisecond,
+	}
+	store, _, durable, paths := createStore(t, &options)
+	defer test.CleanUp(store, paths...)
+
+	found, err := store.Get(ctx, "foo", new(test.Foo))
+	assert.NoError(err)
+	assert.False(found, "A value was found, but no value was expected")
+
+	// Written directly to the durable store, so the cache doesn't know about it
+	err = durable.Set(ctx, "foo", test.Foo{Bar: "baz"})
+	assert.NoError(err)
+	found, err = store.Get(ctx, "foo", new(test.Foo))
+	assert.NoError(err)
+	assert.False(found, "A value was found, but no value was expected")
+
+	time.Sleep(time.Second)
+	found, err = store.Get(ctx, "foo", new(test.Foo))
+	assert.NoError(err)
+	assert.True(found, "No value was found, but should have been")
+
+	// Writes via the cache reset the negative cache immediately
+	found, err = store.Get(ctx, "bar", new(test.Foo))
+	assert.NoError(err)
+	assert.False(found, "A value was found, but no value was expected")
+	err = store.Set(ctx, "bar", test.Foo{Bar: "baz"})
+	assert.NoError(err)
+	found, err = store.Get(ctx, "bar", new(test.Foo))
+	assert.NoError(err)
+	assert.True(found, "No value was found, but should have been")
+}
+
+// TestConcurrentRefill tests if a read that started before a write doesn't put its outdated value
+// or a cached miss into the fast store after the write.
+func TestConcurrentRefill(t *testing.T) {
+	modes := map[string]cache.Mode{
+		"write-through": cache.WriteThrough,
+		"read-through":  cache.ReadThrough,
+	}
+	for name, mode := range modes {
+		t.Run(name, func(t *testing.T) {
+			assert := require.New(t)
+			ctx := context.Background()
+			fast, fastPath := test.NewBboltStore(t, nil)
+			inner, durablePath := test.NewBboltStore(t, nil)
+			durable := &pausingStore{ContextStore: inner}
+			store := cache.NewStore(fast, durable, &cache.Options{Mode: mode, NegativeTTL: time.Minute})
+			defer test.CleanUp(store, fastPath, durablePath)
+
+			assert.NoError(inner.Set(ctx, "foo", test.Foo{Bar: "old"}))
+			for _, k := range []string{"foo", "bar"} {
+				// The read gets the old value or the miss from the durable store and is paused before the refill
+				read, resume := durable.pause()
+				done := make(chan struct{})
+				go func() {
+					defer close(done)
+					_, err := store.Get(ctx, k, new(test.Foo))
+					assert.NoError(err)
+				}()
+				<-read
+				assert.NoError(store.Set(ctx, k, test.Foo{Bar: "new"}))
+				close(resume)
+				<-done
+
+				actual := new(test.Foo)
+				found, err := store.Get(ctx, k, actual)
+				assert.NoError(err)
+				assert.True(found, "No value was found for %q, but should have been", k)
+				assert.Equal(test.Foo{Bar: "new"}, *actual)
+			}
+		})
+	}
+}
+
+// TestSlowRefill tests if a slow refill of the fast store doesn't block writes,
+// and if the refilled value doesn't overwrite the value of a write that happened in the meantime.
+func TestSlowRefill(t *testing.T) {
+	assert := require.New(t)
+	ctx := context.Background()
+	inner, fastPath := test.NewBboltStore(t, nil)
+	fast := &slowStore{ContextStore: inner}
+	durable, durablePath := test.NewBboltStore(t, nil)
+	store := cache.NewStore(fast, durable, &cache.Options{Mode: cache.WriteThrough})
+	defer test.CleanUp(store, fastPath, durablePath)
+
+	assert.NoError(durable.Set(ctx, "foo", test.Foo{Bar: "old"}))
+	started, release := fast.slowDown()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err := store.Get(ctx, "foo", new(test.Foo))
+		assert.NoError(err)
+	}()
+	<-started
+
+	// The write must not wait for the refill
+	written := make(chan error)
+	go func() {
+		written <- store.Set(ctx, "foo", test.Foo{Bar: "new"})
+	}()
+	select {
+	case err := <-written:
+		assert.NoError(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("The write was blocked by the refill")
+	}
+	close(release)
+	<-done
+
+	actual := new(test.Foo)
+	found, err := store.Get(ctx, "foo", actual)
+	assert.NoError(err)
+	assert.True(found, "No value was found, but should have been")
+	assert.Equal(test.Foo{Bar: "new"}, *actual)
+}
+
+// TestConcurrentWrites tests if a write that reaches the fast store after a newer write of the same key
+// doesn't leave its outdated value in the fast store.
+func TestConcurrentWrites(t *testing.T) {
+	assert := require.New(t)
+	ctx := context.Background()
+	inner, fastPath := test.NewBboltStore(t, nil)
+	fast := &slowStore{ContextStore: inner}
+	durable, durablePath := test.NewBboltStore(t, nil)
+	store := cache.NewStore(fast, durable, &cache.Options{Mode: cache.WriteThrough})
+	defer test.CleanUp(store, fastPath, durablePath)
+
+	// The old value is written to the durable store, but its write to the fast store is delayed
+	started, release := fast.slowDown()
+	written := make(chan error)
+	go func() {
+		written <- store.Set(ctx, "foo", test.Foo{Bar: "old"})
+	}()
+	<-started
+	assert.NoError(store.Set(ctx, "foo", test.Foo{Bar: "new"}))
+	close(release)
+	assert.NoError(<-written)
+
+	actual := new(test.Foo)
+	found, err := store.Get(ctx, "foo", actual)
+	assert.NoError(err)
+	assert.True(found, "No value was found, but should have been")
+	assert.Equal(test.Foo{Bar: "new"}, *actual)
+}
+
+// TestTTL tests if values in the fast store expire.
+func TestTTL(t *testing.T) {
+	assert := require.New(t)
+	ctx := context.Background()
+	store, _, durable, paths := createStore(t, &cache.Options{TTL: time.Second})
+	defer test.CleanUp(store, paths...)
+
+	err := store.Set(ctx, "foo", test.Foo{Bar: "baz"})
+	assert.NoError(err)
+	err = durable.Delete(ctx, "foo")
+	assert.NoError(err)
+
+	// Still cached
+	found, err := store.Get(ctx, "foo", new(test.Foo))
+	assert.NoError(err)
+	assert.True(found, "No value was found, but should have been")
+
+	time.Sleep(2 * time.Second)
+	found, err = store.Get(ctx, "foo", new(test.Foo))
+	assert.NoError(err)
+	assert.False(found, "A value was found, but no value was expected")
+}
+
+// TestWriteBehind tests if all queued writes reach the durable store when the store is closed.
+func TestWriteBehind(t *testing.T) {
+	assert := require.New(t)
+	ctx := context.Background()
+	options := cache.Options{
+		Mode:      cache.WriteBehind,
+		QueueSize: 10,
+		ErrorHandler: func(k string, err error) {
+			t.Errorf("Writing %v failed: %v", k, err)
+		},
+	}
+	store, _, _, paths := createStore(t, &options)
+	defer test.CleanUp(nil, paths...)
+
+	for i := 0; i < 100; i++ {
+		err := store.Set(ctx, strconv.Itoa(i), test.Foo{Bar: strconv.Itoa(i)})
+		assert.NoError(err)
+	}
+	for i := 0; i < 100; i += 2 {
+		err := store.Delete(ctx, strconv.Itoa(i))
+		assert.NoError(err)
+	}
+	// The latest write wins
+	err := store.Set(ctx, "1", test.Foo{Bar: "latest"})
+	assert.NoError(err)
+
+	err = store.Close()
+	assert.NoError(err)
+	err = store.Set(ctx, "foo", test.Foo{Bar: "baz"})
+	assert.Error(err)
+
+	durable, err := bbolt.NewContextStore(&bbolt.Options{Path: paths[1]})
+	assert.NoError(err)
+	defer durable.Close()
+	for i := 0; i < 100; i++ {
+		actual := new(test.Foo)
+		found, err := durable.Get(ctx, strconv.Itoa(i), actual)
+		assert.NoError(err)
+		assert.Equal(i%2 == 1, found)
+	}
+	actual := new(test.Foo)
+	_, err = durable.Get(ctx, "1", actual)
+	assert.NoError(err)
+	assert.Equal(test.Foo{Bar: "latest"}, *actual)
+}
+
+// TestWriteBehindPending tests if pending writes are served by Get when the value isn't in the fast store,
+// instead of the outdated value of the durable store.
+func TestWriteBehindPending(t *testing.T) {
+	assert := require.New(t)
+	ctx := context.Background()
+	fast, fastPath := test.NewBboltStore(t, nil)
+	inner, durablePath := test.NewBboltStore(t, nil)
+	durable := &blockingStore{ContextStore: inner, release: make(chan struct{})}
+	store := cache.NewStore(fast, durable, &cache.Options{Mode: cache.WriteBehind})
+	defer test.CleanUp(nil, fastPath, durablePath)
+
+	assert.NoError(inner.Set(ctx, "foo", test.Foo{Bar: "old"}))
+	assert.NoError(inner.Set(ctx, "bar", test.Foo{Bar: "old"}))
+	assert.NoError(store.Set(ctx, "foo", test.Foo{Bar: "new"}))
+	assert.NoError(store.Delete(ctx, "bar"))
+	// The value was evicted from the fast store
+	assert.NoError(fast.Delete(ctx, "foo"))
+
+	actual := new(test.Foo)
+	found, err := store.Get(ctx, "foo", actual)
+	assert.NoError(err)
+	assert.True(found)
+	assert.Equal(test.Foo{Bar: "new"}, *actual)
+	// Values of a different type are converted
+	m := make(map[string]interface{})
+	found, err = store.Get(ctx, "foo", &m)
+	assert.NoError(err)
+	assert.True(found)
+	assert.Equal("new", m["Bar"])
+	found, err = store.Get(ctx, "bar", actual)
+	assert.NoError(err)
+	assert.False(found)
+	// Values must be retrieved into pointers
+	_, err = store.Get(ctx, "foo", test.Foo{})
+	assert.Error(err)
+	// Retrieved values don't share data with the pending write
+	assert.NoError(store.Set(ctx, "baz", map[string]string{"Bar": "new"}))
+	assert.NoError(fast.Delete(ctx, "baz"))
+	retrieved := make(map[string]string)
+	_, err = store.Get(ctx, "baz", &retrieved)
+	assert.NoError(err)
+	retrieved["Bar"] = "modified"
+	retrieved = make(map[string]string)
+	_, err = store.Get(ctx, "baz", &retrieved)
+	assert.NoError(err)
+	assert.Equal("new", retrieved["Bar"])
+	// The fast store wasn't refilled from the durable store
+	found, err = fast.Get(ctx, "foo", actual)
+	assert.NoError(err)
+	assert.False(found)
+
+	close(durable.release)
+	assert.NoError(store.Close())
+	durableStore, err := bbolt.NewContextStore(&bbolt.Options{Path: durablePath})
+	assert.NoError(err)
+	defer durableStore.Close()
+	found, err = durableStore.Get(ctx, "foo", actual)
+	assert.NoError(err)
+	assert.True(found)
+	assert.Equal(test.Foo{Bar: "new"}, *actual)
+}
+
+// TestCloseDuringEnqueue tests if Close doesn't deadlock with a write that waits for space in the queue
+// after its context was canceled, and if the write reaches the durable store nonetheless.
+func TestCloseDuringEnqueue(t *testing.T) {
+	assert := require.New(t)
+	fast, fastPath := test.NewBboltStore(t, nil)
+	inner, durablePath := test.NewBboltStore(t, nil)
+	durable := &blockingStore{ContextStore: inner, release: make(chan struct{})}
+	store := cache.NewStore(fast, durable, &cache.Options{Mode: cache.WriteBehind, QueueSize: 1})
+	defer test.CleanUp(nil, fastPath, durablePath)
+
+	// The worker blocks on "first", "second" fills the queue
+	assert.NoError(store.Set(context.Background(), "first", test.Foo{Bar: "first"}))
+	time.Sleep(100 * time.Millisecond)
+	assert.NoError(store.Set(context.Background(), "second", test.Foo{Bar: "second"}))
+
+	// The write of "third" waits for space in the queue, a newer write is coalesced into it,
+	// and then its context is canceled, so it must queue the key for the newer write.
+	ctx, cancel := context.WithCancel(context.Background())
+	enqueued := make(chan struct{})
+	go func() {
+		defer close(enqueued)
+		assert.NoError(store.Set(ctx, "third", test.Foo{Bar: "old"}))
+	}()
+	time.Sleep(100 * time.Millisecond)
+	assert.NoError(store.Set(context.Background(), "third", test.Foo{Bar: "new"}))
+	cancel()
+	time.Sleep(100 * time.Millisecond)
+
+	closed := make(chan error)
+	go func() {
+		closed <- store.Close()
+	}()
+	select {
+	case <-enqueued:
+	case <-time.After(5 * time.Second):
+		t.Fatal("The write wasn't interrupted by Close")
+	}
+	close(durable.release)
+	select {
+	case err := <-closed:
+		assert.NoError(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close didn't return")
+	}
+
+	durableStore, err := bbolt.NewContextStore(&bbolt.Options{Path: durablePath})
+	assert.NoError(err)
+	defer durableStore.Close()
+	for k, expected := range map[string]string{"first": "first", "second": "second", "third": "new"} {
+		actual := new(test.Foo)
+		found, err := durableStore.Get(context.Background(), k, actual)
+		assert.NoError(err)
+		assert.True(found, "No value was found for %q, but should have been", k)
+		assert.Equal(test.Foo{Bar: expected}, *actual)
+	}
+}
+
+// blockingStore blocks all writes until release is closed.
+type blockingStore struct {
+	gokv.ContextStore
+	release chan struct{}
+}
+
+func (s *blockingStore) Set(ctx context.Context, k string, v interface{}) error {
+	<-s.release
+	return s.ContextStore.Set(ctx, k, v)
+}
+
+func (s *blockingStore) Delete(ctx context.Context, k string) error {
+	<-s.release
+	return s.ContextStore.Delete(ctx, k)
+}
+
+// slowStore makes the next Set slow, see slowDown().
+type slowStore struct {
+	gokv.ContextStore
+	lock    sync.Mutex
+	started chan struct{}
+	release chan struct{}
+}
+
+// slowDown makes the next Set close started and wait until release is closed before writing.
+func (s *slowStore) slowDown() (started chan struct{}, release chan struct{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.started = make(chan struct{})
+	s.release = make(chan struct{})
+	return s.started, s.release
+}
+
+func (s *slowStore) Set(ctx context.Context, k string, v interface{}) error {
+	s.lock.Lock()
+	started, release := s.started, s.release
+	s.started, s.release = nil, nil
+	s.lock.Unlock()
+	if started != nil {
+		close(started)
+		<-release
+	}
+	return s.ContextStore.Set(ctx, k, v)
+}
+
+// pausingStore pauses the next Get after it read from the wrapped store, see pause().
+type pausingStore struct {
+	gokv.ContextStore
+	lock   sync.Mutex
+	read   chan struct{}
+	resume chan struct{}
+}
+
+// pause makes the next Get close read after reading and wait until resume is closed.
+func (s *pausingStore) pause() (read chan struct{}, resume chan struct{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.read = make(chan struct{})
+	s.resume = make(chan struct{})
+	return s.read, s.resume
+}
+
+func (s *pausingStore) Get(ctx context.Context, k string, v interface{}) (bool, error) {
+	found, err := s.ContextStore.Get(ctx, k, v)
+	s.lock.Lock()
+	read, resume := s.read, s.resume
+	s.read, s.resume = nil, nil
+	s.lock.Unlock()
+	if read != nil {
+		close(read)
+		<-resume
+	}
+	return found, err
+}
+
+func createStore(t *testing.T, options *cache.Options) (store, fast, durable gokv.ContextStore, paths []string) {
+	fast, fastPath := test.NewBboltStore(t, nil)
+	durable, durablePath := test.NewBboltStore(t, nil)
+	return cache.NewStore(fast, durable, options), fast, durable, []string{fastPath, durablePath}
+}
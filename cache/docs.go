@@ -0,0 +1,11 @@
+/*
+Package cache contains a `gokv.ContextStore` that combines a fast store (e.g. a Go map, FreeCache or Redis)
+with a durable store (e.g. PostgreSQL or S3).
+
+Reads are always served from the fast store if possible and fall back to the durable store,
+populating the fast store on the way (read-through).
+How writes are handled depends on the Mode, see its constants.
+In WriteBehind mode writes that aren't in the durable store yet are served from memory
+when the value isn't in the fast store (anymore), so reads never see an outdated value of the durable store.
+*/
+package cache
@@ -0,0 +1,112 @@
+/*
+Command gokv contains tools for working with gokv stores.
+
+Usage:
+
+	gokv copy -from <store URL> -to <store URL> [flags]
+
+Copies all key-value pairs from one store to another as raw bytes.
+See "gokv copy -h" for the flags and the supported store URLs.
+*/
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/SpeedyCoder/gokv/migrate"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("gokv: ")
+
+	if len(os.Args) < 2 {
+		usage()
+	}
+	switch os.Args[1] {
+	case "copy":
+		if err := runCopy(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: gokv copy -from <store URL> -to <store URL> [flags]")
+	os.Exit(2)
+}
+
+func runCopy(args []string) error {
+	flags := flag.NewFlagSet("copy", flag.ExitOnError)
+	from := flags.String("from", "", "URL of the source store")
+	to := flags.String("to", "", "URL of the destination store")
+	concurrency := flags.Int("concurrency", migrate.DefaultOptions.Concurrency, "number of keys that are copied in parallel")
+	checkpoint := flags.String("checkpoint", "", "key in the destination store for saving the progress, so that an interrupted copy can be resumed")
+	interval := flags.Int("interval", migrate.DefaultOptions.CheckpointInterval, "number of keys after which the checkpoint is saved and the progress is printed")
+	dryRun := flags.Bool("dry-run", false, "only read the values, don't write anything")
+	verify := flags.Bool("verify", false, "read each value back from the destination store and compare it")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: gokv copy -from <store URL> -to <store URL> [flags]")
+		fmt.Fprintln(flags.Output(), "\nCopies all key-value pairs from one store to another as raw bytes.")
+		fmt.Fprintln(flags.Output(), "\nStore URLs:")
+		fmt.Fprint(flags.Output(), storeUsage+"\n")
+		fmt.Fprintln(flags.Output(), "Flags:")
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+	if *from == "" || *to == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	src, err := openStore(*from)
+	if err != nil {
+		return fmt.Errorf("opening the source store failed: %v", err)
+	}
+	defer src.Close()
+	dst, err := openStore(*to)
+	if err != nil {
+		return fmt.Errorf("opening the destination store failed: %v", err)
+	}
+	defer dst.Close()
+
+	// Stop on Ctrl+C, which saves the checkpoint
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		log.Print("interrupted, stopping")
+		cancel()
+	}()
+
+	progress, err := migrate.Copy(ctx, src, dst, &migrate.Options{
+		Concurrency:        *concurrency,
+		CheckpointKey:      *checkpoint,
+		CheckpointInterval: *interval,
+		DryRun:             *dryRun,
+		Verify:             *verify,
+		Progress:           printProgress,
+	})
+	if err != nil {
+		if progress.Checkpoint != "" && *checkpoint != "" && !*dryRun {
+			log.Printf("run the same command again to continue after %q", progress.Checkpoint)
+		}
+		return err
+	}
+	log.Print("done")
+	return nil
+}
+
+func printProgress(p migrate.Progress) {
+	log.Printf("processed: %d, copied: %d, skipped: %d, verified: %d, checkpoint: %q",
+		p.Processed, p.Copied, p.Skipped, p.Verified, p.Checkpoint)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/SpeedyCoder/gokv"
+	"github.com/SpeedyCoder/gokv/backends/bbolt"
+	"github.com/SpeedyCoder/gokv/backends/mongodb"
+	"github.com/SpeedyCoder/gokv/backends/postgresql"
+	"github.com/SpeedyCoder/gokv/encoding"
+)
+
+// storeUsage describes the supported store URLs.
+const storeUsage = `  bbolt:///path/to/file.db?bucket=<bucket name>&encoding=<codec>
+      bucket is optional ("default" by default), encoding as well ("json" by default).
+  mongodb://[user:pass@]host1[:port1][,host2[:port2],...]/<database>?collection=<collection name>&encoding=<codec>
+      database is optional ("gokv" by default), collection as well ("item" by default).
+      Other parameters are passed on to the MongoDB driver.
+  postgres://username[:password]@[address]/dbname?table=<table name>&encoding=<codec>
+      table is optional ("Item" by default). Other parameters, e.g. sslmode, are passed on to the PostgreSQL driver.
+      postgresql:// can be used as well.
+  The encoding is only used for the checkpoint, values are copied as they are.
+`
+
+// openers open a store for each supported URL scheme.
+// Only backends that implement gokv.RawStore can be added here.
+var openers = map[string]func(u *url.URL) (gokv.ContextStore, error){
+	"bbolt":      openBbolt,
+	"mongodb":    openMongoDB,
+	"postgres":   openPostgreSQL,
+	"postgresql": openPostgreSQL,
+}
+
+// openStore opens the store for the given URL.
+func openStore(rawURL string) (gokv.ContextStore, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	open, ok := openers[u.Scheme]
+	if !ok {
+		return nil, fmt.Errorf("unsupported store %q", u.Scheme)
+	}
+	return open(u)
+}
+
+func openBbolt(u *url.URL) (gokv.ContextStore, error) {
+	codec, err := codecFromQuery(u)
+	if err != nil {
+		return nil, err
+	}
+	return bbolt.NewContextStore(&bbolt.Options{
+		BucketName: u.Query().Get("bucket"),
+		Path:       u.Path,
+		Encoding:   codec,
+	})
+}
+
+func openMongoDB(u *url.URL) (gokv.ContextStore, error) {
+	codec, err := codecFromQuery(u)
+	if err != nil {
+		return nil, err
+	}
+	client, err := mongodb.NewClient(mongodb.Options{
+		ConnectionString: withoutParams(u, "collection", "encoding"),
+		DatabaseName:     strings.TrimPrefix(u.Path, "/"),
+		CollectionName:   u.Query().Get("collection"),
+		Codec:            codec,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+func openPostgreSQL(u *url.URL) (gokv.ContextStore, error) {
+	codec, err := codecFromQuery(u)
+	if err != nil {
+		return nil, err
+	}
+	client, err := postgresql.NewClient(postgresql.Options{
+		ConnectionURL: withoutParams(u, "table", "encoding"),
+		TableName:     u.Query().Get("table"),
+		Codec:         codec,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// codecFromQuery returns the codec of the "encoding" parameter, or nil for the default of the store.
+func codecFromQuery(u *url.URL) (encoding.Encoding, error) {
+	name := u.Query().Get("encoding")
+	if name == "" {
+		return nil, nil
+	}
+	return encoding.FromString(name)
+}
+
+// withoutParams returns the URL without the given query parameters,
+// which are only meant for gokv and not for the driver of the store.
+func withoutParams(u *url.URL, names ...string) string {
+	query := u.Query()
+	for _, name := range names {
+		query.Del(name)
+	}
+	result := *u
+	result.RawQuery = query.Encode()
+	return result.String()
+}
@@ -0,0 +1,30 @@
+package gokv
+
+import "context"
+
+// ConditionalStore is a ContextStore that supports conditional writes for optimistic concurrency.
+type ConditionalStore interface {
+	ContextStore
+	// GetWithVersion retrieves the value for the given key like Get does,
+	// together with the current version of the key-value pair.
+	// The version can be passed to CompareAndSwap.
+	// What a version is depends on the implementation, e.g. a revision number of the store
+	// or a hash of the stored value, so it should be treated as opaque.
+	// If no value is found it returns (false, 0, nil).
+	// The key must not be "" and the pointer must not be nil.
+	GetWithVersion(ctx context.Context, k string, v interface{}) (found bool, version uint64, err error)
+	// SetIfNotExists stores the given value for the given key, but only if no value
+	// is stored for the key yet. It returns whether the value was stored.
+	// The key must not be "" and the value must not be nil.
+	SetIfNotExists(ctx context.Context, k string, v interface{}) (stored bool, err error)
+	// CompareAndSwap stores the given value for the given key, but only if the current
+	// version of the key-value pair is the expected one. It returns whether the value was stored.
+	// If no value is stored for the key it returns (false, nil).
+	// The key must not be "" and the value must not be nil.
+	CompareAndSwap(ctx context.Context, k string, expectedVersion uint64, v interface{}) (swapped bool, err error)
+	// CompareAndDelete deletes the stored value for the given key, but only if the current
+	// version of the key-value pair is the expected one. It returns whether the value was deleted.
+	// If no value is stored for the key it returns (false, nil).
+	// The key must not be "".
+	CompareAndDelete(ctx context.Context, k string, expectedVersion uint64) (deleted bool, err error)
+}
@@ -0,0 +1,18 @@
+package encoding
+
+import (
+	"github.com/fxamacker/cbor/v2"
+)
+
+// cborCodec encodes/decodes Go values to/from CBOR.
+type cborCodec string
+
+// Marshal encodes a Go value to CBOR.
+func (c cborCodec) Marshal(v interface{}) ([]byte, error) {
+	return cbor.Marshal(v)
+}
+
+// Unmarshal decodes a CBOR value into a Go value.
+func (c cborCodec) Unmarshal(data []byte, v interface{}) error {
+	return cbor.Unmarshal(data, v)
+}
@@ -2,6 +2,7 @@ package encoding
 
 import (
 	"fmt"
+	"strings"
 )
 
 // Encoding encodes/decodes Go values to/from slices of bytes.
@@ -21,10 +22,34 @@ const (
 	// Proto is a codec that encodes/decodes Go values that implement
 	// the proto.Message interface to/from.
 	Proto = protoCodec("ProtoCodec")
+	// MsgPack is a codec that encodes/decodes Go values to/from MessagePack.
+	MsgPack = msgpackCodec("MsgPackCodec")
+	// CBOR is a codec that encodes/decodes Go values to/from CBOR.
+	CBOR = cborCodec("CBORCodec")
+	// YAML is a codec that encodes/decodes Go values to/from YAML.
+	YAML = yamlCodec("YAMLCodec")
+	// Raw is a codec that passes slices of bytes through as they are.
+	// It accepts []byte, *[]byte and io.Reader values and decodes into *[]byte or io.Writer,
+	// which is useful for storing payloads that are already serialized.
+	Raw = rawCodec("RawCodec")
 )
 
 // FromString returns encoding corresponding to provided lowercase string.
+// A compression algorithm can be appended with a "+", for example "json+zstd",
+// which wraps the codec with NewCompressed and the default threshold.
 func FromString(s string) (Encoding, error) {
+	if i := strings.LastIndex(s, "+"); i >= 0 {
+		compression, err := compressionFromString(s[i+1:])
+		if err != nil {
+			return nil, err
+		}
+		codec, err := FromString(s[:i])
+		if err != nil {
+			return nil, err
+		}
+		return NewCompressed(codec, CompressionOptions{Compression: compression})
+	}
+
 	switch s {
 	case "json":
 		return JSON, nil
@@ -32,6 +57,14 @@ func FromString(s string) (Encoding, error) {
 		return Gob, nil
 	case "proto", "protobuf":
 		return Proto, nil
+	case "msgpack", "messagepack":
+		return MsgPack, nil
+	case "cbor":
+		return CBOR, nil
+	case "yaml", "yml":
+		return YAML, nil
+	case "raw":
+		return Raw, nil
 	default:
 		return nil, fmt.Errorf("unknown encoding type: %s", s)
 	}
@@ -0,0 +1,244 @@
+package encoding
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression is a compression algorithm for NewCompressed.
+type Compression byte
+
+// All available compression algorithms.
+// The values are used as header byte of compressed values, so they must not be changed.
+const (
+	// Gzip compresses values with gzip.
+	Gzip Compression = iota + 1
+	// Snappy compresses values with Snappy.
+	Snappy
+	// Zstd compresses values with Zstandard.
+	Zstd
+)
+
+// uncompressed is the header byte of values that are smaller than the threshold.
+const uncompressed = 0
+
+// ErrTooLarge is returned when a value would be larger than CompressionOptions.MaxSize after decompressing it.
+var ErrTooLarge = errors.New("the decompressed value exceeds the maximum size")
+
+// String returns the lowercase name of the compression algorithm, as used by FromString.
+func (c Compression) String() string {
+	switch c {
+	case Gzip:
+		return "gzip"
+	case Snappy:
+		return "snappy"
+	case Zstd:
+		return "zstd"
+	default:
+		return fmt.Sprintf("Compression(%d)", byte(c))
+	}
+}
+
+// compressionFromString returns the compression algorithm corresponding to the provided lowercase string.
+func compressionFromString(s string) (Compression, error) {
+	for _, c := range []Compression{Gzip, Snappy, Zstd} {
+		if c.String() == s {
+			return c, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown compression type: %s", s)
+}
+
+// CompressionOptions are the options for NewCompressed.
+type CompressionOptions struct {
+	// Compression algorithm.
+	// Optional (Gzip by default).
+	Compression Compression
+	// Values with fewer bytes than the threshold (after marshalling them with the wrapped codec)
+	// are stored uncompressed, because compressing them doesn't pay off.
+	// Optional (1024 by default).
+	Threshold int
+	// Maximum number of bytes of a decompressed value.
+	// Unmarshal returns ErrTooLarge for bigger values instead of decompressing them,
+	// so a small stored value can't make the process allocate huge amounts of memory.
+	// Optional (64 MiB by default).
+	MaxSize int
+}
+
+// DefaultCompressionOptions is a CompressionOptions object with default values.
+// Compression: Gzip, Threshold: 1024, MaxSize: 64 MiB
+var DefaultCompressionOptions = CompressionOptions{
+	Compression: Gzip,
+	Threshold:   1024,
+	MaxSize:     64 << 20,
+}
+
+// NewCompressed returns an Encoding that marshals values with the given codec
+// and compresses the result if it's at least as big as the threshold.
+// Every value is prefixed with a header byte that indicates the compression algorithm,
+// so the algorithm and threshold can be changed without breaking existing values.
+//
+// Values without a known header byte are passed to the wrapped codec as they are.
+// This means values that were written by the wrapped codec directly can still be read,
+// as long as they can't start with a header byte (0 to 3), which is the case for JSON,
+// but not for binary formats like gob.
+func NewCompressed(codec Encoding, options CompressionOptions) (Encoding, error) {
+	// Set default values
+	if options.Compression == 0 {
+		options.Compression = DefaultCompressionOptions.Compression
+	}
+	if options.Threshold == 0 {
+		options.Threshold = DefaultCompressionOptions.Threshold
+	}
+	if options.MaxSize <= 0 {
+		options.MaxSize = DefaultCompressionOptions.MaxSize
+	}
+
+	if _, err := compressionFromString(options.Compression.String()); err != nil {
+		return nil, err
+	}
+
+	return compressedCodec{
+		codec:       codec,
+		compression: options.Compression,
+		threshold:   options.Threshold,
+		maxSize:     options.MaxSize,
+	}, nil
+}
+
+// compressedCodec compresses/decompresses the values of another codec.
+type compressedCodec struct {
+	codec       Encoding
+	compression Compression
+	threshold   int
+	maxSize     int
+}
+
+// Marshal encodes a Go value with the wrapped codec and compresses it.
+func (c compressedCodec) Marshal(v interface{}) ([]byte, error) {
+	data, err := c.codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < c.threshold {
+		return append([]byte{uncompressed}, data...), nil
+	}
+
+	result := []byte{byte(c.compression)}
+	switch c.compression {
+	case Gzip:
+		buffer := bytes.NewBuffer(result)
+		writer := gzip.NewWriter(buffer)
+		if _, err := writer.Write(data); err != nil {
+			return nil, err
+		}
+		if err := writer.Close(); err != nil {
+			return nil, err
+		}
+		return buffer.Bytes(), nil
+	case Snappy:
+		return append(result, snappy.Encode(nil, data)...), nil
+	default: // Zstd
+		encoder, err := sharedZstdEncoder()
+		if err != nil {
+			return nil, err
+		}
+		return encoder.EncodeAll(data, result), nil
+	}
+}
+
+// Unmarshal decompresses a value and decodes it with the wrapped codec.
+func (c compressedCodec) Unmarshal(data []byte, v interface{}) error {
+	if len(data) == 0 {
+		return c.codec.Unmarshal(data, v)
+	}
+
+	var err error
+	switch data[0] {
+	case uncompressed:
+		data = data[1:]
+	case byte(Gzip):
+		var reader *gzip.Reader
+		reader, err = gzip.NewReader(bytes.NewReader(data[1:]))
+		if err != nil {
+			return err
+		}
+		// Read one byte more than allowed to detect values that are too large.
+		data, err = ioutil.ReadAll(io.LimitReader(reader, int64(c.maxSize)+1))
+		if err == nil && len(data) > c.maxSize {
+			return ErrTooLarge
+		}
+	case byte(Snappy):
+		// The decoded length is stored in the header, Decode allocates that much.
+		var size int
+		size, err = snappy.DecodedLen(data[1:])
+		if err != nil {
+			return err
+		}
+		if size > c.maxSize {
+			return ErrTooLarge
+		}
+		data, err = snappy.Decode(nil, data[1:])
+	case byte(Zstd):
+		var decoder *zstd.Decoder
+		decoder, err = sharedZstdDecoder(c.maxSize)
+		if err != nil {
+			return err
+		}
+		data, err = decoder.DecodeAll(data[1:], nil)
+		// The window size of a frame is limited by the maximum size as well.
+		if err == zstd.ErrDecoderSizeExceeded || err == zstd.ErrFrameSizeExceeded || err == zstd.ErrWindowSizeExceeded {
+			return ErrTooLarge
+		}
+	default:
+		// Value that was written without compression wrapper
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.codec.Unmarshal(data, v)
+}
+
+var (
+	zstdEncoderOnce  sync.Once
+	zstdEncoder      *zstd.Encoder
+	zstdEncoderErr   error
+	zstdDecodersLock sync.Mutex
+	zstdDecoders     = make(map[int]*zstd.Decoder)
+)
+
+// sharedZstdEncoder returns a Zstandard encoder that's shared by all codecs.
+// EncodeAll is safe for concurrent use.
+func sharedZstdEncoder() (*zstd.Encoder, error) {
+	zstdEncoderOnce.Do(func() {
+		zstdEncoder, zstdEncoderErr = zstd.NewWriter(nil)
+	})
+	return zstdEncoder, zstdEncoderErr
+}
+
+// sharedZstdDecoder returns a Zstandard decoder that's shared by all codecs with the given maximum size.
+// The maximum size is an option of the decoder, so there's one decoder per maximum size.
+// DecodeAll is safe for concurrent use.
+func sharedZstdDecoder(maxSize int) (*zstd.Decoder, error) {
+	zstdDecodersLock.Lock()
+	defer zstdDecodersLock.Unlock()
+	if decoder, ok := zstdDecoders[maxSize]; ok {
+		return decoder, nil
+	}
+	decoder, err := zstd.NewReader(nil, zstd.WithDecoderMaxMemory(uint64(maxSize)))
+	if err != nil {
+		return nil, err
+	}
+	zstdDecoders[maxSize] = decoder
+	return decoder, nil
+}
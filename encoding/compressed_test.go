@@ -0,0 +1,131 @@
+package encoding_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/SpeedyCoder/gokv/encoding"
+)
+
+// TestCompressed tests if values are compressed above the threshold and can be decompressed with all algorithms.
+func TestCompressed(t *testing.T) {
+	small := foo{Bar: "baz"}
+	big := foo{Bar: strings.Repeat("baz", 1000)}
+
+	for _, compression := range []encoding.Compression{encoding.Gzip, encoding.Snappy, encoding.Zstd} {
+		t.Run(compression.String(), func(t *testing.T) {
+			assert := require.New(t)
+			codec, err := encoding.NewCompressed(encoding.JSON, encoding.CompressionOptions{
+				Compression: compression,
+			})
+			assert.NoError(err)
+
+			plain, err := encoding.JSON.Marshal(big)
+			assert.NoError(err)
+			data, err := codec.Marshal(big)
+			assert.NoError(err)
+			assert.True(len(data) < len(plain)/2, "Value wasn't compressed")
+			actual := new(foo)
+			assert.NoError(codec.Unmarshal(data, actual))
+			assert.Equal(big, *actual)
+
+			// Values below the threshold are only prefixed with the header byte
+			plain, err = encoding.JSON.Marshal(small)
+			assert.NoError(err)
+			data, err = codec.Marshal(small)
+			assert.NoError(err)
+			assert.Equal(len(plain)+1, len(data))
+			actual = new(foo)
+			assert.NoError(codec.Unmarshal(data, actual))
+			assert.Equal(small, *actual)
+
+			// Values written by the plain codec can still be read
+			actual = new(foo)
+			assert.NoError(codec.Unmarshal(plain, actual))
+			assert.Equal(small, *actual)
+		})
+	}
+}
+
+// TestCompressedChange tests if values can be read after changing the algorithm and the threshold.
+func TestCompressedChange(t *testing.T) {
+	assert := require.New(t)
+	val := foo{Bar: strings.Repeat("baz", 100)}
+
+	oldCodec, err := encoding.NewCompressed(encoding.Gob, encoding.CompressionOptions{
+		Compression: encoding.Snappy,
+		Threshold:   1,
+	})
+	assert.NoError(err)
+	newCodec, err := encoding.NewCompressed(encoding.Gob, encoding.CompressionOptions{
+		Compression: encoding.Zstd,
+		Threshold:   1 << 20,
+	})
+	assert.NoError(err)
+
+	data, err := oldCodec.Marshal(val)
+	assert.NoError(err)
+	actual := new(foo)
+	assert.NoError(newCodec.Unmarshal(data, actual))
+	assert.Equal(val, *actual)
+}
+
+// TestCompressedMaxSize tests if values that are larger than the maximum size after decompressing them are rejected.
+func TestCompressedMaxSize(t *testing.T) {
+	big := strings.Repeat("a", 100000)
+
+	for _, compression := range []encoding.Compression{encoding.Gzip, encoding.Snappy, encoding.Zstd} {
+		t.Run(compression.String(), func(t *testing.T) {
+			assert := require.New(t)
+			codec, err := encoding.NewCompressed(encoding.JSON, encoding.CompressionOptions{
+				Compression: compression,
+			})
+			assert.NoError(err)
+			limited, err := encoding.NewCompressed(encoding.JSON, encoding.CompressionOptions{
+				Compression: compression,
+				MaxSize:     len(big),
+			})
+			assert.NoError(err)
+
+			// The JSON string has two more bytes than the maximum size
+			data, err := codec.Marshal(big)
+			assert.NoError(err)
+			assert.True(len(data) < 10000, "Value wasn't compressed")
+			err = limited.Unmarshal(data, new(string))
+			assert.Equal(encoding.ErrTooLarge, err)
+
+			// Values up to the maximum size can be read
+			data, err = codec.Marshal(big[2:])
+			assert.NoError(err)
+			actual := ""
+			assert.NoError(limited.Unmarshal(data, &actual))
+			assert.Equal(big[2:], actual)
+		})
+	}
+}
+
+// TestFromStringCompressed tests if compressed codecs can be created from strings.
+func TestFromStringCompressed(t *testing.T) {
+	assert := require.New(t)
+	val := foo{Bar: strings.Repeat("baz", 1000)}
+
+	for _, s := range []string{"json+gzip", "json+snappy", "json+zstd", "gob+zstd"} {
+		codec, err := encoding.FromString(s)
+		assert.NoError(err, s)
+		data, err := codec.Marshal(val)
+		assert.NoError(err, s)
+		assert.True(len(data) < 1000, "Value wasn't compressed with %s", s)
+		actual := new(foo)
+		assert.NoError(codec.Unmarshal(data, actual), s)
+		assert.Equal(val, *actual, s)
+	}
+
+	for _, s := range []string{"json+lz4", "xml+gzip", "+gzip", "json+"} {
+		_, err := encoding.FromString(s)
+		assert.Error(err, s)
+	}
+	_, err := encoding.NewCompressed(encoding.JSON, encoding.CompressionOptions{Compression: 42})
+	assert.Error(err)
+}
@@ -2,6 +2,6 @@
 Package encoding is a wrapper for the core functionality of packages like "encoding/json" and "encoding/gob".
 
 It contains the Encoding interface and multiple implementations for encoding Go values to other formats and decode from other formats to Go values.
-Formats can be JSON, gob etc.
+Formats can be JSON, gob, protobuf, MessagePack, CBOR and YAML.
 */
 package encoding
@@ -0,0 +1,134 @@
+package encoding
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// encryptedFormatVersion is the first byte of every encrypted value,
+// so that the format can be changed in the future.
+const encryptedFormatVersion = 1
+
+var errInvalidCiphertext = errors.New("invalid ciphertext")
+
+// Keyring contains the keys for encrypting and decrypting values.
+type Keyring struct {
+	// ID of the key that's used for encrypting values.
+	// It's stored in front of every encrypted value.
+	CurrentKeyID string
+	// Keys by their ID. IDs must be between 1 and 255 bytes long.
+	// For a key rotation add the new key and change CurrentKeyID.
+	// Old keys must be kept for as long as values encrypted with them are stored.
+	Keys map[string]cipher.AEAD
+}
+
+// NewEncrypted returns an Encoding that marshals values with the given codec
+// and encrypts the result with the current key of the keyring.
+// When unmarshalling, the key is chosen based on the key ID in front of the ciphertext,
+// so values encrypted with older keys can still be decrypted.
+// The key ID is authenticated together with the ciphertext.
+func NewEncrypted(codec Encoding, keyring Keyring) (Encoding, error) {
+	keys := make(map[string]cipher.AEAD, len(keyring.Keys))
+	for id, aead := range keyring.Keys {
+		if len(id) == 0 || len(id) > 255 {
+			return nil, fmt.Errorf("invalid key ID length: %d", len(id))
+		}
+		keys[id] = aead
+	}
+	if _, ok := keys[keyring.CurrentKeyID]; !ok {
+		return nil, fmt.Errorf("current key ID not found in the keyring: %s", keyring.CurrentKeyID)
+	}
+
+	return encryptedCodec{
+		codec: codec,
+		keyring: Keyring{
+			CurrentKeyID: keyring.CurrentKeyID,
+			Keys:         keys,
+		},
+	}, nil
+}
+
+// NewAESGCM creates a cipher.AEAD for use in a Keyring that encrypts with AES-GCM.
+// The key must be 16, 24 or 32 bytes long to select AES-128, AES-192 or AES-256.
+func NewAESGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// NewXChaCha20Poly1305 creates a cipher.AEAD for use in a Keyring that encrypts with XChaCha20-Poly1305.
+// The key must be 32 bytes long.
+func NewXChaCha20Poly1305(key []byte) (cipher.AEAD, error) {
+	return chacha20poly1305.NewX(key)
+}
+
+// encryptedCodec encrypts/decrypts the values of another codec.
+// An encrypted value consists of the format version, the length of the key ID,
+// the key ID, the nonce and the sealed data.
+type encryptedCodec struct {
+	codec   Encoding
+	keyring Keyring
+}
+
+// Marshal encodes a Go value with the wrapped codec and encrypts it.
+func (c encryptedCodec) Marshal(v interface{}) ([]byte, error) {
+	data, err := c.codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	id := c.keyring.CurrentKeyID
+	aead := c.keyring.Keys[id]
+
+	header := make([]byte, 0, 2+len(id))
+	header = append(header, encryptedFormatVersion, byte(len(id)))
+	header = append(header, id...)
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	result := make([]byte, 0, len(header)+len(nonce)+len(data)+aead.Overhead())
+	result = append(result, header...)
+	result = append(result, nonce...)
+	// The header is passed as additional data, so the key ID can't be tampered with.
+	return aead.Seal(result, nonce, data, header), nil
+}
+
+// Unmarshal decrypts a value and decodes it with the wrapped codec.
+func (c encryptedCodec) Unmarshal(data []byte, v interface{}) error {
+	if len(data) < 2 || data[0] != encryptedFormatVersion {
+		return errInvalidCiphertext
+	}
+	idLen := int(data[1])
+	if len(data) < 2+idLen {
+		return errInvalidCiphertext
+	}
+	header := data[:2+idLen]
+	id := string(header[2:])
+
+	aead, ok := c.keyring.Keys[id]
+	if !ok {
+		return fmt.Errorf("unknown key ID: %s", id)
+	}
+	if len(data) < len(header)+aead.NonceSize() {
+		return errInvalidCiphertext
+	}
+	nonce := data[len(header) : len(header)+aead.NonceSize()]
+	ciphertext := data[len(header)+aead.NonceSize():]
+
+	plaintext, err := aead.Open(nil, nonce, ciphertext, header)
+	if err != nil {
+		return err
+	}
+	return c.codec.Unmarshal(plaintext, v)
+}
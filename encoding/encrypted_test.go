@@ -0,0 +1,144 @@
+package encoding_test
+
+import (
+	"crypto/cipher"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/SpeedyCoder/gokv/encoding"
+)
+
+type foo struct {
+	Bar string
+}
+
+func newAESGCM(t *testing.T, b byte) cipher.AEAD {
+	key := make([]byte, 32)
+	for i := range key {
+		key[i] = b
+	}
+	aead, err := encoding.NewAESGCM(key)
+	require.NoError(t, err)
+	return aead
+}
+
+func newXChaCha(t *testing.T, b byte) cipher.AEAD {
+	key := make([]byte, 32)
+	for i := range key {
+		key[i] = b
+	}
+	aead, err := encoding.NewXChaCha20Poly1305(key)
+	require.NoError(t, err)
+	return aead
+}
+
+// TestEncrypted tests if values can be encrypted and decrypted with all supported ciphers and codecs.
+func TestEncrypted(t *testing.T) {
+	ciphers := map[string]func(*testing.T, byte) cipher.AEAD{
+		"AES-GCM":            newAESGCM,
+		"XChaCha20-Poly1305": newXChaCha,
+	}
+	codecs := map[string]encoding.Encoding{
+		"JSON": encoding.JSON,
+		"gob":  encoding.Gob,
+	}
+	for cipherName, newAEAD := range ciphers {
+		for codecName, codec := range codecs {
+			t.Run(cipherName+"/"+codecName, func(t *testing.T) {
+				assert := require.New(t)
+				codec, err := encoding.NewEncrypted(codec, encoding.Keyring{
+					CurrentKeyID: "k1",
+					Keys:         map[string]cipher.AEAD{"k1": newAEAD(t, 1)},
+				})
+				assert.NoError(err)
+
+				data, err := codec.Marshal(foo{Bar: "secret"})
+				assert.NoError(err)
+				assert.NotContains(string(data), "secret")
+
+				actual := new(foo)
+				assert.NoError(codec.Unmarshal(data, actual))
+				assert.Equal(foo{Bar: "secret"}, *actual)
+
+				// The same value must not lead to the same ciphertext
+				data2, err := codec.Marshal(foo{Bar: "secret"})
+				assert.NoError(err)
+				assert.NotEqual(data, data2)
+			})
+		}
+	}
+}
+
+// TestEncryptedKeyRotation tests if values encrypted with an old key can still be decrypted.
+func TestEncryptedKeyRotation(t *testing.T) {
+	assert := require.New(t)
+	k1 := newAESGCM(t, 1)
+	k2 := newXChaCha(t, 2)
+
+	oldCodec, err := encoding.NewEncrypted(encoding.JSON, encoding.Keyring{
+		CurrentKeyID: "k1",
+		Keys:         map[string]cipher.AEAD{"k1": k1},
+	})
+	assert.NoError(err)
+	newCodec, err := encoding.NewEncrypted(encoding.JSON, encoding.Keyring{
+		CurrentKeyID: "k2",
+		Keys:         map[string]cipher.AEAD{"k1": k1, "k2": k2},
+	})
+	assert.NoError(err)
+
+	oldData, err := oldCodec.Marshal(foo{Bar: "old"})
+	assert.NoError(err)
+	newData, err := newCodec.Marshal(foo{Bar: "new"})
+	assert.NoError(err)
+
+	actual := new(foo)
+	assert.NoError(newCodec.Unmarshal(oldData, actual))
+	assert.Equal("old", actual.Bar)
+	assert.NoError(newCodec.Unmarshal(newData, actual))
+	assert.Equal("new", actual.Bar)
+
+	// The old codec doesn't know the new key
+	assert.Error(oldCodec.Unmarshal(newData, actual))
+}
+
+// TestEncryptedErrors tests some error cases.
+func TestEncryptedErrors(t *testing.T) {
+	assert := require.New(t)
+
+	// Current key missing in the keyring
+	_, err := encoding.NewEncrypted(encoding.JSON, encoding.Keyring{
+		CurrentKeyID: "k2",
+		Keys:         map[string]cipher.AEAD{"k1": newAESGCM(t, 1)},
+	})
+	assert.Error(err)
+	// Empty key ID
+	_, err = encoding.NewEncrypted(encoding.JSON, encoding.Keyring{
+		Keys: map[string]cipher.AEAD{"": newAESGCM(t, 1)},
+	})
+	assert.Error(err)
+	// Invalid key length
+	_, err = encoding.NewAESGCM([]byte("short"))
+	assert.Error(err)
+	_, err = encoding.NewXChaCha20Poly1305([]byte("short"))
+	assert.Error(err)
+
+	codec, err := encoding.NewEncrypted(encoding.JSON, encoding.Keyring{
+		CurrentKeyID: "k1",
+		Keys:         map[string]cipher.AEAD{"k1": newAESGCM(t, 1)},
+	})
+	assert.NoError(err)
+	data, err := codec.Marshal(foo{Bar: "secret"})
+	assert.NoError(err)
+
+	// Tampering with the ciphertext or the key ID must be detected
+	for _, i := range []int{2, len(data) - 1} {
+		tampered := append([]byte{}, data...)
+		tampered[i] ^= 0xff
+		assert.Error(codec.Unmarshal(tampered, new(foo)))
+	}
+	// Truncated and unencrypted data
+	assert.Error(codec.Unmarshal(data[:5], new(foo)))
+	assert.Error(codec.Unmarshal(nil, new(foo)))
+	assert.Error(codec.Unmarshal([]byte(`{"Bar":"secret"}`), new(foo)))
+}
@@ -0,0 +1,162 @@
+package encoding
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// envelopeMagic is the start of every envelope.
+// A single 0xEE isn't enough: it's not a valid first byte for JSON, YAML, gob and protobuf,
+// but in MessagePack and CBOR it's a complete value (-18 and a simple value respectively).
+// A second 0xEE can't follow it in a single MessagePack or CBOR value, and it's not valid UTF-8 either,
+// so values of all these codecs without envelope can be told apart from enveloped values.
+// That's not the case for Raw, whose values can start with any bytes, so it can't be the Legacy codec of NewEnvelope.
+var envelopeMagic = [2]byte{0xEE, 0xEE}
+
+// envelopeHeaderLen is the length of the magic and the codec ID.
+const envelopeHeaderLen = len(envelopeMagic) + 1
+
+// ErrNoEnvelope is returned by ParseEnvelope when the data doesn't start with an envelope.
+var ErrNoEnvelope = errors.New("the data doesn't start with an envelope")
+
+var (
+	errInvalidEnvelope = errors.New("invalid envelope")
+	errRawLegacy       = errors.New("raw values can't be told apart from envelopes, so Raw can't be the legacy codec")
+)
+
+// Envelope is a self-describing value.
+// In addition to the encoded value it contains the ID of the codec that was used for encoding it
+// and an optional schema version.
+type Envelope struct {
+	// ID of the codec that was used for encoding Data.
+	CodecID CodecID
+	// Version of the schema of the value.
+	// 0 if the value isn't versioned.
+	SchemaVersion uint64
+	// The encoded value.
+	Data []byte
+}
+
+// Bytes returns the binary representation of the envelope.
+// It consists of two magic bytes, the codec ID, the schema version as uvarint and the data.
+func (e Envelope) Bytes() []byte {
+	result := make([]byte, envelopeHeaderLen+binary.MaxVarintLen64+len(e.Data))
+	copy(result, envelopeMagic[:])
+	result[len(envelopeMagic)] = byte(e.CodecID)
+	n := binary.PutUvarint(result[envelopeHeaderLen:], e.SchemaVersion)
+	copy(result[envelopeHeaderLen+n:], e.Data)
+	return result[:envelopeHeaderLen+n+len(e.Data)]
+}
+
+// ParseEnvelope parses the binary representation of an envelope.
+// ErrNoEnvelope is returned when the data doesn't start with the magic bytes of envelopes.
+// The Data of the returned envelope shares the memory with the passed data.
+func ParseEnvelope(data []byte) (Envelope, error) {
+	if len(data) < len(envelopeMagic) || !bytes.Equal(data[:len(envelopeMagic)], envelopeMagic[:]) {
+		return Envelope{}, ErrNoEnvelope
+	}
+	if len(data) <= envelopeHeaderLen {
+		return Envelope{}, errInvalidEnvelope
+	}
+	schemaVersion, n := binary.Uvarint(data[envelopeHeaderLen:])
+	if n <= 0 {
+		return Envelope{}, errInvalidEnvelope
+	}
+	return Envelope{
+		CodecID:       CodecID(data[len(envelopeMagic)]),
+		SchemaVersion: schemaVersion,
+		Data:          data[envelopeHeaderLen+n:],
+	}, nil
+}
+
+// EnvelopeOptions are the options for NewEnvelope.
+type EnvelopeOptions struct {
+	// ID of the codec that's used for encoding values.
+	// Optional (JSONCodecID by default).
+	CodecID CodecID
+	// Schema version that's stored in the envelope of every value.
+	// Optional (0 by default, which means the values aren't versioned).
+	SchemaVersion uint64
+	// Registry that contains the codec for writing and all codecs that values might have been written with.
+	// Optional (DefaultRegistry by default).
+	Registry *Registry
+	// Codec for values that were written without envelope,
+	// for example by a store that was configured with a plain codec before.
+	// Its values must not start with the magic bytes of envelopes (0xEE 0xEE),
+	// which is the case for the codecs of this package except Raw, so Raw isn't allowed.
+	// Optional (nil by default, which leads to an error for such values).
+	Legacy Encoding
+}
+
+// DefaultEnvelopeOptions is an EnvelopeOptions object with default values.
+// CodecID: JSONCodecID, SchemaVersion: 0, Registry: DefaultRegistry, Legacy: nil
+var DefaultEnvelopeOptions = EnvelopeOptions{
+	CodecID:  JSONCodecID,
+	Registry: DefaultRegistry,
+	// No need to set SchemaVersion or Legacy because their Go zero values are fine for that.
+}
+
+// NewEnvelope returns an Encoding that wraps every value in an Envelope.
+// Values are encoded with the configured codec, but decoded with the codec from their envelope,
+// so the codec of a store can be changed without breaking the values that are already stored.
+// Together with the Legacy option this allows to migrate a store from a plain codec (for example gob)
+// to another codec (for example JSON) without downtime.
+func NewEnvelope(options EnvelopeOptions) (Encoding, error) {
+	// Set default values
+	if options.CodecID == 0 {
+		options.CodecID = DefaultEnvelopeOptions.CodecID
+	}
+	if options.Registry == nil {
+		options.Registry = DefaultEnvelopeOptions.Registry
+	}
+
+	codec, ok := options.Registry.Codec(options.CodecID)
+	if !ok {
+		return nil, fmt.Errorf("no codec registered for ID %d", options.CodecID)
+	}
+	if options.Legacy == Raw {
+		return nil, errRawLegacy
+	}
+
+	return envelopeCodec{
+		codec:   codec,
+		options: options,
+	}, nil
+}
+
+// envelopeCodec wraps values of the codecs of a registry in envelopes.
+type envelopeCodec struct {
+	codec   Encoding
+	options EnvelopeOptions
+}
+
+// Marshal encodes a Go value with the configured codec and wraps it in an envelope.
+func (c envelopeCodec) Marshal(v interface{}) ([]byte, error) {
+	data, err := c.codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return Envelope{
+		CodecID:       c.options.CodecID,
+		SchemaVersion: c.options.SchemaVersion,
+		Data:          data,
+	}.Bytes(), nil
+}
+
+// Unmarshal decodes an enveloped value with the codec that's referenced in the envelope.
+func (c envelopeCodec) Unmarshal(data []byte, v interface{}) error {
+	envelope, err := ParseEnvelope(data)
+	if err == ErrNoEnvelope && c.options.Legacy != nil {
+		return c.options.Legacy.Unmarshal(data, v)
+	} else if err != nil {
+		return err
+	}
+
+	codec, ok := c.options.Registry.Codec(envelope.CodecID)
+	if !ok {
+		return fmt.Errorf("no codec registered for ID %d", envelope.CodecID)
+	}
+	return codec.Unmarshal(envelope.Data, v)
+}
@@ -0,0 +1,134 @@
+package encoding_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/SpeedyCoder/gokv/encoding"
+)
+
+// TestEnvelope tests if values written with one codec can be read after changing the codec.
+func TestEnvelope(t *testing.T) {
+	assert := require.New(t)
+	val := foo{Bar: "baz"}
+
+	gobCodec, err := encoding.NewEnvelope(encoding.EnvelopeOptions{CodecID: encoding.GobCodecID})
+	assert.NoError(err)
+	jsonCodec, err := encoding.NewEnvelope(encoding.EnvelopeOptions{
+		CodecID: encoding.JSONCodecID,
+		Legacy:  encoding.Gob,
+	})
+	assert.NoError(err)
+
+	// Values written by the plain codec are read with the legacy codec
+	plain, err := encoding.Gob.Marshal(val)
+	assert.NoError(err)
+	actual := new(foo)
+	assert.NoError(jsonCodec.Unmarshal(plain, actual))
+	assert.Equal(val, *actual)
+	// Without legacy codec they lead to an error
+	assert.Error(gobCodec.Unmarshal(plain, new(foo)))
+
+	// Values written with the old codec
+	data, err := gobCodec.Marshal(val)
+	assert.NoError(err)
+	actual = new(foo)
+	assert.NoError(jsonCodec.Unmarshal(data, actual))
+	assert.Equal(val, *actual)
+
+	// Values written with the new codec
+	data, err = jsonCodec.Marshal(val)
+	assert.NoError(err)
+	envelope, err := encoding.ParseEnvelope(data)
+	assert.NoError(err)
+	assert.Equal(encoding.JSONCodecID, envelope.CodecID)
+	assert.Equal(`{"Bar":"baz"}`, string(envelope.Data))
+	actual = new(foo)
+	assert.NoError(gobCodec.Unmarshal(data, actual))
+	assert.Equal(val, *actual)
+}
+
+// TestEnvelopeSchemaVersion tests if the schema version is stored in the envelope.
+func TestEnvelopeSchemaVersion(t *testing.T) {
+	assert := require.New(t)
+
+	for _, version := range []uint64{0, 1, 300, 1 << 63} {
+		codec, err := encoding.NewEnvelope(encoding.EnvelopeOptions{SchemaVersion: version})
+		assert.NoError(err)
+		data, err := codec.Marshal(foo{Bar: "baz"})
+		assert.NoError(err)
+		envelope, err := encoding.ParseEnvelope(data)
+		assert.NoError(err)
+		assert.Equal(version, envelope.SchemaVersion)
+		assert.Equal(data, envelope.Bytes())
+	}
+}
+
+// TestRegistry tests registering custom codecs.
+func TestRegistry(t *testing.T) {
+	assert := require.New(t)
+	registry := encoding.NewRegistry()
+
+	zstdJSON, err := encoding.FromString("json+zstd")
+	assert.NoError(err)
+	assert.Error(registry.Register(encoding.JSONCodecID, zstdJSON))
+	assert.Error(registry.Register(encoding.MinCustomCodecID-1, zstdJSON))
+	assert.Error(registry.Register(encoding.MinCustomCodecID, nil))
+	assert.NoError(registry.Register(encoding.MinCustomCodecID, zstdJSON))
+	assert.Error(registry.Register(encoding.MinCustomCodecID, zstdJSON))
+
+	codec, ok := registry.Codec(encoding.MinCustomCodecID)
+	assert.True(ok)
+	assert.Equal(zstdJSON, codec)
+	_, ok = registry.Codec(encoding.MinCustomCodecID + 1)
+	assert.False(ok)
+
+	// The codec is only available in the registry it was registered in
+	_, err = encoding.NewEnvelope(encoding.EnvelopeOptions{CodecID: encoding.MinCustomCodecID})
+	assert.Error(err)
+	custom, err := encoding.NewEnvelope(encoding.EnvelopeOptions{
+		CodecID:  encoding.MinCustomCodecID,
+		Registry: registry,
+	})
+	assert.NoError(err)
+	data, err := custom.Marshal(foo{Bar: "baz"})
+	assert.NoError(err)
+	defaultCodec, err := encoding.NewEnvelope(encoding.EnvelopeOptions{})
+	assert.NoError(err)
+	assert.Error(defaultCodec.Unmarshal(data, new(foo)))
+	actual := new(foo)
+	assert.NoError(custom.Unmarshal(data, actual))
+	assert.Equal(foo{Bar: "baz"}, *actual)
+
+	// Invalid envelopes
+	_, err = encoding.ParseEnvelope([]byte{0xEE, 0xEE, 1})
+	assert.Error(err)
+	_, err = encoding.ParseEnvelope([]byte(`{"Bar":"baz"}`))
+	assert.Equal(encoding.ErrNoEnvelope, err)
+}
+
+// TestEnvelopeLegacyMsgPack tests if legacy MessagePack values that start with the first magic byte
+// aren't mistaken for envelopes.
+func TestEnvelopeLegacyMsgPack(t *testing.T) {
+	assert := require.New(t)
+
+	codec, err := encoding.NewEnvelope(encoding.EnvelopeOptions{Legacy: encoding.MsgPack})
+	assert.NoError(err)
+
+	// -18 as negative fixint, like compact MessagePack encoders write it
+	plain := []byte{0xEE}
+	_, err = encoding.ParseEnvelope(plain)
+	assert.Equal(encoding.ErrNoEnvelope, err)
+	var actual int
+	assert.NoError(codec.Unmarshal(plain, &actual))
+	assert.Equal(-18, actual)
+}
+
+// TestEnvelopeLegacyRaw tests if Raw is rejected as legacy codec, because its values can look like envelopes.
+func TestEnvelopeLegacyRaw(t *testing.T) {
+	_, err := encoding.NewEnvelope(encoding.EnvelopeOptions{Legacy: encoding.Raw})
+	if err == nil {
+		t.Error("Expected an error, but got none")
+	}
+}
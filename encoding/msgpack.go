@@ -0,0 +1,18 @@
+package encoding
+
+import (
+	"github.com/vmihailenco/msgpack/v4"
+)
+
+// msgpackCodec encodes/decodes Go values to/from MessagePack.
+type msgpackCodec string
+
+// Marshal encodes a Go value to MessagePack.
+func (c msgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+// Unmarshal decodes a MessagePack value into a Go value.
+func (c msgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	return msgpack.Unmarshal(data, v)
+}
@@ -0,0 +1,47 @@
+package encoding
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+)
+
+var errNotRaw = errors.New("raw codec only supports []byte, *[]byte and io.Reader values and decodes into *[]byte or io.Writer")
+
+// rawCodec passes slices of bytes through without encoding them.
+type rawCodec string
+
+// Marshal returns the bytes of a []byte, *[]byte or io.Reader value as they are.
+// Slices are copied, because some stores keep the marshalled data in memory.
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case []byte:
+		return append([]byte{}, v...), nil
+	case *[]byte:
+		if v == nil {
+			return nil, errNotRaw
+		}
+		return append([]byte{}, *v...), nil
+	case io.Reader:
+		return ioutil.ReadAll(v)
+	default:
+		return nil, errNotRaw
+	}
+}
+
+// Unmarshal copies the bytes into a *[]byte or writes them to an io.Writer.
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	switch v := v.(type) {
+	case *[]byte:
+		if v == nil {
+			return errNotRaw
+		}
+		*v = append([]byte{}, data...)
+		return nil
+	case io.Writer:
+		_, err := v.Write(data)
+		return err
+	default:
+		return errNotRaw
+	}
+}
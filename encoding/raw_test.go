@@ -0,0 +1,53 @@
+package encoding_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/SpeedyCoder/gokv/encoding"
+)
+
+// TestRaw tests if the raw codec passes bytes through for all supported types.
+func TestRaw(t *testing.T) {
+	assert := require.New(t)
+	expected := []byte{0, 1, 2, 0xff}
+
+	for _, v := range []interface{}{expected, &expected, bytes.NewReader(expected)} {
+		data, err := encoding.Raw.Marshal(v)
+		assert.NoError(err)
+		assert.Equal(expected, data)
+
+		actual := new([]byte)
+		assert.NoError(encoding.Raw.Unmarshal(data, actual))
+		assert.Equal(expected, *actual)
+
+		buffer := new(bytes.Buffer)
+		assert.NoError(encoding.Raw.Unmarshal(data, buffer))
+		assert.Equal(expected, buffer.Bytes())
+	}
+
+	// The marshalled data must not share memory with the value
+	val := []byte("foo")
+	data, err := encoding.Raw.Marshal(val)
+	assert.NoError(err)
+	val[0] = 'b'
+	assert.Equal("foo", string(data))
+
+	// Unsupported types
+	_, err = encoding.Raw.Marshal("foo")
+	assert.Error(err)
+	_, err = encoding.Raw.Marshal(foo{Bar: "baz"})
+	assert.Error(err)
+	assert.Error(encoding.Raw.Unmarshal(data, new(string)))
+	var nilPtr *[]byte
+	assert.Error(encoding.Raw.Unmarshal(data, nilPtr))
+
+	codec, err := encoding.FromString("raw")
+	assert.NoError(err)
+	data, err = codec.Marshal(strings.NewReader("foo"))
+	assert.NoError(err)
+	assert.Equal("foo", string(data))
+}
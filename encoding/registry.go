@@ -0,0 +1,81 @@
+package encoding
+
+import (
+	"fmt"
+	"sync"
+)
+
+// CodecID identifies a codec in a Registry.
+// It's stored in the envelope of every value that's written by an Encoding created with NewEnvelope.
+type CodecID byte
+
+// IDs of the codecs of this package in the DefaultRegistry.
+// IDs below MinCustomCodecID are reserved for codecs of this package.
+const (
+	JSONCodecID CodecID = iota + 1
+	GobCodecID
+	ProtoCodecID
+	MsgPackCodecID
+	CBORCodecID
+	YAMLCodecID
+	RawCodecID
+)
+
+// MinCustomCodecID is the lowest ID that can be used for registering custom codecs.
+const MinCustomCodecID CodecID = 64
+
+// DefaultRegistry is the Registry that's used by NewEnvelope if no other registry is configured.
+// It contains all codecs of this package.
+var DefaultRegistry = NewRegistry()
+
+// Registry maps codec IDs to codecs.
+// It's safe for concurrent use.
+type Registry struct {
+	codecs map[CodecID]Encoding
+	lock   *sync.RWMutex
+}
+
+// NewRegistry creates a new Registry that contains all codecs of this package.
+func NewRegistry() *Registry {
+	return &Registry{
+		codecs: map[CodecID]Encoding{
+			JSONCodecID:    JSON,
+			GobCodecID:     Gob,
+			ProtoCodecID:   Proto,
+			MsgPackCodecID: MsgPack,
+			CBORCodecID:    CBOR,
+			YAMLCodecID:    YAML,
+			RawCodecID:     Raw,
+		},
+		lock: new(sync.RWMutex),
+	}
+}
+
+// Register adds a codec to the registry.
+// The ID must not be lower than MinCustomCodecID and must not be registered yet.
+// As the ID is stored together with the values, it must not be used for a different codec later.
+func (r *Registry) Register(id CodecID, codec Encoding) error {
+	if id < MinCustomCodecID {
+		return fmt.Errorf("codec ID %d is reserved, custom codecs must use IDs from %d", id, MinCustomCodecID)
+	}
+	if codec == nil {
+		return fmt.Errorf("the codec for ID %d is nil", id)
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if _, ok := r.codecs[id]; ok {
+		return fmt.Errorf("codec ID %d is already registered", id)
+	}
+	r.codecs[id] = codec
+	return nil
+}
+
+// Codec returns the codec that's registered for the given ID.
+// If no codec is registered for the ID it returns (nil, false).
+func (r *Registry) Codec(id CodecID) (Encoding, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	codec, ok := r.codecs[id]
+	return codec, ok
+}
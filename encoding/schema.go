@@ -0,0 +1,145 @@
+package encoding
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Migration upgrades values from one schema version of a type to the next version.
+type Migration struct {
+	// Version that the migration upgrades from.
+	// The migration upgrades values to version From+1.
+	From uint64
+	// A value of the Go type that stored values of version From are decoded into, for example FooV1{}.
+	// It's only used for decoding when the value was stored with version From,
+	// otherwise Upgrade gets the result of the previous migration.
+	Old interface{}
+	// Upgrade converts a value of version From (of the type of Old) to a value of version From+1.
+	// The result of the last migration must be of the current type or a pointer to it.
+	Upgrade func(old interface{}) (interface{}, error)
+}
+
+// Schemas contains the current schema versions of Go types and the migrations for upgrading older versions.
+// It's safe for concurrent use.
+type Schemas struct {
+	schemas map[reflect.Type]schema
+	lock    *sync.RWMutex
+}
+
+type schema struct {
+	version    uint64
+	migrations map[uint64]Migration
+}
+
+// NewSchemas creates a new, empty Schemas object.
+func NewSchemas() *Schemas {
+	return &Schemas{
+		schemas: make(map[reflect.Type]schema),
+		lock:    new(sync.RWMutex),
+	}
+}
+
+// Register registers the Go type of the given value with its current schema version
+// and the migrations for upgrading values of older versions.
+// Versions start at 1. Values that were stored without a version are treated as version 1.
+// A migration for every version from the oldest stored version up to version-1 is required.
+func (s *Schemas) Register(current interface{}, version uint64, migrations ...Migration) error {
+	if current == nil {
+		return errors.New("the provided value is nil")
+	}
+	if version == 0 {
+		return errors.New("schema versions start at 1")
+	}
+
+	ms := make(map[uint64]Migration, len(migrations))
+	for _, m := range migrations {
+		if m.From == 0 || m.From >= version {
+			return fmt.Errorf("invalid migration from version %d to the current version %d", m.From, version)
+		}
+		if m.Old == nil || m.Upgrade == nil {
+			return fmt.Errorf("incomplete migration from version %d", m.From)
+		}
+		if _, ok := ms[m.From]; ok {
+			return fmt.Errorf("duplicate migration from version %d", m.From)
+		}
+		ms[m.From] = m
+	}
+
+	t := indirectType(reflect.TypeOf(current))
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.schemas[t]; ok {
+		return fmt.Errorf("type %v is already registered", t)
+	}
+	s.schemas[t] = schema{
+		version:    version,
+		migrations: ms,
+	}
+	return nil
+}
+
+// Version returns the current schema version of the Go type of the given value or pointer.
+// If the type isn't registered it returns (0, false).
+func (s *Schemas) Version(v interface{}) (uint64, bool) {
+	if v == nil {
+		return 0, false
+	}
+	schema, ok := s.get(indirectType(reflect.TypeOf(v)))
+	return schema.version, ok
+}
+
+func (s *Schemas) get(t reflect.Type) (schema, bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	schema, ok := s.schemas[t]
+	return schema, ok
+}
+
+// upgrade decodes data of the given version with the codec
+// and runs the migrations up to the current version of the schema.
+// The result is assigned to target, which must be a pointer to a value of the current type.
+func (sc schema) upgrade(codec Encoding, data []byte, version uint64, target reflect.Value) error {
+	m, ok := sc.migrations[version]
+	if !ok {
+		return fmt.Errorf("no migration from schema version %d", version)
+	}
+	old := reflect.New(reflect.TypeOf(m.Old))
+	if err := codec.Unmarshal(data, old.Interface()); err != nil {
+		return err
+	}
+
+	current := old.Elem().Interface()
+	for ; version < sc.version; version++ {
+		m, ok := sc.migrations[version]
+		if !ok {
+			return fmt.Errorf("no migration from schema version %d", version)
+		}
+		var err error
+		if current, err = m.Upgrade(current); err != nil {
+			return err
+		}
+	}
+
+	result := reflect.ValueOf(current)
+	if !result.IsValid() {
+		return errors.New("the migration to the current schema version returned nil")
+	}
+	if result.Kind() == reflect.Ptr && result.Type().Elem() == target.Type().Elem() {
+		result = result.Elem()
+	}
+	if result.Type() != target.Type().Elem() {
+		return fmt.Errorf("the migration to the current schema version returned a %v instead of a %v", result.Type(), target.Type().Elem())
+	}
+	target.Elem().Set(result)
+	return nil
+}
+
+// indirectType returns the type that t points to if it's a pointer type, otherwise t.
+func indirectType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Ptr {
+		return t.Elem()
+	}
+	return t
+}
@@ -0,0 +1,132 @@
+package encoding
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// VersionedValue can be passed to Unmarshal of an Encoding created with NewVersioned
+// (usually via the Get method of a store) to find out which schema version a value was stored with.
+type VersionedValue struct {
+	// Pointer to the value to decode into.
+	Value interface{}
+	// Schema version the value was stored with.
+	// It's set by Unmarshal, with 1 for values that were stored without version.
+	// It's 0 if the type isn't registered.
+	Version uint64
+}
+
+// VersionedOptions are the options for NewVersioned.
+type VersionedOptions struct {
+	// Schema versions and migrations of the stored types.
+	// Values of types that aren't registered are stored and retrieved without migrations.
+	Schemas *Schemas
+	// ID of the codec that's used for encoding values.
+	// Optional (JSONCodecID by default).
+	CodecID CodecID
+	// Registry that contains the codec for writing and all codecs that values might have been written with.
+	// Optional (DefaultRegistry by default).
+	Registry *Registry
+	// Codec for values that were written without envelope.
+	// Such values are treated as schema version 1.
+	// Optional (nil by default, which leads to an error for such values).
+	Legacy Encoding
+}
+
+// NewVersioned returns an Encoding that stores values with the current schema version of their type
+// in an Envelope (see NewEnvelope) and upgrades values of older versions
+// by running the registered migrations when decoding them.
+// Values of a version that's newer than the registered version lead to an error,
+// so that they don't silently lose fields.
+//
+// Upgraded values aren't written back by the Encoding. See package upgrade for a store wrapper that does that.
+func NewVersioned(options VersionedOptions) (Encoding, error) {
+	if options.Schemas == nil {
+		return nil, errors.New("the schemas must not be nil")
+	}
+	// Set default values
+	if options.CodecID == 0 {
+		options.CodecID = DefaultEnvelopeOptions.CodecID
+	}
+	if options.Registry == nil {
+		options.Registry = DefaultEnvelopeOptions.Registry
+	}
+
+	codec, ok := options.Registry.Codec(options.CodecID)
+	if !ok {
+		return nil, fmt.Errorf("no codec registered for ID %d", options.CodecID)
+	}
+
+	return versionedCodec{
+		codec:   codec,
+		options: options,
+	}, nil
+}
+
+// versionedCodec stores values with their schema version and upgrades old values.
+type versionedCodec struct {
+	codec   Encoding
+	options VersionedOptions
+}
+
+// Marshal encodes a Go value with the configured codec
+// and wraps it in an envelope with the current schema version of its type.
+func (c versionedCodec) Marshal(v interface{}) ([]byte, error) {
+	data, err := c.codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	version, _ := c.options.Schemas.Version(v)
+	return Envelope{
+		CodecID:       c.options.CodecID,
+		SchemaVersion: version,
+		Data:          data,
+	}.Bytes(), nil
+}
+
+// Unmarshal decodes an enveloped value with the codec that's referenced in the envelope
+// and upgrades it to the current schema version of the type of v.
+// v can be a *VersionedValue.
+func (c versionedCodec) Unmarshal(data []byte, v interface{}) error {
+	versioned, isVersioned := v.(*VersionedValue)
+	if isVersioned {
+		v = versioned.Value
+	}
+
+	codec, version := c.options.Legacy, uint64(0)
+	envelope, err := ParseEnvelope(data)
+	if err == nil {
+		var ok bool
+		if codec, ok = c.options.Registry.Codec(envelope.CodecID); !ok {
+			return fmt.Errorf("no codec registered for ID %d", envelope.CodecID)
+		}
+		data, version = envelope.Data, envelope.SchemaVersion
+	} else if err != ErrNoEnvelope || c.options.Legacy == nil {
+		return err
+	}
+
+	target := reflect.ValueOf(v)
+	if v == nil || target.Kind() != reflect.Ptr || target.IsNil() {
+		return codec.Unmarshal(data, v)
+	}
+	schema, ok := c.options.Schemas.get(target.Type().Elem())
+	if !ok {
+		return codec.Unmarshal(data, v)
+	}
+
+	if version == 0 {
+		version = 1
+	}
+	if isVersioned {
+		versioned.Version = version
+	}
+	switch {
+	case version == schema.version:
+		return codec.Unmarshal(data, v)
+	case version > schema.version:
+		return fmt.Errorf("the value has schema version %d, which is newer than the current version %d", version, schema.version)
+	default:
+		return schema.upgrade(codec, data, version, target)
+	}
+}
@@ -0,0 +1,155 @@
+package encoding_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/SpeedyCoder/gokv/encoding"
+)
+
+type userV1 struct {
+	Name string
+}
+
+type userV2 struct {
+	FirstName string
+	LastName  string
+}
+
+type user struct {
+	FirstName string
+	LastName  string
+	Admin     bool
+}
+
+func newUserSchemas(t *testing.T) *encoding.Schemas {
+	schemas := encoding.NewSchemas()
+	err := schemas.Register(user{}, 3,
+		encoding.Migration{
+			From: 1,
+			Old:  userV1{},
+			Upgrade: func(old interface{}) (interface{}, error) {
+				names := strings.SplitN(old.(userV1).Name, " ", 2)
+				return userV2{FirstName: names[0], LastName: names[len(names)-1]}, nil
+			},
+		},
+		encoding.Migration{
+			From: 2,
+			Old:  userV2{},
+			Upgrade: func(old interface{}) (interface{}, error) {
+				v2 := old.(userV2)
+				return &user{FirstName: v2.FirstName, LastName: v2.LastName}, nil
+			},
+		},
+	)
+	require.NoError(t, err)
+	return schemas
+}
+
+// TestVersioned tests if old values are upgraded to the current schema version.
+func TestVersioned(t *testing.T) {
+	assert := require.New(t)
+	schemas := newUserSchemas(t)
+	codec, err := encoding.NewVersioned(encoding.VersionedOptions{
+		Schemas: schemas,
+		Legacy:  encoding.JSON,
+	})
+	assert.NoError(err)
+	expected := user{FirstName: "Jane", LastName: "Doe"}
+
+	// Unversioned value is treated as version 1
+	data, err := encoding.JSON.Marshal(userV1{Name: "Jane Doe"})
+	assert.NoError(err)
+	actual := new(user)
+	assert.NoError(codec.Unmarshal(data, actual))
+	assert.Equal(expected, *actual)
+
+	// Version 2
+	v2, err := encoding.NewEnvelope(encoding.EnvelopeOptions{SchemaVersion: 2})
+	assert.NoError(err)
+	data, err = v2.Marshal(userV2{FirstName: "Jane", LastName: "Doe"})
+	assert.NoError(err)
+	versioned := &encoding.VersionedValue{Value: new(user)}
+	assert.NoError(codec.Unmarshal(data, versioned))
+	assert.Equal(expected, *versioned.Value.(*user))
+	assert.Equal(uint64(2), versioned.Version)
+
+	// Current version
+	expected.Admin = true
+	data, err = codec.Marshal(expected)
+	assert.NoError(err)
+	envelope, err := encoding.ParseEnvelope(data)
+	assert.NoError(err)
+	assert.Equal(uint64(3), envelope.SchemaVersion)
+	versioned = &encoding.VersionedValue{Value: new(user)}
+	assert.NoError(codec.Unmarshal(data, versioned))
+	assert.Equal(expected, *versioned.Value.(*user))
+	assert.Equal(uint64(3), versioned.Version)
+
+	// Newer version
+	v4, err := encoding.NewEnvelope(encoding.EnvelopeOptions{SchemaVersion: 4})
+	assert.NoError(err)
+	data, err = v4.Marshal(expected)
+	assert.NoError(err)
+	assert.Error(codec.Unmarshal(data, new(user)))
+
+	// Unregistered types are stored without version
+	data, err = codec.Marshal(foo{Bar: "baz"})
+	assert.NoError(err)
+	envelope, err = encoding.ParseEnvelope(data)
+	assert.NoError(err)
+	assert.Equal(uint64(0), envelope.SchemaVersion)
+	actualFoo := new(foo)
+	assert.NoError(codec.Unmarshal(data, actualFoo))
+	assert.Equal(foo{Bar: "baz"}, *actualFoo)
+}
+
+// TestSchemasErrors tests some error cases of registering schemas and running migrations.
+func TestSchemasErrors(t *testing.T) {
+	assert := require.New(t)
+	upgrade := func(old interface{}) (interface{}, error) { return user{}, nil }
+
+	schemas := encoding.NewSchemas()
+	assert.Error(schemas.Register(nil, 1))
+	assert.Error(schemas.Register(user{}, 0))
+	assert.Error(schemas.Register(user{}, 2, encoding.Migration{From: 2, Old: userV2{}, Upgrade: upgrade}))
+	assert.Error(schemas.Register(user{}, 2, encoding.Migration{From: 1, Upgrade: upgrade}))
+	assert.Error(schemas.Register(user{}, 3,
+		encoding.Migration{From: 2, Old: userV2{}, Upgrade: upgrade},
+		encoding.Migration{From: 2, Old: userV2{}, Upgrade: upgrade},
+	))
+	// Only a migration from version 2
+	assert.NoError(schemas.Register(&user{}, 3, encoding.Migration{From: 2, Old: userV2{}, Upgrade: upgrade}))
+	assert.Error(schemas.Register(user{}, 3))
+	version, ok := schemas.Version(new(user))
+	assert.True(ok)
+	assert.Equal(uint64(3), version)
+	_, ok = schemas.Version(foo{})
+	assert.False(ok)
+
+	_, err := encoding.NewVersioned(encoding.VersionedOptions{})
+	assert.Error(err)
+	codec, err := encoding.NewVersioned(encoding.VersionedOptions{
+		Schemas: schemas,
+		Legacy:  encoding.JSON,
+	})
+	assert.NoError(err)
+	// Missing migration from version 1
+	assert.Error(codec.Unmarshal([]byte(`{"Name":"Jane Doe"}`), new(user)))
+
+	// Migration that returns the wrong type
+	schemas = encoding.NewSchemas()
+	assert.NoError(schemas.Register(user{}, 2, encoding.Migration{
+		From:    1,
+		Old:     userV1{},
+		Upgrade: func(old interface{}) (interface{}, error) { return old, nil },
+	}))
+	codec, err = encoding.NewVersioned(encoding.VersionedOptions{
+		Schemas: schemas,
+		Legacy:  encoding.JSON,
+	})
+	assert.NoError(err)
+	assert.Error(codec.Unmarshal([]byte(`{"Name":"Jane Doe"}`), new(user)))
+}
@@ -0,0 +1,18 @@
+package encoding
+
+import (
+	"gopkg.in/yaml.v2"
+)
+
+// yamlCodec encodes/decodes Go values to/from YAML.
+type yamlCodec string
+
+// Marshal encodes a Go value to YAML.
+func (c yamlCodec) Marshal(v interface{}) ([]byte, error) {
+	return yaml.Marshal(v)
+}
+
+// Unmarshal decodes a YAML value into a Go value.
+func (c yamlCodec) Unmarshal(data []byte, v interface{}) error {
+	return yaml.Unmarshal(data, v)
+}
@@ -0,0 +1,21 @@
+package gokv
+
+import (
+	"context"
+	"time"
+)
+
+// ExpiringStore is a ContextStore that can store key-value pairs with a limited lifetime.
+// Implementations with native support for expiry (e.g. Redis, Memcached or etcd leases)
+// implement it directly, for all other stores the expiry package provides a wrapper
+// that emulates it.
+type ExpiringStore interface {
+	ContextStore
+	// SetWithTTL stores the given value for the given key.
+	// After the given time-to-live has passed the key-value pair is treated
+	// as if it had been deleted, so Get returns (false, nil) for it.
+	// Depending on the implementation the key might still show up in Keys()
+	// until the store actually removes it.
+	// The key must not be "", the value must not be nil and the ttl must be positive.
+	SetWithTTL(ctx context.Context, k string, v interface{}, ttl time.Duration) error
+}
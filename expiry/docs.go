@@ -0,0 +1,10 @@
+/*
+Package expiry contains a wrapper that adds per-key expiry to any `gokv.ContextStore`.
+
+Stores without native support for expiry (e.g. bbolt or a Go map) get the `gokv.ExpiringStore`
+interface by wrapping each value in an envelope that contains its expiry time.
+Expired key-value pairs are deleted lazily when they're read, if the wrapped store implements
+`gokv.ConditionalStore`. The deletion is conditional, so values that were set concurrently aren't lost.
+Other stores keep expired key-value pairs until they're overwritten or deleted.
+*/
+package expiry
@@ -0,0 +1,155 @@
+package expiry
+
+import (
+	"context"
+	"time"
+
+	"github.com/SpeedyCoder/gokv"
+	"github.com/SpeedyCoder/gokv/encoding"
+	"github.com/SpeedyCoder/gokv/internal/check"
+)
+
+// Options are the options for the expiry store.
+type Options struct {
+	// Encoding format of the wrapped values.
+	// The envelope itself is marshalled by the wrapped store.
+	// Optional (encoding.JSON by default).
+	Encoding encoding.Encoding
+}
+
+const (
+	DefaultEncoding = encoding.JSON
+)
+
+// NewStore creates a new gokv.ExpiringStore that emulates expiry on top of the given store.
+// If the given store already implements gokv.ExpiringStore, it's returned unchanged.
+// All key-value pairs must be written via the returned store,
+// because values are stored in an envelope that contains their expiry time.
+func NewStore(store gokv.ContextStore, options *Options) gokv.ExpiringStore {
+	if s, ok := store.(gokv.ExpiringStore); ok {
+		return s
+	}
+
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+
+	// Set default values
+	if opts.Encoding == nil {
+		opts.Encoding = DefaultEncoding
+	}
+
+	result := expiryStore{
+		store: store,
+		codec: opts.Encoding,
+	}
+	if conditional, ok := store.(gokv.ConditionalStore); ok {
+		result.conditional = conditional
+	}
+	return result
+}
+
+// envelope is what's actually stored in the wrapped store.
+type envelope struct {
+	// ExpiresAt is the expiry time in Unix nanoseconds. 0 means the value never expires.
+	ExpiresAt int64
+	Data      []byte
+}
+
+type expiryStore struct {
+	store gokv.ContextStore
+	// conditional is the wrapped store if it implements gokv.ConditionalStore, nil otherwise.
+	conditional gokv.ConditionalStore
+	codec       encoding.Encoding
+}
+
+// Set stores the given value for the given key without an expiry time.
+// The key must not be "" and the value must not be nil.
+func (s expiryStore) Set(ctx context.Context, k string, v interface{}) error {
+	if err := check.KeyAndValue(k, v); err != nil {
+		return err
+	}
+
+	return s.set(ctx, k, v, 0)
+}
+
+// SetWithTTL stores the given value for the given key.
+// After the ttl has passed Get doesn't return the value anymore.
+// The key must not be "", the value must not be nil and the ttl must be positive.
+func (s expiryStore) SetWithTTL(ctx context.Context, k string, v interface{}, ttl time.Duration) error {
+	if err := check.KeyAndValue(k, v); err != nil {
+		return err
+	}
+	if err := check.TTL(ttl); err != nil {
+		return err
+	}
+
+	return s.set(ctx, k, v, time.Now().Add(ttl).UnixNano())
+}
+
+func (s expiryStore) set(ctx context.Context, k string, v interface{}, expiresAt int64) error {
+	data, err := s.codec.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return s.store.Set(ctx, k, envelope{
+		ExpiresAt: expiresAt,
+		Data:      data,
+	})
+}
+
+// Get retrieves the stored value for the given key.
+// If the value is expired (false, nil) is returned.
+// If the wrapped store implements gokv.ConditionalStore, the expired value also gets deleted,
+// unless it was overwritten in the meantime.
+// The key must not be "" and the pointer must not be nil.
+func (s expiryStore) Get(ctx context.Context, k string, v interface{}) (found bool, err error) {
+	if err := check.KeyAndValue(k, v); err != nil {
+		return false, err
+	}
+
+	env := envelope{}
+	var version uint64
+	if s.conditional != nil {
+		found, version, err = s.conditional.GetWithVersion(ctx, k, &env)
+	} else {
+		found, err = s.store.Get(ctx, k, &env)
+	}
+	if err != nil || !found {
+		return false, err
+	}
+
+	if env.ExpiresAt != 0 && time.Now().UnixNano() >= env.ExpiresAt {
+		// Lazy deletion. A plain Delete would also delete a value that was set
+		// between the Get and the Delete, so without a conditional delete
+		// the expired value stays until it's overwritten or deleted.
+		if s.conditional == nil {
+			return false, nil
+		}
+		_, err = s.conditional.CompareAndDelete(ctx, k, version)
+		return false, err
+	}
+
+	return true, s.codec.Unmarshal(env.Data, v)
+}
+
+// Delete deletes the stored value for the given key.
+// Deleting a non-existing key-value pair does NOT lead to an error.
+// The key must not be "".
+func (s expiryStore) Delete(ctx context.Context, k string) error {
+	return s.store.Delete(ctx, k)
+}
+
+// Keys returns an iterator over all keys of the wrapped store.
+// Expired keys are included until they're deleted by a call to Get
+// (or until they're overwritten or deleted, if the wrapped store doesn't implement gokv.ConditionalStore).
+func (s expiryStore) Keys(ctx context.Context) gokv.KeysIterator {
+	return s.store.Keys(ctx)
+}
+
+// Close closes the wrapped store.
+func (s expiryStore) Close() error {
+	return s.store.Close()
+}
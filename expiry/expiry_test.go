@@ -0,0 +1,171 @@
+package expiry_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/SpeedyCoder/gokv"
+	"github.com/SpeedyCoder/gokv/encoding"
+	"github.com/SpeedyCoder/gokv/expiry"
+	"github.com/SpeedyCoder/gokv/internal/ctxconv"
+	"github.com/SpeedyCoder/gokv/internal/test"
+)
+
+// TestStore tests if reading from, writing to and deleting from the store works properly.
+// A struct is used as value. See TestTypes() for a test that is simpler but tests all types.
+func TestStore(t *testing.T) {
+	// Test with JSON
+	t.Run("JSON", func(t *testing.T) {
+		store, path := createStore(t, encoding.JSON)
+		defer test.CleanUp(store, path)
+		test.Store(ctxconv.ToStore(store), t)
+	})
+
+	// Test with gob
+	t.Run("gob", func(t *testing.T) {
+		store, path := createStore(t, encoding.Gob)
+		defer test.CleanUp(store, path)
+		test.Store(ctxconv.ToStore(store), t)
+	})
+}
+
+// TestTypes tests if setting and getting values works with all Go types.
+func TestTypes(t *testing.T) {
+	// Test with JSON
+	t.Run("JSON", func(t *testing.T) {
+		store, path := createStore(t, encoding.JSON)
+		defer test.CleanUp(store, path)
+		test.Types(ctxconv.ToStore(store), t)
+	})
+
+	// Test with gob
+	t.Run("gob", func(t *testing.T) {
+		store, path := createStore(t, encoding.Gob)
+		defer test.CleanUp(store, path)
+		test.Types(ctxconv.ToStore(store), t)
+	})
+}
+
+// TestExpiry tests if values stored with a TTL expire.
+func TestExpiry(t *testing.T) {
+	store, path := createStore(t, encoding.JSON)
+	defer test.CleanUp(store, path)
+	test.ExpiringStore(store, t)
+}
+
+// TestLazyDeletion tests if expired values are only deleted when the deletion can't remove a value
+// that was set after the expired one was read.
+func TestLazyDeletion(t *testing.T) {
+	ctx := context.Background()
+
+	// The value that's set concurrently must survive the lazy deletion
+	t.Run("conditional", func(t *testing.T) {
+		inner, path := test.NewBboltStore(t, nil)
+		racing := &racingStore{ConditionalStore: inner.(gokv.ConditionalStore)}
+		store := expiry.NewStore(racing, nil)
+		defer test.CleanUp(store, path)
+
+		err := store.SetWithTTL(ctx, "foo", "bar", time.Millisecond)
+		if err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+		racing.afterGet = func() {
+			if err := store.Set(ctx, "foo", "fresh"); err != nil {
+				t.Error(err)
+			}
+		}
+		var actual string
+		found, err := store.Get(ctx, "foo", &actual)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if found {
+			t.Error("Expected the expired value not to be found")
+		}
+		found, err = store.Get(ctx, "foo", &actual)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !found || actual != "fresh" {
+			t.Errorf("Expected the value that was set concurrently to be found, but got %v, %q", found, actual)
+		}
+	})
+
+	// Without conditional deletes the expired value must be kept
+	t.Run("plain", func(t *testing.T) {
+		inner, path := test.NewBboltStore(t, nil)
+		store := expiry.NewStore(plainStore{ContextStore: inner}, nil)
+		defer test.CleanUp(store, path)
+
+		err := store.SetWithTTL(ctx, "foo", "bar", time.Millisecond)
+		if err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+		found, err := store.Get(ctx, "foo", new(string))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if found {
+			t.Error("Expected the expired value not to be found")
+		}
+		found, err = inner.Get(ctx, "foo", new(interface{}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !found {
+			t.Error("Expected the expired value to be kept in the wrapped store")
+		}
+	})
+}
+
+// racingStore calls afterGet once after the next GetWithVersion,
+// to simulate a write between reading an expired value and deleting it.
+type racingStore struct {
+	gokv.ConditionalStore
+	afterGet func()
+}
+
+func (s *racingStore) GetWithVersion(ctx context.Context, k string, v interface{}) (bool, uint64, error) {
+	found, version, err := s.ConditionalStore.GetWithVersion(ctx, k, v)
+	if s.afterGet != nil {
+		afterGet := s.afterGet
+		s.afterGet = nil
+		afterGet()
+	}
+	return found, version, err
+}
+
+// plainStore hides all interfaces of the wrapped store except gokv.ContextStore.
+type plainStore struct {
+	gokv.ContextStore
+}
+
+// TestNative tests if a store that already implements gokv.ExpiringStore is returned as is.
+func TestNative(t *testing.T) {
+	inner, path := test.NewBboltStore(t, nil)
+	store := nativeStore{ContextStore: inner}
+	defer test.CleanUp(store, path)
+	if expiry.NewStore(store, nil) != gokv.ExpiringStore(store) {
+		t.Error("Expected the store to be returned unchanged")
+	}
+}
+
+// nativeStore pretends to implement gokv.ExpiringStore natively.
+type nativeStore struct {
+	gokv.ContextStore
+}
+
+func (s nativeStore) SetWithTTL(ctx context.Context, k string, v interface{}, _ time.Duration) error {
+	return s.Set(ctx, k, v)
+}
+
+func createStore(t *testing.T, codec encoding.Encoding) (gokv.ExpiringStore, string) {
+	inner, path := test.NewBboltStore(t, nil)
+	options := expiry.Options{
+		Encoding: codec,
+	}
+	return expiry.NewStore(inner, &options), path
+}
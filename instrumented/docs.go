@@ -0,0 +1,11 @@
+/*
+Package instrumented contains a wrapper that records metrics for the operations of a `gokv.ContextStore`.
+
+The metrics are passed to a Recorder, with implementations for
+Prometheus (`NewPrometheusRecorder()`) and OpenCensus (`NewOpenCensusRecorder()`).
+All metrics are labelled with the name of the store, so multiple stores can be told apart.
+
+Sizes of values are only recorded if the wrapped store reports them via `gokv.ReportValueSize()`,
+which `bbolt`, `mongodb` and `postgresql` do.
+*/
+package instrumented
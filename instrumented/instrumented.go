@@ -0,0 +1,127 @@
+package instrumented
+
+import (
+	"context"
+	"time"
+
+	"github.com/SpeedyCoder/gokv"
+	"github.com/SpeedyCoder/gokv/internal/iterator"
+)
+
+// Names of the operations that are passed to Recorder methods.
+const (
+	OpSet    = "set"
+	OpGet    = "get"
+	OpDelete = "delete"
+	OpKeys   = "keys"
+	OpClose  = "close"
+)
+
+// Recorder records the metrics of store operations.
+// Implementations must be safe for concurrent use.
+type Recorder interface {
+	// Operation is called after every operation with its duration and error.
+	// For OpKeys the duration is the time until the iteration finished.
+	Operation(ctx context.Context, store, op string, d time.Duration, err error)
+	// GetResult is called after every successful Get with whether the value was found.
+	GetResult(ctx context.Context, store string, found bool)
+	// KeysCount is called after an iteration over the keys with the number of iterated keys.
+	KeysCount(ctx context.Context, store string, count int)
+	// ValueSize is called with the size of the marshalled value of a Set (OpSet) or Get (OpGet)
+	// when the wrapped store reports it via gokv.ReportValueSize.
+	ValueSize(ctx context.Context, store, op string, size int)
+}
+
+// Options are the options for the instrumented store.
+type Options struct {
+	// Name of the store, which all metrics are labelled with.
+	// Optional ("default" by default).
+	Name string
+}
+
+// DefaultName is the default name of instrumented stores.
+const DefaultName = "default"
+
+// NewStore creates a new gokv.ContextStore that records metrics
+// for all operations of the given store with the given recorder.
+// The returned store only implements gokv.ContextStore,
+// so other interfaces that the wrapped store implements are hidden.
+func NewStore(store gokv.ContextStore, recorder Recorder, options *Options) gokv.ContextStore {
+	if options == nil {
+		options = &Options{}
+	}
+	// Set default values
+	name := options.Name
+	if name == "" {
+		name = DefaultName
+	}
+
+	return instrumentedStore{
+		store:    store,
+		recorder: recorder,
+		name:     name,
+	}
+}
+
+type instrumentedStore struct {
+	store    gokv.ContextStore
+	recorder Recorder
+	name     string
+}
+
+// Set stores the given value for the given key.
+// The key must not be "" and the value must not be nil.
+func (s instrumentedStore) Set(ctx context.Context, k string, v interface{}) error {
+	start := time.Now()
+	err := s.store.Set(s.reportValueSize(ctx, OpSet), k, v)
+	s.recorder.Operation(ctx, s.name, OpSet, time.Since(start), err)
+	return err
+}
+
+// Get retrieves the stored value for the given key.
+// If no value is found it returns (false, nil).
+// The key must not be "" and the pointer must not be nil.
+func (s instrumentedStore) Get(ctx context.Context, k string, v interface{}) (found bool, err error) {
+	start := time.Now()
+	found, err = s.store.Get(s.reportValueSize(ctx, OpGet), k, v)
+	s.recorder.Operation(ctx, s.name, OpGet, time.Since(start), err)
+	if err == nil {
+		s.recorder.GetResult(ctx, s.name, found)
+	}
+	return found, err
+}
+
+// Delete deletes the stored value for the given key.
+// Deleting a non-existing key-value pair does NOT lead to an error.
+// The key must not be "".
+func (s instrumentedStore) Delete(ctx context.Context, k string) error {
+	start := time.Now()
+	err := s.store.Delete(ctx, k)
+	s.recorder.Operation(ctx, s.name, OpDelete, time.Since(start), err)
+	return err
+}
+
+// Keys returns an iterator over all keys in the store.
+// The metrics are recorded when the iteration is finished.
+func (s instrumentedStore) Keys(ctx context.Context) gokv.KeysIterator {
+	start := time.Now()
+	return iterator.Observe(ctx, s.store.Keys(ctx), func(count int, err error) {
+		s.recorder.Operation(ctx, s.name, OpKeys, time.Since(start), err)
+		s.recorder.KeysCount(ctx, s.name, count)
+	})
+}
+
+// reportValueSize returns a context that passes the value size that the wrapped store reports to the recorder.
+func (s instrumentedStore) reportValueSize(ctx context.Context, op string) context.Context {
+	return gokv.WithValueSizeReporter(ctx, func(size int) {
+		s.recorder.ValueSize(ctx, s.name, op, size)
+	})
+}
+
+// Close closes the wrapped store.
+func (s instrumentedStore) Close() error {
+	start := time.Now()
+	err := s.store.Close()
+	s.recorder.Operation(context.Background(), s.name, OpClose, time.Since(start), err)
+	return err
+}
@@ -0,0 +1,188 @@
+package instrumented_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+	"go.opencensus.io/stats/view"
+
+	"github.com/SpeedyCoder/gokv/instrumented"
+	"github.com/SpeedyCoder/gokv/internal/ctxconv"
+	"github.com/SpeedyCoder/gokv/internal/test"
+	"github.com/SpeedyCoder/gokv/tracing"
+)
+
+// TestStore tests if reading from, writing to and deleting from the store works properly.
+func TestStore(t *testing.T) {
+	recorder := newRecorder()
+	store, path := test.NewBboltStore(t, nil)
+	defer test.CleanUp(store, path)
+
+	test.Store(ctxconv.ToStore(instrumented.NewStore(store, recorder, nil)), t)
+}
+
+// TestRecorder tests if all metrics are passed to the recorder.
+func TestRecorder(t *testing.T) {
+	assert := require.New(t)
+	ctx := context.Background()
+	recorder := newRecorder()
+	inner, path := test.NewBboltStore(t, nil)
+	store := instrumented.NewStore(inner, recorder, &instrumented.Options{Name: "test"})
+	defer test.CleanUp(store, path)
+
+	err := store.Set(ctx, "foo", test.Foo{Bar: "baz"})
+	assert.NoError(err)
+	_, err = store.Get(ctx, "foo", new(test.Foo))
+	assert.NoError(err)
+	_, err = store.Get(ctx, "bar", new(test.Foo))
+	assert.NoError(err)
+	_, err = store.Get(ctx, "", new(test.Foo))
+	assert.Error(err)
+	err = store.Delete(ctx, "bar")
+	assert.NoError(err)
+	it := store.Keys(ctx)
+	for range it.Ch() {
+	}
+	assert.NoError(it.Err())
+
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+	assert.Equal(map[string]int{
+		"test/set/success":    1,
+		"test/get/success":    2,
+		"test/get/error":      1,
+		"test/delete/success": 1,
+		"test/keys/success":   1,
+	}, recorder.operations)
+	assert.Equal(map[string]int{"test/true": 1, "test/false": 1}, recorder.getResults)
+	assert.Equal(1, recorder.keys["test"])
+	assert.Equal(map[string]int{"test/set": 13, "test/get": 13}, recorder.sizes)
+}
+
+// TestNested tests if value sizes are recorded when the store is wrapped by another wrapper that records them.
+func TestNested(t *testing.T) {
+	assert := require.New(t)
+	ctx := context.Background()
+	recorder := newRecorder()
+	tracer := tracing.NewMemoryTracer()
+	inner, path := test.NewBboltStore(t, nil)
+	store := instrumented.NewStore(tracing.NewStore(inner, tracer, nil), recorder, &instrumented.Options{Name: "test"})
+	defer test.CleanUp(store, path)
+
+	err := store.Set(ctx, "foo", test.Foo{Bar: "baz"})
+	assert.NoError(err)
+
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+	assert.Equal(map[string]int{"test/set": 13}, recorder.sizes)
+	assert.Equal(13, tracer.Spans()[0].Attributes[tracing.AttrValueSize])
+}
+
+// TestPrometheus tests if the Prometheus recorder registers and updates its metrics.
+func TestPrometheus(t *testing.T) {
+	assert := require.New(t)
+	ctx := context.Background()
+	registry := prometheus.NewRegistry()
+	options := &instrumented.PrometheusOptions{Registerer: registry}
+	recorder, err := instrumented.NewPrometheusRecorder(options)
+	assert.NoError(err)
+	// The default values aren't written into the passed options
+	assert.Equal(instrumented.PrometheusOptions{Registerer: registry}, *options)
+	// Registering the metrics twice fails
+	_, err = instrumented.NewPrometheusRecorder(&instrumented.PrometheusOptions{Registerer: registry})
+	assert.Error(err)
+
+	recorder.Operation(ctx, "test", instrumented.OpGet, time.Millisecond, nil)
+	recorder.GetResult(ctx, "test", true)
+	recorder.KeysCount(ctx, "test", 3)
+	recorder.ValueSize(ctx, "test", instrumented.OpSet, 100)
+
+	families, err := registry.Gather()
+	assert.NoError(err)
+	metrics := make(map[string][]*dto.Metric)
+	for _, family := range families {
+		metrics[family.GetName()] = family.GetMetric()
+	}
+	assert.Len(metrics, 5)
+	assert.Equal(1.0, metrics["gokv_operations_total"][0].GetCounter().GetValue())
+	assert.Equal(uint64(1), metrics["gokv_operation_duration_seconds"][0].GetHistogram().GetSampleCount())
+	assert.Equal(1.0, metrics["gokv_get_results_total"][0].GetCounter().GetValue())
+	assert.Equal(3.0, metrics["gokv_keys_iterated_total"][0].GetCounter().GetValue())
+	assert.Equal(100.0, metrics["gokv_value_size_bytes"][0].GetHistogram().GetSampleSum())
+}
+
+// TestOpenCensus tests if the OpenCensus recorder records its measures.
+func TestOpenCensus(t *testing.T) {
+	assert := require.New(t)
+	ctx := context.Background()
+	recorder, err := instrumented.NewOpenCensusRecorder()
+	assert.NoError(err)
+	defer view.Unregister(instrumented.OpenCensusViews...)
+
+	recorder.Operation(ctx, "test", instrumented.OpGet, time.Millisecond, nil)
+	recorder.GetResult(ctx, "test", false)
+	recorder.KeysCount(ctx, "test", 3)
+	recorder.ValueSize(ctx, "test", instrumented.OpSet, 100)
+
+	for _, v := range instrumented.OpenCensusViews {
+		rows, err := view.RetrieveData(v.Name)
+		assert.NoError(err)
+		assert.Len(rows, 1, v.Name)
+	}
+}
+
+// recorder records all metrics in maps.
+type recorder struct {
+	lock       *sync.Mutex
+	operations map[string]int
+	getResults map[string]int
+	keys       map[string]int
+	sizes      map[string]int
+}
+
+func newRecorder() *recorder {
+	return &recorder{
+		lock:       new(sync.Mutex),
+		operations: make(map[string]int),
+		getResults: make(map[string]int),
+		keys:       make(map[string]int),
+		sizes:      make(map[string]int),
+	}
+}
+
+func (r *recorder) Operation(_ context.Context, store, op string, _ time.Duration, err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	r.operations[store+"/"+op+"/"+result]++
+}
+
+func (r *recorder) GetResult(_ context.Context, store string, found bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if found {
+		r.getResults[store+"/true"]++
+	} else {
+		r.getResults[store+"/false"]++
+	}
+}
+
+func (r *recorder) KeysCount(_ context.Context, store string, count int) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.keys[store] += count
+}
+
+func (r *recorder) ValueSize(_ context.Context, store, op string, size int) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.sizes[store+"/"+op] += size
+}
@@ -0,0 +1,135 @@
+package instrumented
+
+import (
+	"context"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+// Measures that the OpenCensus recorder records.
+var (
+	// MeasureOperationDuration is the duration of store operations in milliseconds.
+	MeasureOperationDuration = stats.Float64("gokv/operation_duration", "Duration of store operations", stats.UnitMilliseconds)
+	// MeasureKeysIterated is the number of keys returned by iterations over the keys.
+	MeasureKeysIterated = stats.Int64("gokv/keys_iterated", "Number of keys returned by iterations over the keys", stats.UnitDimensionless)
+	// MeasureGetResult is recorded once for each successful Get, tagged with the result "hit" or "miss".
+	MeasureGetResult = stats.Int64("gokv/get_result", "Successful Get operations", stats.UnitDimensionless)
+	// MeasureValueSize is the size of marshalled values in bytes.
+	MeasureValueSize = stats.Int64("gokv/value_size", "Size of marshalled values", stats.UnitBytes)
+)
+
+// Tag keys of the measures.
+var (
+	KeyStore     = mustNewKey("gokv_store")
+	KeyOperation = mustNewKey("gokv_operation")
+	KeyResult    = mustNewKey("gokv_result")
+)
+
+// OpenCensusViews are the views for the measures of the OpenCensus recorder.
+// They're registered by NewOpenCensusRecorder.
+var OpenCensusViews = []*view.View{
+	{
+		Name:        "gokv/operation_count",
+		Description: "Number of store operations",
+		Measure:     MeasureOperationDuration,
+		TagKeys:     []tag.Key{KeyStore, KeyOperation, KeyResult},
+		Aggregation: view.Count(),
+	},
+	{
+		Name:        "gokv/operation_duration",
+		Description: "Duration of store operations",
+		Measure:     MeasureOperationDuration,
+		TagKeys:     []tag.Key{KeyStore, KeyOperation},
+		Aggregation: view.Distribution(0, 1, 2, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000),
+	},
+	{
+		Name:        "gokv/get_result_count",
+		Description: "Number of successful Get operations that found a value (hit) or didn't (miss)",
+		Measure:     MeasureGetResult,
+		TagKeys:     []tag.Key{KeyStore, KeyResult},
+		Aggregation: view.Count(),
+	},
+	{
+		Name:        "gokv/keys_iterated",
+		Description: "Number of keys returned by iterations over the keys",
+		Measure:     MeasureKeysIterated,
+		TagKeys:     []tag.Key{KeyStore},
+		Aggregation: view.Sum(),
+	},
+	{
+		Name:        "gokv/value_size",
+		Description: "Size of marshalled values",
+		Measure:     MeasureValueSize,
+		TagKeys:     []tag.Key{KeyStore, KeyOperation},
+		Aggregation: view.Distribution(0, 64, 256, 1<<10, 4<<10, 16<<10, 64<<10, 256<<10, 1<<20, 4<<20, 16<<20),
+	},
+}
+
+// NewOpenCensusRecorder creates a Recorder that records the metrics with OpenCensus
+// and registers the OpenCensusViews.
+// The tags of the context of the operations are kept, so the views can be extended with further tag keys.
+func NewOpenCensusRecorder() (Recorder, error) {
+	if err := view.Register(OpenCensusViews...); err != nil {
+		return nil, err
+	}
+	return openCensusRecorder{}, nil
+}
+
+type openCensusRecorder struct{}
+
+func (openCensusRecorder) Operation(ctx context.Context, store, op string, d time.Duration, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	record(ctx, []tag.Mutator{
+		tag.Upsert(KeyStore, store),
+		tag.Upsert(KeyOperation, op),
+		tag.Upsert(KeyResult, result),
+	}, MeasureOperationDuration.M(float64(d)/float64(time.Millisecond)))
+}
+
+func (openCensusRecorder) GetResult(ctx context.Context, store string, found bool) {
+	result := "miss"
+	if found {
+		result = "hit"
+	}
+	record(ctx, []tag.Mutator{
+		tag.Upsert(KeyStore, store),
+		tag.Upsert(KeyResult, result),
+	}, MeasureGetResult.M(1))
+}
+
+func (openCensusRecorder) KeysCount(ctx context.Context, store string, count int) {
+	record(ctx, []tag.Mutator{
+		tag.Upsert(KeyStore, store),
+	}, MeasureKeysIterated.M(int64(count)))
+}
+
+func (openCensusRecorder) ValueSize(ctx context.Context, store, op string, size int) {
+	record(ctx, []tag.Mutator{
+		tag.Upsert(KeyStore, store),
+		tag.Upsert(KeyOperation, op),
+	}, MeasureValueSize.M(int64(size)))
+}
+
+// record records the measurements with the given tags added to the tags of the context.
+func record(ctx context.Context, mutators []tag.Mutator, ms ...stats.Measurement) {
+	ctx, err := tag.New(ctx, mutators...)
+	if err != nil {
+		// Only happens for invalid tag values, which aren't worth failing the operation for
+		return
+	}
+	stats.Record(ctx, ms...)
+}
+
+func mustNewKey(name string) tag.Key {
+	k, err := tag.NewKey(name)
+	if err != nil {
+		panic(err)
+	}
+	return k
+}
@@ -0,0 +1,135 @@
+package instrumented
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PrometheusOptions are the options for the Prometheus recorder.
+type PrometheusOptions struct {
+	// Registerer that the metrics are registered with.
+	// Optional (prometheus.DefaultRegisterer by default).
+	Registerer prometheus.Registerer
+	// Namespace of the metrics.
+	// Optional ("gokv" by default).
+	Namespace string
+	// Buckets of the operation duration histogram, in seconds.
+	// Optional (prometheus.DefBuckets by default).
+	DurationBuckets []float64
+	// Buckets of the value size histogram, in bytes.
+	// Optional (64 bytes to 16 MiB by default).
+	SizeBuckets []float64
+}
+
+// DefaultPrometheusOptions is a PrometheusOptions object with default values.
+// Registerer: prometheus.DefaultRegisterer, Namespace: "gokv", DurationBuckets: prometheus.DefBuckets,
+// SizeBuckets: 64 bytes to 16 MiB, each bucket 4 times as big as the previous one
+var DefaultPrometheusOptions = PrometheusOptions{
+	Registerer:      prometheus.DefaultRegisterer,
+	Namespace:       "gokv",
+	DurationBuckets: prometheus.DefBuckets,
+	SizeBuckets:     prometheus.ExponentialBuckets(64, 4, 10),
+}
+
+// NewPrometheusRecorder creates a Recorder that records the metrics with Prometheus.
+// It registers the following metrics:
+//
+//	<namespace>_operations_total{store, operation, result}
+//	<namespace>_operation_duration_seconds{store, operation}
+//	<namespace>_get_results_total{store, result}
+//	<namespace>_keys_iterated_total{store}
+//	<namespace>_value_size_bytes{store, operation}
+//
+// result is "success" or "error" for operations and "hit" or "miss" for Get results.
+// Registering fails if the metrics are already registered with the Registerer,
+// so multiple stores must share a recorder.
+func NewPrometheusRecorder(options *PrometheusOptions) (Recorder, error) {
+	opts := PrometheusOptions{}
+	if options != nil {
+		opts = *options
+	}
+	// Set default values
+	if opts.Registerer == nil {
+		opts.Registerer = DefaultPrometheusOptions.Registerer
+	}
+	if opts.Namespace == "" {
+		opts.Namespace = DefaultPrometheusOptions.Namespace
+	}
+	if opts.DurationBuckets == nil {
+		opts.DurationBuckets = DefaultPrometheusOptions.DurationBuckets
+	}
+	if opts.SizeBuckets == nil {
+		opts.SizeBuckets = DefaultPrometheusOptions.SizeBuckets
+	}
+
+	r := prometheusRecorder{
+		operations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: opts.Namespace,
+			Name:      "operations_total",
+			Help:      "Number of store operations.",
+		}, []string{"store", "operation", "result"}),
+		durations: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: opts.Namespace,
+			Name:      "operation_duration_seconds",
+			Help:      "Duration of store operations.",
+			Buckets:   opts.DurationBuckets,
+		}, []string{"store", "operation"}),
+		getResults: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: opts.Namespace,
+			Name:      "get_results_total",
+			Help:      "Number of successful Get operations that found a value (hit) or didn't (miss).",
+		}, []string{"store", "result"}),
+		keys: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: opts.Namespace,
+			Name:      "keys_iterated_total",
+			Help:      "Number of keys returned by iterations over the keys.",
+		}, []string{"store"}),
+		sizes: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: opts.Namespace,
+			Name:      "value_size_bytes",
+			Help:      "Size of marshalled values.",
+			Buckets:   opts.SizeBuckets,
+		}, []string{"store", "operation"}),
+	}
+	for _, c := range []prometheus.Collector{r.operations, r.durations, r.getResults, r.keys, r.sizes} {
+		if err := opts.Registerer.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+type prometheusRecorder struct {
+	operations *prometheus.CounterVec
+	durations  *prometheus.HistogramVec
+	getResults *prometheus.CounterVec
+	keys       *prometheus.CounterVec
+	sizes      *prometheus.HistogramVec
+}
+
+func (r prometheusRecorder) Operation(_ context.Context, store, op string, d time.Duration, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	r.operations.WithLabelValues(store, op, result).Inc()
+	r.durations.WithLabelValues(store, op).Observe(d.Seconds())
+}
+
+func (r prometheusRecorder) GetResult(_ context.Context, store string, found bool) {
+	result := "miss"
+	if found {
+		result = "hit"
+	}
+	r.getResults.WithLabelValues(store, result).Inc()
+}
+
+func (r prometheusRecorder) KeysCount(_ context.Context, store string, count int) {
+	r.keys.WithLabelValues(store).Add(float64(count))
+}
+
+func (r prometheusRecorder) ValueSize(_ context.Context, store, op string, size int) {
+	r.sizes.WithLabelValues(store, op).Observe(float64(size))
+}
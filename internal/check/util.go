@@ -2,6 +2,8 @@ package check
 
 import (
 	"errors"
+	"io"
+	"time"
 )
 
 // Key returns an error if k == ""
@@ -22,3 +24,33 @@ func KeyAndValue(k string, v interface{}) error {
 	}
 	return nil
 }
+
+// KeyAndData returns an error if k == "" or if data == nil
+func KeyAndData(k string, data []byte) error {
+	if err := Key(k); err != nil {
+		return err
+	}
+	if data == nil {
+		return errors.New("the provided data is nil")
+	}
+	return nil
+}
+
+// KeyAndReader returns an error if k == "" or if r == nil
+func KeyAndReader(k string, r io.Reader) error {
+	if err := Key(k); err != nil {
+		return err
+	}
+	if r == nil {
+		return errors.New("the provided reader is nil")
+	}
+	return nil
+}
+
+// TTL returns an error if ttl <= 0
+func TTL(ttl time.Duration) error {
+	if ttl <= 0 {
+		return errors.New("the provided TTL is not positive")
+	}
+	return nil
+}
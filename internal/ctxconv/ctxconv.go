@@ -7,11 +7,21 @@ import (
 )
 
 // ToContextStore converts an instance of Store to ContextStore
-// that ignores the provided context.
+// that ignores the provided context,
+// unless the store has the methods SetContext, GetContext and DeleteContext,
+// like the clients of the SQL, Redis, DynamoDB and etcd backends,
+// which are called with the context instead.
 func ToContextStore(store gokv.Store) gokv.ContextStore {
 	return ctxStore{store: store}
 }
 
+// contextMethods are the methods of stores that take a context in addition to the gokv.Store methods.
+type contextMethods interface {
+	SetContext(ctx context.Context, k string, v interface{}) error
+	GetContext(ctx context.Context, k string, v interface{}) (found bool, err error)
+	DeleteContext(ctx context.Context, k string) error
+}
+
 // ToStore converts an instance of ContextStore to Store
 // that passes in context.Background to all actions.
 func ToStore(store gokv.ContextStore) gokv.Store {
@@ -22,15 +32,24 @@ type ctxStore struct {
 	store gokv.Store
 }
 
-func (s ctxStore) Set(_ context.Context, k string, v interface{}) error {
+func (s ctxStore) Set(ctx context.Context, k string, v interface{}) error {
+	if store, ok := s.store.(contextMethods); ok {
+		return store.SetContext(ctx, k, v)
+	}
 	return s.store.Set(k, v)
 }
 
-func (s ctxStore) Get(_ context.Context, k string, v interface{}) (found bool, err error) {
+func (s ctxStore) Get(ctx context.Context, k string, v interface{}) (found bool, err error) {
+	if store, ok := s.store.(contextMethods); ok {
+		return store.GetContext(ctx, k, v)
+	}
 	return s.store.Get(k, v)
 }
 
-func (s ctxStore) Delete(_ context.Context, k string) error {
+func (s ctxStore) Delete(ctx context.Context, k string) error {
+	if store, ok := s.store.(contextMethods); ok {
+		return store.DeleteContext(ctx, k)
+	}
 	return s.store.Delete(k)
 }
 
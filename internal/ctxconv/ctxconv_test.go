@@ -0,0 +1,73 @@
+package ctxconv_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/SpeedyCoder/gokv"
+	"github.com/SpeedyCoder/gokv/internal/ctxconv"
+)
+
+type contextKey struct{}
+
+// TestToContextStore tests that the context is passed to stores with context methods.
+func TestToContextStore(t *testing.T) {
+	assert := require.New(t)
+	ctx := context.WithValue(context.Background(), contextKey{}, "foo")
+
+	store := &contextStore{}
+	converted := ctxconv.ToContextStore(store)
+	assert.NoError(converted.Set(ctx, "foo", "bar"))
+	_, err := converted.Get(ctx, "foo", new(string))
+	assert.NoError(err)
+	assert.NoError(converted.Delete(ctx, "foo"))
+	assert.Equal([]string{"SetContext foo", "GetContext foo", "DeleteContext foo"}, store.calls)
+
+	plain := &plainStore{}
+	converted = ctxconv.ToContextStore(plain)
+	assert.NoError(converted.Set(ctx, "foo", "bar"))
+	assert.Equal([]string{"Set"}, plain.calls)
+}
+
+type plainStore struct {
+	calls []string
+}
+
+func (s *plainStore) Set(k string, v interface{}) error {
+	s.calls = append(s.calls, "Set")
+	return nil
+}
+
+func (s *plainStore) Get(k string, v interface{}) (bool, error) {
+	s.calls = append(s.calls, "Get")
+	return false, nil
+}
+
+func (s *plainStore) Delete(k string) error {
+	s.calls = append(s.calls, "Delete")
+	return nil
+}
+
+func (s *plainStore) Keys() gokv.KeysIterator { return nil }
+func (s *plainStore) Close() error            { return nil }
+
+type contextStore struct {
+	plainStore
+}
+
+func (s *contextStore) SetContext(ctx context.Context, k string, v interface{}) error {
+	s.calls = append(s.calls, "SetContext "+ctx.Value(contextKey{}).(string))
+	return nil
+}
+
+func (s *contextStore) GetContext(ctx context.Context, k string, v interface{}) (bool, error) {
+	s.calls = append(s.calls, "GetContext "+ctx.Value(contextKey{}).(string))
+	return false, nil
+}
+
+func (s *contextStore) DeleteContext(ctx context.Context, k string) error {
+	s.calls = append(s.calls, "DeleteContext "+ctx.Value(contextKey{}).(string))
+	return nil
+}
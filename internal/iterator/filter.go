@@ -0,0 +1,64 @@
+package iterator
+
+import (
+	"context"
+
+	"github.com/SpeedyCoder/gokv"
+)
+
+// Filter returns an Iterator over all keys of the given iterator for which keep returns true.
+func Filter(ctx context.Context, src gokv.KeysIterator, keep func(k string) bool) *Iterator {
+	return transform(ctx, src, func(k string) (string, bool) {
+		return k, keep(k)
+	}, nil)
+}
+
+// Map returns an Iterator over the keys of the given iterator, each transformed by f.
+func Map(ctx context.Context, src gokv.KeysIterator, f func(k string) string) *Iterator {
+	return transform(ctx, src, func(k string) (string, bool) {
+		return f(k), true
+	}, nil)
+}
+
+// Observe returns an Iterator over the keys of the given iterator,
+// which calls done with the number of keys and the error of the iteration when it's finished.
+func Observe(ctx context.Context, src gokv.KeysIterator, done func(count int, err error)) *Iterator {
+	return transform(ctx, src, func(k string) (string, bool) {
+		return k, true
+	}, done)
+}
+
+// transform returns an Iterator over the keys of the given iterator, each transformed by f.
+// Keys for which f returns false are skipped.
+// If done isn't nil it's called with the number of written keys and the error before the Iterator is closed.
+func transform(ctx context.Context, src gokv.KeysIterator, f func(k string) (string, bool), done func(count int, err error)) *Iterator {
+	it := New(ctx)
+	go func() {
+		var err error
+		count := 0
+		for k := range src.Ch() {
+			k, ok := f(k)
+			if !ok {
+				continue
+			}
+			if err = it.Write(k); err != nil {
+				break
+			}
+			count++
+		}
+		if err != nil {
+			// Drain the source so it doesn't block forever.
+			go func() {
+				for range src.Ch() {
+				}
+			}()
+		} else {
+			err = src.Err()
+		}
+		if done != nil {
+			done(count, err)
+		}
+		it.Close(err)
+	}()
+	return it
+}
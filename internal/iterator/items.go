@@ -0,0 +1,55 @@
+package iterator
+
+import (
+	"context"
+	"sync"
+
+	"github.com/SpeedyCoder/gokv"
+)
+
+// NewItems returns a new ItemsIterator.
+func NewItems(ctx context.Context) *ItemsIterator {
+	return &ItemsIterator{ctx: ctx, out: make(chan gokv.Item)}
+}
+
+// ItemsIterator is an implementation of the gokv.ItemsIterator that's used
+// by the various backends. It follows the same contract as Iterator.
+type ItemsIterator struct {
+	ctx      context.Context
+	out      chan gokv.Item
+	errMutex sync.RWMutex
+	done     bool
+	err      error
+}
+
+func (it *ItemsIterator) Ch() <-chan gokv.Item {
+	return it.out
+}
+
+func (it *ItemsIterator) Err() error {
+	it.errMutex.RLock()
+	defer it.errMutex.RUnlock()
+	if !it.done {
+		panic("iteration did not complete yet")
+	}
+
+	return it.err
+}
+
+func (it *ItemsIterator) Write(item gokv.Item) error {
+	select {
+	case <-it.ctx.Done():
+		return it.ctx.Err()
+	case it.out <- item:
+		return nil
+	}
+}
+
+func (it *ItemsIterator) Close(err error) {
+	it.errMutex.Lock()
+	defer it.errMutex.Unlock()
+
+	it.done = true
+	it.err = err
+	close(it.out)
+}
@@ -0,0 +1,126 @@
+/*
+Package pubsub contains an in-process publisher of `gokv.Event`s
+that's used by stores implementing `gokv.Watcher` without native support for it.
+*/
+package pubsub
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/SpeedyCoder/gokv"
+)
+
+// Hub distributes published events to all subscribers with a matching prefix.
+// Every subscriber has its own unbounded queue, so slow subscribers neither
+// block publishers nor miss events.
+type Hub struct {
+	lock        sync.Mutex
+	subscribers map[*subscriber]struct{}
+	done        chan struct{}
+	closed      bool
+}
+
+// NewHub creates a new Hub.
+func NewHub() *Hub {
+	return &Hub{
+		subscribers: make(map[*subscriber]struct{}),
+		done:        make(chan struct{}),
+	}
+}
+
+type subscriber struct {
+	prefix string
+	lock   sync.Mutex
+	queue  []gokv.Event
+	// notify is signaled when the queue gets new events.
+	notify chan struct{}
+	out    chan gokv.Event
+}
+
+// Subscribe returns a channel with all events published after the call
+// for keys that start with the given prefix.
+// The channel is closed when ctx is done or the hub is closed.
+func (h *Hub) Subscribe(ctx context.Context, prefix string) <-chan gokv.Event {
+	s := &subscriber{
+		prefix: prefix,
+		notify: make(chan struct{}, 1),
+		out:    make(chan gokv.Event),
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.closed {
+		close(s.out)
+		return s.out
+	}
+	h.subscribers[s] = struct{}{}
+	go h.deliver(ctx, s)
+	return s.out
+}
+
+// deliver writes the queued events of the subscriber to its channel.
+func (h *Hub) deliver(ctx context.Context, s *subscriber) {
+	defer func() {
+		h.lock.Lock()
+		delete(h.subscribers, s)
+		h.lock.Unlock()
+		close(s.out)
+	}()
+
+	for {
+		s.lock.Lock()
+		if len(s.queue) == 0 {
+			s.lock.Unlock()
+			select {
+			case <-s.notify:
+				continue
+			case <-ctx.Done():
+				return
+			case <-h.done:
+				return
+			}
+		}
+		e := s.queue[0]
+		s.queue = s.queue[1:]
+		s.lock.Unlock()
+
+		select {
+		case s.out <- e:
+		case <-ctx.Done():
+			return
+		case <-h.done:
+			return
+		}
+	}
+}
+
+// Publish queues the event for all subscribers with a matching prefix.
+func (h *Hub) Publish(e gokv.Event) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	for s := range h.subscribers {
+		if !strings.HasPrefix(e.Key, s.prefix) {
+			continue
+		}
+		s.lock.Lock()
+		s.queue = append(s.queue, e)
+		s.lock.Unlock()
+		select {
+		case s.notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Close closes the channels of all subscribers.
+// Subscribing after Close returns a closed channel.
+func (h *Hub) Close() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if !h.closed {
+		h.closed = true
+		close(h.done)
+	}
+}
@@ -1,25 +1,39 @@
 package sql
 
 import (
+	"context"
 	"database/sql"
 
+	"github.com/SpeedyCoder/gokv"
 	"github.com/SpeedyCoder/gokv/encoding"
 	"github.com/SpeedyCoder/gokv/internal/check"
+	"github.com/SpeedyCoder/gokv/internal/iterator"
 )
 
 // Client is a gokv.Store implementation for SQL databases.
+// The methods with a context are for the backends that implement gokv.ContextStore.
 type Client struct {
 	C          *sql.DB
 	UpsertStmt *sql.Stmt
 	GetStmt    *sql.Stmt
 	DeleteStmt *sql.Stmt
-	Codec      encoding.Encoding
+	// KeysStmt selects the keys of all rows.
+	KeysStmt *sql.Stmt
+	// Streams are the statements for streamed values,
+	// nil for the backends that don't implement gokv.StreamStore.
+	Streams *StreamStmts
+	Codec   encoding.Encoding
 }
 
 // Set stores the given value for the given key.
 // Values are automatically marshalled to JSON or gob (depending on the configuration).
 // The key must not be "" and the value must not be nil.
 func (c Client) Set(k string, v interface{}) error {
+	return c.SetContext(context.Background(), k, v)
+}
+
+// SetContext is like Set, but uses the given context for the query.
+func (c Client) SetContext(ctx context.Context, k string, v interface{}) error {
 	if err := check.KeyAndValue(k, v); err != nil {
 		return err
 	}
@@ -29,13 +43,9 @@ func (c Client) Set(k string, v interface{}) error {
 	if err != nil {
 		return err
 	}
+	gokv.ReportValueSize(ctx, len(data))
 
-	_, err = c.UpsertStmt.Exec(k, data)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return c.SetBytes(ctx, k, data)
 }
 
 // Get retrieves the stored value for the given key.
@@ -45,20 +55,20 @@ func (c Client) Set(k string, v interface{}) error {
 // If no value is found it returns (false, nil).
 // The key must not be "" and the pointer must not be nil.
 func (c Client) Get(k string, v interface{}) (found bool, err error) {
+	return c.GetContext(context.Background(), k, v)
+}
+
+// GetContext is like Get, but uses the given context for the query.
+func (c Client) GetContext(ctx context.Context, k string, v interface{}) (found bool, err error) {
 	if err := check.KeyAndValue(k, v); err != nil {
 		return false, err
 	}
 
-	// TODO: Consider using RawBytes.
-	dataPtr := new([]byte)
-	err = c.GetStmt.QueryRow(k).Scan(dataPtr)
-	// If no value was found return false
-	if err == sql.ErrNoRows {
-		return false, nil
-	} else if err != nil {
-		return false, err
+	data, found, err := c.GetBytes(ctx, k)
+	if err != nil || !found {
+		return found, err
 	}
-	data := *dataPtr
+	gokv.ReportValueSize(ctx, len(data))
 
 	return true, c.Codec.Unmarshal(data, v)
 }
@@ -67,14 +77,97 @@ func (c Client) Get(k string, v interface{}) (found bool, err error) {
 // Deleting a non-existing key-value pair does NOT lead to an error.
 // The key must not be "".
 func (c Client) Delete(k string) error {
+	return c.DeleteContext(context.Background(), k)
+}
+
+// DeleteContext is like Delete, but uses the given context for the query.
+// If the backend supports streamed values, the streamed value of the key is deleted as well.
+func (c Client) DeleteContext(ctx context.Context, k string) error {
 	if err := check.Key(k); err != nil {
 		return err
 	}
 
-	_, err := c.DeleteStmt.Exec(k)
+	if c.Streams == nil {
+		_, err := c.DeleteStmt.ExecContext(ctx, k)
+		return err
+	}
+
+	tx, err := c.C.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	// Rolling back after committing does nothing
+	defer tx.Rollback()
+	if _, err := tx.StmtContext(ctx, c.DeleteStmt).ExecContext(ctx, k); err != nil {
+		return err
+	}
+	if err := c.deleteStream(ctx, tx, k); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SetBytes stores the given data for the given key without marshalling it.
+// The key must not be "" and the data must not be nil.
+func (c Client) SetBytes(ctx context.Context, k string, data []byte) error {
+	if err := check.KeyAndData(k, data); err != nil {
+		return err
+	}
+
+	_, err := c.UpsertStmt.ExecContext(ctx, k, data)
 	return err
 }
 
+// GetBytes retrieves the data for the given key without unmarshalling it.
+// If no value is found it returns (nil, false, nil).
+// The key must not be "".
+func (c Client) GetBytes(ctx context.Context, k string) (data []byte, found bool, err error) {
+	if err := check.Key(k); err != nil {
+		return nil, false, err
+	}
+
+	// TODO: Consider using RawBytes.
+	err = c.GetStmt.QueryRowContext(ctx, k).Scan(&data)
+	// If no value was found return false
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	// Found data is never nil, even if it was stored empty
+	if data == nil {
+		data = []byte{}
+	}
+	return data, true, nil
+}
+
+// Keys returns an iterator over all keys in the table, in no particular order.
+func (c Client) Keys(ctx context.Context) gokv.KeysIterator {
+	it := iterator.New(ctx)
+	go func() {
+		rows, err := c.KeysStmt.QueryContext(ctx)
+		if err != nil {
+			it.Close(err)
+			return
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var k string
+			if err = rows.Scan(&k); err != nil {
+				break
+			}
+			if err = it.Write(k); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			err = rows.Err()
+		}
+		it.Close(err)
+	}()
+	return it
+}
+
 // Close closes the client.
 // It must be called to return all open connections to the connection pool and to release any open resources.
 func (c Client) Close() error {
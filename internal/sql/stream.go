@@ -0,0 +1,184 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+
+	"github.com/SpeedyCoder/gokv"
+	"github.com/SpeedyCoder/gokv/internal/check"
+	"github.com/SpeedyCoder/gokv/internal/stream"
+)
+
+// streamChunkSize is the size of the chunks that streamed values are split into.
+const streamChunkSize = 64 * 1024
+
+var (
+	errStreamReplaced = errors.New("the stream was replaced or deleted while reading it")
+	errStreamClosed   = errors.New("the stream is closed")
+	errNoStreams      = errors.New("the backend doesn't support streamed values")
+)
+
+// StreamStmts are the statements for values that are streamed with PutStream and OpenStream.
+// Streamed values are stored in a table of streams, which maps keys to the ID and size of their stream,
+// and a table of chunks with the primary key (k, id, n), where n is the index of the chunk in the stream.
+type StreamStmts struct {
+	// NextIDStmt selects a new, unique stream ID, which must be greater than 0.
+	NextIDStmt *sql.Stmt
+	// InsertChunkStmt inserts a chunk with the arguments k, id, n and data.
+	InsertChunkStmt *sql.Stmt
+	// GetChunkStmt selects the data of a chunk with the arguments k, id and n.
+	GetChunkStmt *sql.Stmt
+	// UpsertStmt inserts or updates the stream of a key with the arguments k, id and size.
+	UpsertStmt *sql.Stmt
+	// GetStmt selects the id and size of the stream of a key.
+	GetStmt *sql.Stmt
+	// DeleteStmt deletes the stream of a key.
+	DeleteStmt *sql.Stmt
+	// DeleteChunksStmt deletes the chunks of a key that don't belong to the stream with the given ID,
+	// with the arguments k and id.
+	DeleteChunksStmt *sql.Stmt
+}
+
+// PutStream stores the data that's read from r until io.EOF for the given key.
+// The data is split into chunks, which are inserted in one transaction,
+// so the value only becomes visible to OpenStream() when all chunks are written,
+// and the chunks of values that were never completed are rolled back.
+// The value doesn't have to fit into memory, but reading from r happens while the transaction is open.
+// Streamed values are independent of the values stored with SetContext(),
+// but DeleteContext() deletes both.
+// The key must not be "" and the reader must not be nil.
+func (c Client) PutStream(ctx context.Context, k string, r io.Reader, size int64) error {
+	if err := check.KeyAndReader(k, r); err != nil {
+		return err
+	}
+	if c.Streams == nil {
+		return errNoStreams
+	}
+
+	tx, err := c.C.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	// Rolling back after committing does nothing
+	defer tx.Rollback()
+
+	var id int64
+	if err := tx.StmtContext(ctx, c.Streams.NextIDStmt).QueryRowContext(ctx).Scan(&id); err != nil {
+		return err
+	}
+
+	insertChunk := tx.StmtContext(ctx, c.Streams.InsertChunkStmt)
+	r = stream.NewReader(ctx, r, size)
+	chunk := make([]byte, streamChunkSize)
+	var written int64
+	for n, done := 0, false; !done; n++ {
+		read, err := io.ReadFull(r, chunk)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			done = true
+		} else if err != nil {
+			return err
+		}
+		if read == 0 {
+			break
+		}
+		if _, err := insertChunk.ExecContext(ctx, k, id, n, chunk[:read]); err != nil {
+			return err
+		}
+		written += int64(read)
+	}
+
+	// Upserting locks the row of the key, so concurrent puts of the same key are serialized here
+	// and the last one deletes the chunks of all the others.
+	if _, err := tx.StmtContext(ctx, c.Streams.UpsertStmt).ExecContext(ctx, k, id, written); err != nil {
+		return err
+	}
+	if _, err := tx.StmtContext(ctx, c.Streams.DeleteChunksStmt).ExecContext(ctx, k, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// OpenStream returns a reader for the value that was streamed for the given key.
+// The chunks are selected one at a time.
+// If the value is replaced or deleted while it's being read, the reader returns an error.
+// If no value is found it returns gokv.ErrNotFound.
+// The key must not be "".
+func (c Client) OpenStream(ctx context.Context, k string) (io.ReadCloser, error) {
+	if err := check.Key(k); err != nil {
+		return nil, err
+	}
+	if c.Streams == nil {
+		return nil, errNoStreams
+	}
+
+	var id, size int64
+	err := c.Streams.GetStmt.QueryRowContext(ctx, k).Scan(&id, &size)
+	if err == sql.ErrNoRows {
+		return nil, gokv.ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &streamReader{
+		stmt:      c.Streams.GetChunkStmt,
+		ctx:       ctx,
+		k:         k,
+		id:        id,
+		remaining: size,
+	}, nil
+}
+
+// deleteStream deletes the stream of the given key and its chunks in the given transaction.
+func (c Client) deleteStream(ctx context.Context, tx *sql.Tx, k string) error {
+	if _, err := tx.StmtContext(ctx, c.Streams.DeleteStmt).ExecContext(ctx, k); err != nil {
+		return err
+	}
+	// No stream has the ID 0, so all chunks of the key are deleted
+	_, err := tx.StmtContext(ctx, c.Streams.DeleteChunksStmt).ExecContext(ctx, k, 0)
+	return err
+}
+
+// streamReader reads the chunks of a stream.
+type streamReader struct {
+	stmt      *sql.Stmt
+	ctx       context.Context
+	k         string
+	id        int64
+	next      int
+	buf       []byte
+	remaining int64
+	closed    bool
+}
+
+// Read reads from the current chunk and selects the next chunk when the current one is exhausted.
+func (r *streamReader) Read(p []byte) (int, error) {
+	if r.closed {
+		return 0, errStreamClosed
+	}
+	if len(r.buf) == 0 {
+		if r.remaining <= 0 {
+			return 0, io.EOF
+		}
+		err := r.stmt.QueryRowContext(r.ctx, r.k, r.id, r.next).Scan(&r.buf)
+		if err == sql.ErrNoRows || (err == nil && len(r.buf) == 0) {
+			return 0, errStreamReplaced
+		} else if err != nil {
+			return 0, err
+		}
+		r.next++
+	}
+
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	r.remaining -= int64(n)
+	return n, nil
+}
+
+// Close releases the buffered chunk. Reading after closing leads to an error.
+func (r *streamReader) Close() error {
+	r.closed = true
+	r.buf = nil
+	return nil
+}
@@ -0,0 +1,50 @@
+/*
+Package stream contains utilities for implementations of `gokv.StreamStore`.
+*/
+package stream
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// NewReader returns a reader that reads from r until io.EOF,
+// but returns an error instead of io.EOF when r doesn't return the expected number of bytes,
+// or as soon as ctx is done.
+// A negative size means that the size is unknown, so any number of bytes is accepted.
+// If size isn't negative, at most size+1 bytes are read from r,
+// which is enough to detect that the reader returns more data than expected.
+func NewReader(ctx context.Context, r io.Reader, size int64) io.Reader {
+	if size >= 0 {
+		r = io.LimitReader(r, size+1)
+	}
+	return &reader{
+		ctx:  ctx,
+		r:    r,
+		size: size,
+	}
+}
+
+type reader struct {
+	ctx  context.Context
+	r    io.Reader
+	size int64
+	read int64
+}
+
+// Read reads from the wrapped reader and checks the number of bytes that it returned.
+func (r *reader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := r.r.Read(p)
+	r.read += int64(n)
+	if r.size >= 0 && r.read > r.size {
+		return n, fmt.Errorf("expected %d bytes, but the reader returned more", r.size)
+	}
+	if err == io.EOF && r.size >= 0 && r.read != r.size {
+		return n, fmt.Errorf("expected %d bytes, but the reader returned %d", r.size, r.read)
+	}
+	return n, err
+}
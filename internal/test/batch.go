@@ -0,0 +1,70 @@
+package test
+
+import (
+	"context"
+	"math/rand"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/SpeedyCoder/gokv"
+)
+
+// BatchStore tests if setting, getting and deleting multiple key-value pairs at once works properly.
+func BatchStore(store gokv.BatchStore, t *testing.T) {
+	assert := require.New(t)
+	ctx := context.Background()
+	prefix := strconv.FormatInt(rand.Int63(), 10)
+
+	kvs := make(map[string]interface{})
+	keys := make([]string, 0)
+	for i := 0; i < 25; i++ {
+		k := prefix + "-" + strconv.Itoa(i)
+		kvs[k] = Foo{Bar: strconv.Itoa(i)}
+		keys = append(keys, k)
+	}
+	missingKey := prefix + "-missing"
+	newValue := func() interface{} { return new(Foo) }
+
+	// Initially none of the keys should exist
+	result, err := store.GetMany(ctx, keys, newValue)
+	assert.NoError(err)
+	assert.Empty(result)
+
+	// Empty keys must be rejected
+	err = store.SetMany(ctx, map[string]interface{}{"": Foo{}})
+	assert.Error(err)
+	_, err = store.GetMany(ctx, []string{""}, newValue)
+	assert.Error(err)
+	err = store.DeleteMany(ctx, []string{""})
+	assert.Error(err)
+
+	// Store all
+	err = store.SetMany(ctx, kvs)
+	assert.NoError(err)
+
+	// Retrieve all plus one that doesn't exist
+	result, err = store.GetMany(ctx, append(keys, missingKey), newValue)
+	assert.NoError(err)
+	assert.Len(result, len(kvs))
+	for k, v := range kvs {
+		actual, ok := result[k]
+		assert.True(ok, "No value was found for key %v, but should have been", k)
+		assert.Equal(v, *actual.(*Foo))
+	}
+
+	// The values must also be visible to the single key methods
+	actual := new(Foo)
+	found, err := store.Get(ctx, keys[0], actual)
+	assert.NoError(err)
+	assert.True(found, "No value was found, but should have been")
+	assert.Equal(kvs[keys[0]], *actual)
+
+	// Delete all plus one that doesn't exist
+	err = store.DeleteMany(ctx, append(keys, missingKey))
+	assert.NoError(err)
+	result, err = store.GetMany(ctx, keys, newValue)
+	assert.NoError(err)
+	assert.Empty(result)
+}
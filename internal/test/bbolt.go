@@ -0,0 +1,54 @@
+package test
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SpeedyCoder/gokv"
+	"github.com/SpeedyCoder/gokv/backends/bbolt"
+)
+
+// NewBboltStore creates a bbolt store in a new temporary directory, for tests of wrappers that need a store to wrap.
+// The options can be nil. Their Path is replaced by a file in the temporary directory,
+// which is returned, so the store can be reopened.
+// The store must be cleaned up with CleanUp.
+func NewBboltStore(t *testing.T, options *bbolt.Options) (gokv.ContextStore, string) {
+	dir, err := ioutil.TempDir(os.TempDir(), "gokv")
+	if err != nil {
+		t.Fatalf("Generating random DB path failed: %v", err)
+	}
+	// bbolt sets the default values in the passed options
+	opts := bbolt.Options{}
+	if options != nil {
+		opts = *options
+	}
+	opts.Path = filepath.Join(dir, "bbolt.db")
+	store, err := bbolt.NewContextStore(&opts)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return store, opts.Path
+}
+
+// CleanUp closes the store and deletes the temporary directories of the given paths,
+// which must have been returned by NewBboltStore.
+// The store can be nil if it was closed already.
+// If an error occurs the test is NOT marked as failed.
+func CleanUp(store gokv.ContextStore, paths ...string) {
+	if store != nil {
+		err := store.Close()
+		if err != nil {
+			log.Printf("Error during cleaning up after a test (during closing the store): %v\n", err)
+		}
+	}
+	for _, path := range paths {
+		err := os.RemoveAll(filepath.Dir(path))
+		if err != nil {
+			log.Printf("Error during cleaning up after a test (during removing the data directory): %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package test
+
+import (
+	"context"
+	"math/rand"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/SpeedyCoder/gokv"
+)
+
+// ConditionalStore tests if SetIfNotExists, CompareAndSwap and CompareAndDelete only write when their condition holds.
+func ConditionalStore(store gokv.ConditionalStore, t *testing.T) {
+	assert := require.New(t)
+	ctx := context.Background()
+	key := strconv.FormatInt(rand.Int63(), 10)
+
+	// Swapping a non-existing key-value pair must not work
+	swapped, err := store.CompareAndSwap(ctx, key, 0, Foo{Bar: "baz"})
+	assert.NoError(err)
+	assert.False(swapped, "The value was swapped, but shouldn't have been")
+	found, _, err := store.GetWithVersion(ctx, key, new(Foo))
+	assert.NoError(err)
+	assert.False(found, "A value was found, but no value was expected")
+
+	// Only the first write must succeed
+	stored, err := store.SetIfNotExists(ctx, key, Foo{Bar: "first"})
+	assert.NoError(err)
+	assert.True(stored, "The value wasn't stored, but should have been")
+	stored, err = store.SetIfNotExists(ctx, key, Foo{Bar: "second"})
+	assert.NoError(err)
+	assert.False(stored, "The value was stored, but shouldn't have been")
+
+	actual := new(Foo)
+	found, version, err := store.GetWithVersion(ctx, key, actual)
+	assert.NoError(err)
+	assert.True(found, "No value was found, but should have been")
+	assert.Equal(Foo{Bar: "first"}, *actual)
+
+	// Swapping with the current version must work exactly once
+	swapped, err = store.CompareAndSwap(ctx, key, version, Foo{Bar: "swapped"})
+	assert.NoError(err)
+	assert.True(swapped, "The value wasn't swapped, but should have been")
+	swapped, err = store.CompareAndSwap(ctx, key, version, Foo{Bar: "swapped again"})
+	assert.NoError(err)
+	assert.False(swapped, "The value was swapped, but shouldn't have been")
+
+	found, err = store.Get(ctx, key, actual)
+	assert.NoError(err)
+	assert.True(found, "No value was found, but should have been")
+	assert.Equal(Foo{Bar: "swapped"}, *actual)
+
+	// The version must change with every write, even if the value is written back (ABA)
+	_, version, err = store.GetWithVersion(ctx, key, actual)
+	assert.NoError(err)
+	err = store.Set(ctx, key, Foo{Bar: "other"})
+	assert.NoError(err)
+	err = store.Set(ctx, key, Foo{Bar: "swapped"})
+	assert.NoError(err)
+	swapped, err = store.CompareAndSwap(ctx, key, version, Foo{Bar: "stale"})
+	assert.NoError(err)
+	assert.False(swapped, "The value was swapped with a stale version, but shouldn't have been")
+
+	// After deletion the key is free again, but the version of the old value must not match the new one
+	_, version, err = store.GetWithVersion(ctx, key, actual)
+	assert.NoError(err)
+	err = store.Delete(ctx, key)
+	assert.NoError(err)
+	stored, err = store.SetIfNotExists(ctx, key, Foo{Bar: "swapped"})
+	assert.NoError(err)
+	assert.True(stored, "The value wasn't stored, but should have been")
+	swapped, err = store.CompareAndSwap(ctx, key, version, Foo{Bar: "stale"})
+	assert.NoError(err)
+	assert.False(swapped, "The value was swapped with a stale version, but shouldn't have been")
+
+	// Deleting with a stale version must not work, deleting with the current version must work exactly once
+	deleted, err := store.CompareAndDelete(ctx, key, version)
+	assert.NoError(err)
+	assert.False(deleted, "The value was deleted with a stale version, but shouldn't have been")
+	_, version, err = store.GetWithVersion(ctx, key, actual)
+	assert.NoError(err)
+	deleted, err = store.CompareAndDelete(ctx, key, version)
+	assert.NoError(err)
+	assert.True(deleted, "The value wasn't deleted, but should have been")
+	deleted, err = store.CompareAndDelete(ctx, key, version)
+	assert.NoError(err)
+	assert.False(deleted, "A non-existing value was deleted")
+	found, err = store.Get(ctx, key, actual)
+	assert.NoError(err)
+	assert.False(found, "A value was found, but no value was expected")
+
+	// Concurrent writers: exactly one must win
+	goroutineCount := 50
+	var winners int32
+	waitGroup := sync.WaitGroup{}
+	waitGroup.Add(goroutineCount)
+	for i := 0; i < goroutineCount; i++ {
+		go func(i int) {
+			defer waitGroup.Done()
+			stored, err := store.SetIfNotExists(ctx, key, Foo{Bar: strconv.Itoa(i)})
+			if err != nil {
+				t.Error(err)
+			}
+			if stored {
+				atomic.AddInt32(&winners, 1)
+			}
+		}(i)
+	}
+	waitGroup.Wait()
+	assert.Equal(int32(1), winners)
+
+	err = store.Delete(ctx, key)
+	assert.NoError(err)
+}
@@ -0,0 +1,59 @@
+package test
+
+import (
+	"context"
+	"math/rand"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/SpeedyCoder/gokv"
+)
+
+// ExpiringStore tests if key-value pairs stored with a TTL expire and
+// if key-value pairs stored without one don't.
+func ExpiringStore(store gokv.ExpiringStore, t *testing.T) {
+	assert := require.New(t)
+	ctx := context.Background()
+	key := strconv.FormatInt(rand.Int63(), 10)
+	otherKey := key + "-other"
+
+	// Invalid TTLs must be rejected
+	err := store.SetWithTTL(ctx, key, Foo{Bar: "baz"}, 0)
+	assert.Error(err)
+	err = store.SetWithTTL(ctx, key, Foo{Bar: "baz"}, -time.Second)
+	assert.Error(err)
+
+	// Store one value with and one without TTL.
+	// Some implementations only support a granularity of seconds.
+	val := Foo{Bar: "baz"}
+	err = store.SetWithTTL(ctx, key, val, time.Second)
+	assert.NoError(err)
+	err = store.Set(ctx, otherKey, val)
+	assert.NoError(err)
+
+	// Before expiry the value must be there
+	actual := new(Foo)
+	found, err := store.Get(ctx, key, actual)
+	assert.NoError(err)
+	assert.True(found, "No value was found, but should have been")
+	assert.Equal(val, *actual)
+
+	time.Sleep(2 * time.Second)
+
+	// After expiry it must be gone
+	found, err = store.Get(ctx, key, new(Foo))
+	assert.NoError(err)
+	assert.False(found, "A value was found, but no value was expected")
+
+	// The value without TTL must still be there
+	found, err = store.Get(ctx, otherKey, actual)
+	assert.NoError(err)
+	assert.True(found, "No value was found, but should have been")
+	assert.Equal(val, *actual)
+
+	err = store.Delete(ctx, otherKey)
+	assert.NoError(err)
+}
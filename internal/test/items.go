@@ -0,0 +1,50 @@
+package test
+
+import (
+	"context"
+	"math/rand"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/SpeedyCoder/gokv"
+)
+
+// ItemsStore tests if iterating over all key-value pairs works properly.
+// The store must be empty before the test.
+func ItemsStore(store gokv.ItemsStore, t *testing.T) {
+	assert := require.New(t)
+	ctx := context.Background()
+	base := strconv.FormatInt(rand.Int63(), 10) + "-"
+
+	expected := make(map[string]Foo)
+	for i := 0; i < 250; i++ {
+		k := base + strconv.Itoa(i)
+		expected[k] = Foo{Bar: strconv.Itoa(i)}
+		err := store.Set(ctx, k, expected[k])
+		assert.NoError(err)
+	}
+
+	actual := make(map[string]Foo)
+	it := store.Items(ctx, func() interface{} { return new(Foo) })
+	for item := range it.Ch() {
+		actual[item.Key] = *item.Value.(*Foo)
+	}
+	assert.NoError(it.Err())
+	assert.Equal(expected, actual)
+
+	// Stop iterating early
+	cancelCtx, cancel := context.WithCancel(ctx)
+	it = store.Items(cancelCtx, func() interface{} { return new(Foo) })
+	<-it.Ch()
+	cancel()
+	for range it.Ch() {
+	}
+	assert.Error(it.Err())
+
+	for k := range expected {
+		err := store.Delete(ctx, k)
+		assert.NoError(err)
+	}
+}
@@ -0,0 +1,51 @@
+package test
+
+import (
+	"context"
+	"math/rand"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/SpeedyCoder/gokv"
+)
+
+// RangeStore tests if iterating over the keys with a given prefix or in a given range works properly.
+func RangeStore(store gokv.RangeStore, t *testing.T) {
+	assert := require.New(t)
+	ctx := context.Background()
+	// The keys don't contain "/", so that the test works for namespaces as well.
+	base := strconv.FormatInt(rand.Int63(), 10) + ":"
+
+	keys := []string{base + "a:1", base + "a:2", base + "a:3", base + "ab", base + "b:1"}
+	for _, k := range keys {
+		err := store.Set(ctx, k, Foo{Bar: k})
+		assert.NoError(err)
+	}
+
+	collect := func(it gokv.KeysIterator) []string {
+		result := make([]string, 0)
+		for k := range it.Ch() {
+			result = append(result, k)
+		}
+		assert.NoError(it.Err())
+		return result
+	}
+
+	// Prefixes
+	assert.ElementsMatch([]string{base + "a:1", base + "a:2", base + "a:3"}, collect(store.KeysWithPrefix(ctx, base+"a:")))
+	assert.ElementsMatch([]string{base + "a:1", base + "a:2", base + "a:3", base + "ab"}, collect(store.KeysWithPrefix(ctx, base+"a")))
+	assert.ElementsMatch(keys, collect(store.KeysWithPrefix(ctx, base)))
+	assert.Empty(collect(store.KeysWithPrefix(ctx, base+"c")))
+
+	// Ranges
+	assert.ElementsMatch([]string{base + "a:2", base + "a:3", base + "ab"}, collect(store.KeysInRange(ctx, base+"a:2", base+"b")))
+	assert.ElementsMatch([]string{base + "a:2", base + "a:3", base + "ab", base + "b:1"}, collect(store.KeysInRange(ctx, base+"a:2", base+"c")))
+	assert.Empty(collect(store.KeysInRange(ctx, base+"a:4", base+"ab")))
+
+	for _, k := range keys {
+		err := store.Delete(ctx, k)
+		assert.NoError(err)
+	}
+}
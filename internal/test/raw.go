@@ -0,0 +1,66 @@
+package test
+
+import (
+	"context"
+	"math/rand"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/SpeedyCoder/gokv"
+)
+
+// RawStore tests if storing and retrieving values without the codec works properly.
+func RawStore(store gokv.RawStore, t *testing.T) {
+	assert := require.New(t)
+	ctx := context.Background()
+	key := strconv.FormatInt(rand.Int63(), 10)
+
+	// Initially the key shouldn't exist
+	data, found, err := store.GetBytes(ctx, key)
+	assert.NoError(err)
+	assert.False(found, "A value was found, but no value was expected")
+	assert.Nil(data)
+
+	// Arbitrary binary data
+	expected := make([]byte, 1<<16)
+	rand.Read(expected)
+	err = store.SetBytes(ctx, key, expected)
+	assert.NoError(err)
+	data, found, err = store.GetBytes(ctx, key)
+	assert.NoError(err)
+	assert.True(found, "No value was found, but should have been")
+	assert.Equal(expected, data)
+
+	// Empty data
+	err = store.SetBytes(ctx, key, []byte{})
+	assert.NoError(err)
+	data, found, err = store.GetBytes(ctx, key)
+	assert.NoError(err)
+	assert.True(found, "No value was found, but should have been")
+	assert.Empty(data)
+
+	// Values stored via Set can be read as bytes
+	err = store.Set(ctx, key, Foo{Bar: "baz"})
+	assert.NoError(err)
+	data, found, err = store.GetBytes(ctx, key)
+	assert.NoError(err)
+	assert.True(found, "No value was found, but should have been")
+	assert.NotEmpty(data)
+
+	// Deleting works as usual
+	err = store.Delete(ctx, key)
+	assert.NoError(err)
+	_, found, err = store.GetBytes(ctx, key)
+	assert.NoError(err)
+	assert.False(found, "A value was found, but no value was expected")
+
+	// Errors
+	err = store.SetBytes(ctx, "", []byte("foo"))
+	assert.Error(err)
+	err = store.SetBytes(ctx, key, nil)
+	assert.Error(err)
+	_, _, err = store.GetBytes(ctx, "")
+	assert.Error(err)
+}
@@ -0,0 +1,88 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/SpeedyCoder/gokv"
+)
+
+// StreamStore tests if storing and retrieving values as streams works properly.
+func StreamStore(store gokv.StreamStore, t *testing.T) {
+	assert := require.New(t)
+	ctx := context.Background()
+	key := strconv.FormatInt(rand.Int63(), 10)
+
+	// Initially the key shouldn't exist
+	_, err := store.Open(ctx, key)
+	assert.Equal(gokv.ErrNotFound, err)
+
+	// A value that's bigger than the chunks of typical implementations, with a size that isn't aligned
+	expected := make([]byte, 10<<20+123)
+	rand.Read(expected)
+	err = store.Put(ctx, key, bytes.NewReader(expected), int64(len(expected)))
+	assert.NoError(err)
+	assertStream(t, store, key, expected)
+
+	// Replace it with a smaller value of unknown size
+	err = store.Put(ctx, key, strings.NewReader("foo"), -1)
+	assert.NoError(err)
+	assertStream(t, store, key, []byte("foo"))
+
+	// Wrong size doesn't replace the value
+	err = store.Put(ctx, key, strings.NewReader("foobar"), 3)
+	assert.Error(err)
+	err = store.Put(ctx, key, strings.NewReader("f"), 3)
+	assert.Error(err)
+	assertStream(t, store, key, []byte("foo"))
+
+	// Empty value
+	err = store.Put(ctx, key, strings.NewReader(""), 0)
+	assert.NoError(err)
+	assertStream(t, store, key, []byte{})
+
+	// Canceled context
+	canceledCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	err = store.Put(canceledCtx, key, strings.NewReader("bar"), 3)
+	assert.Error(err)
+	assertStream(t, store, key, []byte{})
+
+	// Reading from a closed stream
+	r, err := store.Open(ctx, key)
+	assert.NoError(err)
+	assert.NoError(r.Close())
+	_, err = r.Read(make([]byte, 1))
+	assert.Error(err)
+
+	// Delete
+	err = store.Delete(ctx, key)
+	assert.NoError(err)
+	_, err = store.Open(ctx, key)
+	assert.Equal(gokv.ErrNotFound, err)
+
+	// Errors
+	err = store.Put(ctx, "", strings.NewReader("foo"), 3)
+	assert.Error(err)
+	err = store.Put(ctx, key, nil, 0)
+	assert.Error(err)
+	_, err = store.Open(ctx, "")
+	assert.Error(err)
+}
+
+func assertStream(t *testing.T, store gokv.StreamStore, key string, expected []byte) {
+	r, err := store.Open(context.Background(), key)
+	require.NoError(t, err)
+	defer r.Close()
+	actual, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, len(expected), len(actual))
+	require.True(t, bytes.Equal(expected, actual), "The streamed value differs from the expected one")
+}
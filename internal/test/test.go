@@ -118,6 +118,9 @@ func Types(store gokv.Store, t *testing.T) {
 	sliceOfStruct := []Foo{{Bar: "baz"}}
 	sliceOfPrivateStruct := []privateFoo{{Bar: "baz"}}
 
+	mapOfString := map[string]string{"foo": "bar"}
+	mapOfStruct := map[string]Foo{"foo": {Bar: "baz"}}
+
 	testVals := []struct {
 		subTestName string
 		val         interface{}
@@ -268,6 +271,24 @@ func Types(store gokv.Store, t *testing.T) {
 				t.Error(diff)
 			}
 		}},
+		{"map of string", mapOfString, mapOfString, func(t *testing.T, store gokv.Store, key string, expected interface{}) {
+			actualPtr := new(map[string]string)
+			found, err := store.Get(key, actualPtr)
+			handleGetError(t, err, found)
+			actual := *actualPtr
+			if diff := deep.Equal(actual, expected); diff != nil {
+				t.Error(diff)
+			}
+		}},
+		{"map of struct", mapOfStruct, mapOfStruct, func(t *testing.T, store gokv.Store, key string, expected interface{}) {
+			actualPtr := new(map[string]Foo)
+			found, err := store.Get(key, actualPtr)
+			handleGetError(t, err, found)
+			actual := *actualPtr
+			if diff := deep.Equal(actual, expected); diff != nil {
+				t.Error(diff)
+			}
+		}},
 	}
 
 	for _, testVal := range testVals {
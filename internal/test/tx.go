@@ -0,0 +1,116 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/SpeedyCoder/gokv"
+)
+
+// Transactional tests if transactions are committed and rolled back atomically.
+func Transactional(store gokv.Transactional, t *testing.T) {
+	assert := require.New(t)
+	ctx := context.Background()
+	base := strconv.FormatInt(rand.Int63(), 10) + "-"
+	from := base + "from"
+	to := base + "to"
+	index := base + "index"
+
+	err := store.Set(ctx, from, Foo{Bar: "baz"})
+	assert.NoError(err)
+
+	// Move a value and update an index in one transaction
+	err = store.Update(ctx, func(tx gokv.Tx) error {
+		val := new(Foo)
+		found, err := tx.Get(from, val)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return errors.New("no value found")
+		}
+		if err := tx.Delete(from); err != nil {
+			return err
+		}
+		if err := tx.Set(to, *val); err != nil {
+			return err
+		}
+		// Changes must be visible within the transaction
+		found, err = tx.Get(from, new(Foo))
+		if err != nil {
+			return err
+		}
+		if found {
+			return errors.New("deleted value found")
+		}
+		return tx.Set(index, []string{to})
+	})
+	assert.NoError(err)
+
+	found, err := store.Get(ctx, from, new(Foo))
+	assert.NoError(err)
+	assert.False(found, "A value was found, but no value was expected")
+	actual := new(Foo)
+	found, err = store.Get(ctx, to, actual)
+	assert.NoError(err)
+	assert.True(found, "No value was found, but should have been")
+	assert.Equal(Foo{Bar: "baz"}, *actual)
+
+	// An error must roll back all changes
+	expectedErr := errors.New("rollback")
+	err = store.Update(ctx, func(tx gokv.Tx) error {
+		if err := tx.Delete(to); err != nil {
+			return err
+		}
+		if err := tx.Set(from, Foo{Bar: "qux"}); err != nil {
+			return err
+		}
+		return expectedErr
+	})
+	assert.Equal(expectedErr, err)
+
+	found, err = store.Get(ctx, from, new(Foo))
+	assert.NoError(err)
+	assert.False(found, "A value was found, but no value was expected")
+	found, err = store.Get(ctx, to, actual)
+	assert.NoError(err)
+	assert.True(found, "No value was found, but should have been")
+
+	// Concurrent read-modify-write transactions must not lose updates
+	counter := base + "counter"
+	goroutineCount := 50
+	waitGroup := sync.WaitGroup{}
+	waitGroup.Add(goroutineCount)
+	for i := 0; i < goroutineCount; i++ {
+		go func() {
+			defer waitGroup.Done()
+			err := store.Update(ctx, func(tx gokv.Tx) error {
+				count := new(int)
+				if _, err := tx.Get(counter, count); err != nil {
+					return err
+				}
+				return tx.Set(counter, *count+1)
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	waitGroup.Wait()
+	count := new(int)
+	found, err = store.Get(ctx, counter, count)
+	assert.NoError(err)
+	assert.True(found, "No value was found, but should have been")
+	assert.Equal(goroutineCount, *count)
+
+	for _, k := range []string{to, index, counter} {
+		err = store.Delete(ctx, k)
+		assert.NoError(err)
+	}
+}
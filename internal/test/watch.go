@@ -0,0 +1,82 @@
+package test
+
+import (
+	"context"
+	"math/rand"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/SpeedyCoder/gokv"
+)
+
+// Watcher tests if changes of key-value pairs are reported to watchers with a matching prefix.
+func Watcher(store gokv.Watcher, t *testing.T) {
+	assert := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	prefix := strconv.FormatInt(rand.Int63(), 10) + "/"
+	key := prefix + "foo"
+	otherKey := "other" + prefix
+
+	events := store.Watch(ctx, prefix)
+	// Some implementations register the watch asynchronously
+	time.Sleep(100 * time.Millisecond)
+
+	err := store.Set(ctx, otherKey, Foo{Bar: "baz"})
+	assert.NoError(err)
+	err = store.Set(ctx, key, Foo{Bar: "baz"})
+	assert.NoError(err)
+	err = store.Set(ctx, key, Foo{Bar: "qux"})
+	assert.NoError(err)
+	err = store.Delete(ctx, key)
+	assert.NoError(err)
+	err = store.Delete(ctx, otherKey)
+	assert.NoError(err)
+	// Deleting a non-existing key-value pair isn't a change
+	err = store.Delete(ctx, key)
+	assert.NoError(err)
+	err = store.Set(ctx, key, Foo{Bar: "baz"})
+	assert.NoError(err)
+
+	receive := func() gokv.Event {
+		select {
+		case e, ok := <-events:
+			assert.True(ok, "The event channel was closed unexpectedly")
+			return e
+		case <-time.After(5 * time.Second):
+			assert.FailNow("No event was received")
+		}
+		return gokv.Event{}
+	}
+
+	// The changes of otherKey must not be reported
+	first := receive()
+	assert.Equal(gokv.EventPut, first.Type)
+	assert.Equal(key, first.Key)
+	assert.NotEmpty(first.Value)
+	second := receive()
+	assert.Equal(gokv.EventPut, second.Type)
+	assert.Equal(key, second.Key)
+	assert.NotEqual(first.Value, second.Value)
+	assert.True(second.Revision > first.Revision, "Revisions must increase")
+	third := receive()
+	assert.Equal(gokv.EventDelete, third.Type)
+	assert.Equal(key, third.Key)
+	assert.Nil(third.Value)
+	assert.True(third.Revision > second.Revision, "Revisions must increase")
+	fourth := receive()
+	assert.Equal(gokv.EventPut, fourth.Type)
+	assert.Equal(key, fourth.Key)
+
+	// Canceling the context must close the channel
+	cancel()
+	select {
+	case _, ok := <-events:
+		assert.False(ok, "No event was expected")
+	case <-time.After(5 * time.Second):
+		assert.FailNow("The event channel wasn't closed")
+	}
+}
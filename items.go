@@ -0,0 +1,31 @@
+package gokv
+
+import "context"
+
+// ItemsStore is a ContextStore that can iterate over all key-value pairs in a single pass,
+// which saves the round trip per key that iterating over Keys() and calling Get() requires.
+// For stores that can't do this natively the scan package provides a wrapper.
+type ItemsStore interface {
+	ContextStore
+	// Items returns an iterator over all key-value pairs in the store.
+	// For each key-value pair newValue is called to create the pointer
+	// that the value gets unmarshalled into.
+	Items(ctx context.Context, newValue func() interface{}) ItemsIterator
+}
+
+// Item is a key-value pair.
+type Item struct {
+	Key string
+	// Value is the pointer created by newValue, populated with the stored value.
+	Value interface{}
+}
+
+// ItemsIterator is an iterator over all key-value pairs in a store.
+type ItemsIterator interface {
+	// Ch returns a read only channel of items, to which the iterator
+	// writes all the key-value pairs.
+	Ch() <-chan Item
+	// Err returns any error encountered during iteration. It panics if it's
+	// called before the iterator channel is closed.
+	Err() error
+}
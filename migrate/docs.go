@@ -0,0 +1,14 @@
+/*
+Package migrate contains functions for copying all key-value pairs from one `gokv.ContextStore` to another,
+for example when moving from one database to another.
+
+Values are copied as raw bytes, so both stores must implement `gokv.RawStore`
+and use the same codec. Values aren't unmarshalled and marshalled again on the way.
+
+A copy can be resumed after it was interrupted: The progress is saved as checkpoint
+in the destination store and the next copy continues after the checkpoint.
+For that the keys are copied in byte-sorted order.
+
+The `gokv copy` command (in cmd/gokv) provides this functionality on the command line.
+*/
+package migrate
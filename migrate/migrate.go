@@ -0,0 +1,353 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/SpeedyCoder/gokv"
+)
+
+// finalCheckpointTimeout limits how long saving the checkpoint may take after copying failed.
+const finalCheckpointTimeout = 30 * time.Second
+
+// ErrNotRaw is returned if the source or the destination store doesn't implement gokv.RawStore.
+var ErrNotRaw = errors.New("the source and the destination store must implement gokv.RawStore")
+
+// Options are the options for copying.
+type Options struct {
+	// Number of keys that are copied in parallel.
+	// Optional (4 by default).
+	Concurrency int
+	// Key in the destination store where the checkpoint is saved.
+	// If it's set and a checkpoint exists, copying continues after it.
+	// The checkpoint is deleted when copying is done.
+	// A key with this name in the source store isn't copied.
+	// Optional ("" by default, which means there are no checkpoints).
+	CheckpointKey string
+	// Number of processed keys after which the checkpoint is saved and the progress is reported.
+	// Optional (1000 by default).
+	CheckpointInterval int
+	// DryRun only reads the values from the source store, nothing is written to the destination store.
+	// Optional (false by default).
+	DryRun bool
+	// Verify reads each value back from the destination store after it was written
+	// and compares it with the value from the source store.
+	// Optional (false by default).
+	Verify bool
+	// Progress is called with the progress every CheckpointInterval keys and when copying is done.
+	// Optional (nothing is done by default).
+	Progress func(p Progress)
+}
+
+// DefaultOptions is an Options object with default values.
+// Concurrency: 4, CheckpointKey: "", CheckpointInterval: 1000, DryRun: false, Verify: false
+var DefaultOptions = Options{
+	Concurrency:        4,
+	CheckpointInterval: 1000,
+	// No need to set the other fields because their Go zero values are fine for that.
+}
+
+// Progress is the progress of copying.
+type Progress struct {
+	// Number of processed keys.
+	Processed int
+	// Number of copied values. In a dry run the number of values that would have been copied.
+	Copied int
+	// Number of keys that were deleted from the source store after they were listed.
+	Skipped int
+	// Number of copied values that were verified.
+	Verified int
+	// The key up to which all keys were processed.
+	// When copying is resumed it starts with the key after it.
+	Checkpoint string
+}
+
+// Copy copies all key-value pairs from src to dst as raw bytes.
+// Existing values in dst are overwritten.
+// It returns the progress, which is also valid when an error occurred.
+//
+// With a checkpoint key the keys are copied in byte-sorted order.
+// If src implements gokv.RangeStore its keys are expected to be sorted already,
+// otherwise all keys are read and sorted first.
+func Copy(ctx context.Context, src, dst gokv.ContextStore, options *Options) (Progress, error) {
+	opts := DefaultOptions
+	if options != nil {
+		opts = *options
+	}
+
+	// Set default values
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = DefaultOptions.Concurrency
+	}
+	if opts.CheckpointInterval <= 0 {
+		opts.CheckpointInterval = DefaultOptions.CheckpointInterval
+	}
+	if opts.Progress == nil {
+		opts.Progress = func(Progress) {}
+	}
+
+	rawSrc, ok := src.(gokv.RawStore)
+	if !ok {
+		return Progress{}, ErrNotRaw
+	}
+	rawDst, ok := dst.(gokv.RawStore)
+	if !ok {
+		return Progress{}, ErrNotRaw
+	}
+
+	c := copier{
+		src:     rawSrc,
+		dst:     rawDst,
+		options: opts,
+	}
+	return c.run(ctx)
+}
+
+type copier struct {
+	src     gokv.RawStore
+	dst     gokv.RawStore
+	options Options
+}
+
+type job struct {
+	seq int
+	k   string
+}
+
+type result struct {
+	job
+	copied   bool
+	verified bool
+	err      error
+}
+
+func (c copier) run(parent context.Context) (Progress, error) {
+	var progress Progress
+	if c.options.CheckpointKey != "" {
+		if _, err := c.dst.Get(parent, c.options.CheckpointKey, &progress.Checkpoint); err != nil {
+			return progress, fmt.Errorf("reading the checkpoint failed: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	jobs := make(chan job)
+	var listErr error
+	go func() {
+		defer close(jobs)
+		listErr = c.list(ctx, progress.Checkpoint, jobs)
+	}()
+
+	results := make(chan result)
+	var wg sync.WaitGroup
+	for i := 0; i < c.options.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				results <- c.copy(ctx, j)
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// The checkpoint is the key up to which all keys are processed.
+	// Keys that were processed out of order are kept until the keys before them are done.
+	done := make(map[int]string)
+	next := 0
+	saved := progress.Checkpoint
+	var firstErr error
+	for res := range results {
+		if firstErr != nil {
+			continue
+		}
+		if res.err != nil {
+			firstErr = res.err
+			cancel()
+			continue
+		}
+
+		progress.Processed++
+		if res.copied {
+			progress.Copied++
+		} else {
+			progress.Skipped++
+		}
+		if res.verified {
+			progress.Verified++
+		}
+		done[res.seq] = res.k
+		for {
+			k, ok := done[next]
+			if !ok {
+				break
+			}
+			delete(done, next)
+			progress.Checkpoint = k
+			next++
+		}
+
+		if progress.Processed%c.options.CheckpointInterval == 0 {
+			if err := c.saveCheckpoint(ctx, progress.Checkpoint, &saved); err != nil {
+				firstErr = err
+				cancel()
+				continue
+			}
+			c.options.Progress(progress)
+		}
+	}
+	// listErr can be read safely, because jobs is closed before results
+	if firstErr == nil {
+		firstErr = listErr
+	}
+
+	if firstErr != nil {
+		// Save the checkpoint so that copying can be resumed.
+		// The caller's context isn't used, because it's usually canceled already when copying was interrupted.
+		saveCtx, cancelSave := context.WithTimeout(context.Background(), finalCheckpointTimeout)
+		defer cancelSave()
+		if err := c.saveCheckpoint(saveCtx, progress.Checkpoint, &saved); err != nil {
+			return progress, fmt.Errorf("%v (and saving the checkpoint failed: %v)", firstErr, err)
+		}
+		return progress, firstErr
+	}
+
+	if c.options.CheckpointKey != "" && !c.options.DryRun {
+		if err := c.dst.Delete(parent, c.options.CheckpointKey); err != nil {
+			return progress, fmt.Errorf("deleting the checkpoint failed: %v", err)
+		}
+	}
+	c.options.Progress(progress)
+	return progress, nil
+}
+
+// list sends the keys of the source store after the checkpoint to jobs.
+func (c copier) list(ctx context.Context, checkpoint string, jobs chan<- job) error {
+	seq := 0
+	send := func(k string) error {
+		if k == c.options.CheckpointKey {
+			return nil
+		}
+		select {
+		case jobs <- job{seq: seq, k: k}:
+			seq++
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	if c.options.CheckpointKey == "" {
+		return forEach(c.src.Keys(ctx), send)
+	}
+
+	if rangeStore, ok := c.src.(gokv.RangeStore); ok {
+		start := ""
+		if checkpoint != "" {
+			// The smallest key after the checkpoint
+			start = checkpoint + "\x00"
+		}
+		prev := checkpoint
+		return forEach(rangeStore.KeysInRange(ctx, start, ""), func(k string) error {
+			if k < prev {
+				return errors.New("the keys of the source store aren't sorted, which is required for checkpoints")
+			}
+			prev = k
+			return send(k)
+		})
+	}
+
+	var keys []string
+	err := forEach(c.src.Keys(ctx), func(k string) error {
+		if checkpoint == "" || k > checkpoint {
+			keys = append(keys, k)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := send(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// forEach calls f for each key of the iterator until it returns an error.
+// The iterator is drained in any case.
+func forEach(it gokv.KeysIterator, f func(k string) error) error {
+	var err error
+	for k := range it.Ch() {
+		if err == nil {
+			err = f(k)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return it.Err()
+}
+
+// copy copies the value of a single key.
+func (c copier) copy(ctx context.Context, j job) result {
+	res := result{job: j}
+	if res.err = ctx.Err(); res.err != nil {
+		return res
+	}
+
+	data, found, err := c.src.GetBytes(ctx, j.k)
+	if err != nil {
+		res.err = fmt.Errorf("reading %q from the source store failed: %v", j.k, err)
+		return res
+	}
+	if !found {
+		// Deleted after it was listed
+		return res
+	}
+	res.copied = true
+	if c.options.DryRun {
+		return res
+	}
+
+	if err := c.dst.SetBytes(ctx, j.k, data); err != nil {
+		res.err = fmt.Errorf("writing %q to the destination store failed: %v", j.k, err)
+		return res
+	}
+	if c.options.Verify {
+		copied, found, err := c.dst.GetBytes(ctx, j.k)
+		if err != nil {
+			res.err = fmt.Errorf("reading %q from the destination store for verification failed: %v", j.k, err)
+			return res
+		}
+		if !found || !bytes.Equal(data, copied) {
+			res.err = fmt.Errorf("verifying %q failed: the copied value differs from the source value", j.k)
+			return res
+		}
+		res.verified = true
+	}
+	return res
+}
+
+// saveCheckpoint saves the checkpoint if it changed since the last time.
+func (c copier) saveCheckpoint(ctx context.Context, checkpoint string, saved *string) error {
+	if c.options.CheckpointKey == "" || c.options.DryRun || checkpoint == *saved {
+		return nil
+	}
+	if err := c.dst.Set(ctx, c.options.CheckpointKey, checkpoint); err != nil {
+		return fmt.Errorf("saving the checkpoint failed: %v", err)
+	}
+	*saved = checkpoint
+	return nil
+}
@@ -0,0 +1,232 @@
+package migrate_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/SpeedyCoder/gokv"
+	"github.com/SpeedyCoder/gokv/internal/ctxconv"
+	"github.com/SpeedyCoder/gokv/internal/test"
+	"github.com/SpeedyCoder/gokv/migrate"
+)
+
+// TestCopy tests if all values are copied and verified.
+func TestCopy(t *testing.T) {
+	assert := require.New(t)
+	src, srcPath := test.NewBboltStore(t, nil)
+	defer test.CleanUp(src, srcPath)
+	dst, dstPath := test.NewBboltStore(t, nil)
+	defer test.CleanUp(dst, dstPath)
+	fill(t, src, 50)
+
+	var reports []migrate.Progress
+	progress, err := migrate.Copy(context.Background(), src, dst, &migrate.Options{
+		CheckpointInterval: 20,
+		Verify:             true,
+		Progress: func(p migrate.Progress) {
+			reports = append(reports, p)
+		},
+	})
+	assert.NoError(err)
+	assert.Equal(50, progress.Processed)
+	assert.Equal(50, progress.Copied)
+	assert.Equal(50, progress.Verified)
+	assert.Len(reports, 3)
+	assert.Equal(20, reports[0].Processed)
+	assert.Equal(progress, reports[2])
+	assertCopied(t, src, dst, 50)
+}
+
+// TestDryRun tests if nothing is written in a dry run.
+func TestDryRun(t *testing.T) {
+	assert := require.New(t)
+	src, srcPath := test.NewBboltStore(t, nil)
+	defer test.CleanUp(src, srcPath)
+	dst, dstPath := test.NewBboltStore(t, nil)
+	defer test.CleanUp(dst, dstPath)
+	fill(t, src, 10)
+
+	progress, err := migrate.Copy(context.Background(), src, dst, &migrate.Options{
+		CheckpointKey: "checkpoint",
+		DryRun:        true,
+	})
+	assert.NoError(err)
+	assert.Equal(10, progress.Copied)
+	assert.Empty(keys(t, dst))
+}
+
+// TestResume tests if an interrupted copy continues after the checkpoint.
+func TestResume(t *testing.T) {
+	assert := require.New(t)
+	src, srcPath := test.NewBboltStore(t, nil)
+	defer test.CleanUp(src, srcPath)
+	inner, dstPath := test.NewBboltStore(t, nil)
+	defer test.CleanUp(inner, dstPath)
+	fill(t, src, 100)
+	ctx := context.Background()
+
+	dst := &failingStore{RawStore: inner.(gokv.RawStore), failAfter: 60}
+	options := &migrate.Options{
+		Concurrency:        8,
+		CheckpointKey:      "checkpoint",
+		CheckpointInterval: 10,
+	}
+	progress, err := migrate.Copy(ctx, src, dst, options)
+	assert.Error(err)
+	assert.True(progress.Processed >= 50 && progress.Processed <= 60, "processed: %d", progress.Processed)
+	var checkpoint string
+	found, err := inner.Get(ctx, "checkpoint", &checkpoint)
+	assert.NoError(err)
+	assert.True(found)
+	assert.Equal(progress.Checkpoint, checkpoint)
+	// All keys up to the checkpoint were copied
+	for i := 0; i < 100; i++ {
+		k := key(i)
+		if k > checkpoint {
+			break
+		}
+		_, found, err := inner.(gokv.RawStore).GetBytes(ctx, k)
+		assert.NoError(err)
+		assert.True(found, k)
+	}
+
+	dst.failAfter = -1
+	progress, err = migrate.Copy(ctx, src, dst, options)
+	assert.NoError(err)
+	assert.True(progress.Processed < 100)
+	found, err = inner.Get(ctx, "checkpoint", &checkpoint)
+	assert.NoError(err)
+	assert.False(found)
+	assertCopied(t, src, inner, 100)
+}
+
+// TestCancel tests if the checkpoint is saved when copying is canceled,
+// even if the destination store respects the canceled context.
+func TestCancel(t *testing.T) {
+	assert := require.New(t)
+	src, srcPath := test.NewBboltStore(t, nil)
+	defer test.CleanUp(src, srcPath)
+	inner, dstPath := test.NewBboltStore(t, nil)
+	defer test.CleanUp(inner, dstPath)
+	fill(t, src, 100)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	dst := &cancelingStore{RawStore: inner.(gokv.RawStore), cancelAfter: 30, cancel: cancel}
+	progress, err := migrate.Copy(ctx, src, dst, &migrate.Options{
+		CheckpointKey:      "checkpoint",
+		CheckpointInterval: 1000,
+	})
+	assert.Error(err)
+	assert.Contains(err.Error(), context.Canceled.Error())
+	assert.NotEmpty(progress.Checkpoint)
+	var checkpoint string
+	found, err := inner.Get(context.Background(), "checkpoint", &checkpoint)
+	assert.NoError(err)
+	assert.True(found, "The checkpoint wasn't saved")
+	assert.Equal(progress.Checkpoint, checkpoint)
+}
+
+// TestNotRaw tests if stores that don't implement gokv.RawStore are rejected.
+func TestNotRaw(t *testing.T) {
+	src, srcPath := test.NewBboltStore(t, nil)
+	defer test.CleanUp(src, srcPath)
+	dst, dstPath := test.NewBboltStore(t, nil)
+	defer test.CleanUp(dst, dstPath)
+
+	_, err := migrate.Copy(context.Background(), src, ctxconv.ToContextStore(ctxconv.ToStore(dst)), nil)
+	if err != migrate.ErrNotRaw {
+		t.Errorf("Expected migrate.ErrNotRaw, but was: %v", err)
+	}
+}
+
+// failingStore fails SetBytes after the given number of calls. A negative number disables that.
+type failingStore struct {
+	gokv.RawStore
+	lock      sync.Mutex
+	calls     int
+	failAfter int
+}
+
+func (s *failingStore) SetBytes(ctx context.Context, k string, data []byte) error {
+	s.lock.Lock()
+	s.calls++
+	fail := s.failAfter >= 0 && s.calls > s.failAfter
+	s.lock.Unlock()
+	if fail {
+		return errors.New("unavailable")
+	}
+	return s.RawStore.SetBytes(ctx, k, data)
+}
+
+// cancelingStore cancels the context after the given number of SetBytes calls
+// and fails all writes with the error of the context, like a store that sends requests over the network.
+type cancelingStore struct {
+	gokv.RawStore
+	lock        sync.Mutex
+	calls       int
+	cancelAfter int
+	cancel      context.CancelFunc
+}
+
+func (s *cancelingStore) Set(ctx context.Context, k string, v interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.RawStore.Set(ctx, k, v)
+}
+
+func (s *cancelingStore) SetBytes(ctx context.Context, k string, data []byte) error {
+	s.lock.Lock()
+	s.calls++
+	if s.calls > s.cancelAfter {
+		s.cancel()
+	}
+	s.lock.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.RawStore.SetBytes(ctx, k, data)
+}
+
+func key(i int) string {
+	return fmt.Sprintf("key%03d", i)
+}
+
+func fill(t *testing.T, store gokv.ContextStore, n int) {
+	for i := 0; i < n; i++ {
+		if err := store.Set(context.Background(), key(i), i); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func assertCopied(t *testing.T, src, dst gokv.ContextStore, n int) {
+	assert := require.New(t)
+	assert.Len(keys(t, dst), n)
+	for i := 0; i < n; i++ {
+		expected, _, err := src.(gokv.RawStore).GetBytes(context.Background(), key(i))
+		assert.NoError(err)
+		actual, found, err := dst.(gokv.RawStore).GetBytes(context.Background(), key(i))
+		assert.NoError(err)
+		assert.True(found)
+		assert.Equal(expected, actual)
+	}
+}
+
+func keys(t *testing.T, store gokv.ContextStore) []string {
+	var result []string
+	it := store.Keys(context.Background())
+	for k := range it.Ch() {
+		result = append(result, k)
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
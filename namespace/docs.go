@@ -0,0 +1,41 @@
+/*
+Package namespace contains a wrapper that confines a `gokv.ContextStore` to the keys with a given prefix.
+
+This allows multiple tenants (e.g. microservices) to safely share one store,
+for example one Redis connection, without manually prefixing all keys.
+The prefix is stored with its length, so keys can contain any character,
+including the "/" that separates the prefix from the key.
+
+Keys are stored in the wrapped store in the format "<length of the prefix>:<prefix>/<key>",
+e.g. the key "users/42" in the namespace "tenant" is stored as "6:tenant/users/42".
+Each level of nested namespaces adds its length-prefixed prefix before the "/",
+e.g. "6:tenant3:app/users/42" for the namespace "app" within "tenant".
+
+This means that keys that were prefixed manually, e.g. "tenant/users/42",
+can't be read via a namespace with the same prefix. To migrate them,
+iterate over the old keys with `KeysWithPrefix()` of the `scan` package,
+read each value and write it via the namespace store with the old prefix stripped,
+and delete the old key afterwards:
+
+	ns := namespace.NewStore(store, "tenant")
+	keys := scan.NewStore(store).KeysWithPrefix(ctx, "tenant/")
+	for k := range keys.Ch() {
+		v := new(User)
+		if _, err := store.Get(ctx, k, v); err != nil {
+			return err
+		}
+		if err := ns.Set(ctx, strings.TrimPrefix(k, "tenant/"), v); err != nil {
+			return err
+		}
+		if err := store.Delete(ctx, k); err != nil {
+			return err
+		}
+	}
+	if err := keys.Err(); err != nil {
+		return err
+	}
+
+Keys that were already migrated don't start with "tenant/" anymore, so the migration can be repeated
+when it was interrupted.
+*/
+package namespace
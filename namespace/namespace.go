@@ -0,0 +1,126 @@
+package namespace
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/SpeedyCoder/gokv"
+	"github.com/SpeedyCoder/gokv/internal/check"
+	"github.com/SpeedyCoder/gokv/internal/iterator"
+	"github.com/SpeedyCoder/gokv/scan"
+)
+
+// NewStore creates a new gokv.ContextStore that transparently prefixes all keys with the given prefix.
+// Keys() only returns the keys with the prefix, with the prefix stripped.
+// Namespaces can be nested, so a namespace "b" in the namespace "a" only sees its own keys,
+// not the ones of "a", and vice versa.
+// The returned store also implements gokv.RangeStore, making use of the
+// native implementation of the wrapped store if there's one.
+//
+// The prefix is length-prefixed and followed by "/", e.g. the key "users/42" in the namespace "tenant"
+// is stored as "6:tenant/users/42", and in the namespace "app" within "tenant" as "6:tenant3:app/users/42".
+// That way keys can contain any character, without a namespace being able to access the keys of another one.
+//
+// Close() doesn't close the wrapped store, because it's usually shared.
+// The wrapped store must be closed separately.
+func NewStore(store gokv.ContextStore, prefix string) gokv.ContextStore {
+	if ns, ok := store.(namespaceStore); ok {
+		return namespaceStore{
+			store: ns.store,
+			path:  ns.path + encode(prefix),
+		}
+	}
+
+	return namespaceStore{
+		store: scan.NewStore(store),
+		path:  encode(prefix),
+	}
+}
+
+// encode returns the length-prefixed form of a namespace prefix.
+// A sequence of encoded prefixes can't contain the encoded prefixes of another sequence followed by "/",
+// because "/" is never at the start of an encoded prefix, where its length is expected.
+func encode(prefix string) string {
+	return strconv.Itoa(len(prefix)) + ":" + prefix
+}
+
+type namespaceStore struct {
+	store gokv.RangeStore
+	// path contains the encoded prefixes of the namespace and the ones it's nested in.
+	path string
+}
+
+// key returns the key in the wrapped store for the given key of the namespace.
+func (s namespaceStore) key(k string) string {
+	return s.path + "/" + k
+}
+
+// Set stores the given value for the prefixed key.
+// The key must not be "" and the value must not be nil.
+func (s namespaceStore) Set(ctx context.Context, k string, v interface{}) error {
+	if err := check.KeyAndValue(k, v); err != nil {
+		return err
+	}
+
+	return s.store.Set(ctx, s.key(k), v)
+}
+
+// Get retrieves the stored value for the prefixed key.
+// The key must not be "" and the pointer must not be nil.
+func (s namespaceStore) Get(ctx context.Context, k string, v interface{}) (found bool, err error) {
+	if err := check.KeyAndValue(k, v); err != nil {
+		return false, err
+	}
+
+	return s.store.Get(ctx, s.key(k), v)
+}
+
+// Delete deletes the stored value for the prefixed key.
+// Deleting a non-existing key-value pair does NOT lead to an error.
+// The key must not be "".
+func (s namespaceStore) Delete(ctx context.Context, k string) error {
+	if err := check.Key(k); err != nil {
+		return err
+	}
+
+	return s.store.Delete(ctx, s.key(k))
+}
+
+// Keys returns an iterator over all keys in the namespace, with the prefix stripped.
+// The keys of nested namespaces aren't included.
+func (s namespaceStore) Keys(ctx context.Context) gokv.KeysIterator {
+	return s.KeysWithPrefix(ctx, "")
+}
+
+// KeysWithPrefix returns an iterator over all keys in the namespace
+// that start with the given prefix, with the namespace prefix stripped.
+func (s namespaceStore) KeysWithPrefix(ctx context.Context, prefix string) gokv.KeysIterator {
+	return s.strip(ctx, s.store.KeysWithPrefix(ctx, s.key(prefix)))
+}
+
+// KeysInRange returns an iterator over all keys k in the namespace with start <= k < end,
+// with the namespace prefix stripped.
+// An empty end means there's no upper bound.
+func (s namespaceStore) KeysInRange(ctx context.Context, start, end string) gokv.KeysIterator {
+	// Without an upper bound, the range must still end with the namespace.
+	if end == "" {
+		return iterator.Filter(ctx, s.KeysWithPrefix(ctx, ""), func(k string) bool {
+			return k >= start
+		})
+	}
+	return s.strip(ctx, s.store.KeysInRange(ctx, s.key(start), s.key(end)))
+}
+
+// strip removes the namespace prefix from all keys of the given iterator.
+func (s namespaceStore) strip(ctx context.Context, src gokv.KeysIterator) gokv.KeysIterator {
+	prefix := s.key("")
+	return iterator.Map(ctx, src, func(k string) string {
+		return strings.TrimPrefix(k, prefix)
+	})
+}
+
+// Close does nothing. The wrapped store must be closed separately.
+func (s namespaceStore) Close() error {
+	return nil
+}
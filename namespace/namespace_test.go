@@ -0,0 +1,134 @@
+package namespace_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/SpeedyCoder/gokv"
+	"github.com/SpeedyCoder/gokv/internal/ctxconv"
+	"github.com/SpeedyCoder/gokv/internal/test"
+	"github.com/SpeedyCoder/gokv/namespace"
+)
+
+// TestStore tests if reading from, writing to and deleting from the store works properly.
+// The shared store contains keys of other namespaces, which must not show up.
+func TestStore(t *testing.T) {
+	store, path := test.NewBboltStore(t, nil)
+	defer test.CleanUp(store, path)
+	other := namespace.NewStore(store, "other")
+	err := other.Set(context.Background(), "foo", test.Foo{Bar: "baz"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	test.Store(ctxconv.ToStore(namespace.NewStore(store, "tenant")), t)
+}
+
+// TestRange tests if the range methods are confined to the namespace.
+func TestRange(t *testing.T) {
+	store, path := test.NewBboltStore(t, nil)
+	defer test.CleanUp(store, path)
+	t.Run("native", func(t *testing.T) {
+		test.RangeStore(namespace.NewStore(store, "tenant").(gokv.RangeStore), t)
+	})
+	t.Run("fallback", func(t *testing.T) {
+		fallback := ctxconv.ToContextStore(ctxconv.ToStore(store))
+		test.RangeStore(namespace.NewStore(fallback, "tenant").(gokv.RangeStore), t)
+	})
+}
+
+// TestIsolation tests if namespaces are isolated from each other and can be nested.
+func TestIsolation(t *testing.T) {
+	assert := require.New(t)
+	ctx := context.Background()
+	store, path := test.NewBboltStore(t, nil)
+	defer test.CleanUp(store, path)
+
+	a := namespace.NewStore(store, "a")
+	b := namespace.NewStore(store, "b")
+	ab := namespace.NewStore(a, "b")
+
+	err := a.Set(ctx, "foo", test.Foo{Bar: "a"})
+	assert.NoError(err)
+	err = b.Set(ctx, "foo", test.Foo{Bar: "b"})
+	assert.NoError(err)
+	err = ab.Set(ctx, "foo", test.Foo{Bar: "ab"})
+	assert.NoError(err)
+
+	for expected, s := range map[string]gokv.ContextStore{"a": a, "b": b, "ab": ab} {
+		actual := new(test.Foo)
+		found, err := s.Get(ctx, "foo", actual)
+		assert.NoError(err)
+		assert.True(found, "No value was found, but should have been")
+		assert.Equal(expected, actual.Bar)
+	}
+
+	// The keys of the nested namespace aren't part of the outer one
+	assert.ElementsMatch([]string{"foo"}, collect(t, a.Keys(ctx)))
+	assert.ElementsMatch([]string{"foo"}, collect(t, a.(gokv.RangeStore).KeysWithPrefix(ctx, "")))
+	assert.ElementsMatch([]string{"foo"}, collect(t, a.(gokv.RangeStore).KeysInRange(ctx, "a", "z")))
+	assert.ElementsMatch([]string{"foo"}, collect(t, b.Keys(ctx)))
+	assert.ElementsMatch([]string{"foo"}, collect(t, ab.Keys(ctx)))
+	assert.ElementsMatch([]string{"1:a/foo", "1:a1:b/foo", "1:b/foo"}, collect(t, store.Keys(ctx)))
+
+	// Keys can contain "/" without reaching into the nested namespace
+	found, err := a.Get(ctx, "b/foo", new(test.Foo))
+	assert.NoError(err)
+	assert.False(found, "A value of the nested namespace was found")
+	err = a.Set(ctx, "b/foo", test.Foo{Bar: "a"})
+	assert.NoError(err)
+	actual := new(test.Foo)
+	_, err = ab.Get(ctx, "foo", actual)
+	assert.NoError(err)
+	assert.Equal("ab", actual.Bar)
+	assert.ElementsMatch([]string{"foo", "b/foo"}, collect(t, a.Keys(ctx)))
+	assert.ElementsMatch([]string{"b/foo"}, collect(t, a.(gokv.RangeStore).KeysWithPrefix(ctx, "b/")))
+	assert.ElementsMatch([]string{"foo"}, collect(t, ab.Keys(ctx)))
+	err = a.Delete(ctx, "b/foo")
+	assert.NoError(err)
+
+	// Neither can the prefixes
+	slash := namespace.NewStore(store, "a/b")
+	err = slash.Set(ctx, "foo", test.Foo{Bar: "slash"})
+	assert.NoError(err)
+	assert.ElementsMatch([]string{"foo"}, collect(t, slash.Keys(ctx)))
+	assert.ElementsMatch([]string{"foo"}, collect(t, ab.Keys(ctx)))
+	assert.ElementsMatch([]string{"foo"}, collect(t, a.Keys(ctx)))
+	_, err = ab.Get(ctx, "foo", actual)
+	assert.NoError(err)
+	assert.Equal("ab", actual.Bar)
+	err = slash.Delete(ctx, "foo")
+	assert.NoError(err)
+
+	// A namespace whose prefix starts with the one of another namespace is separate
+	aa := namespace.NewStore(store, "aa")
+	err = aa.Set(ctx, "foo", test.Foo{Bar: "aa"})
+	assert.NoError(err)
+	err = a.Set(ctx, "afoo", test.Foo{Bar: "a"})
+	assert.NoError(err)
+	assert.ElementsMatch([]string{"foo"}, collect(t, aa.Keys(ctx)))
+	assert.ElementsMatch([]string{"foo", "afoo"}, collect(t, a.Keys(ctx)))
+	found, err = aa.Get(ctx, "foo", actual)
+	assert.NoError(err)
+	assert.True(found, "No value was found, but should have been")
+	assert.Equal("aa", actual.Bar)
+
+	// Closing a namespace must not close the shared store
+	err = a.Close()
+	assert.NoError(err)
+	err = b.Set(ctx, "bar", test.Foo{Bar: "b"})
+	assert.NoError(err)
+}
+
+func collect(t *testing.T, it gokv.KeysIterator) []string {
+	keys := make([]string, 0)
+	for k := range it.Ch() {
+		keys = append(keys, k)
+	}
+	if err := it.Err(); err != nil {
+		t.Error(err)
+	}
+	return keys
+}
@@ -0,0 +1,18 @@
+package gokv
+
+import "context"
+
+// RangeStore is a ContextStore that can iterate over a subset of its keys.
+// Implementations that can filter keys natively (e.g. with a cursor or a prefix query)
+// implement it directly, for all other stores the scan package provides a wrapper
+// that filters the keys on the client side.
+type RangeStore interface {
+	ContextStore
+	// KeysWithPrefix returns an iterator over all keys that start with the given prefix.
+	// An empty prefix matches all keys.
+	KeysWithPrefix(ctx context.Context, prefix string) KeysIterator
+	// KeysInRange returns an iterator over all keys k with start <= k < end,
+	// compared byte-wise like strings in Go.
+	// An empty end means there's no upper bound.
+	KeysInRange(ctx context.Context, start, end string) KeysIterator
+}
@@ -0,0 +1,15 @@
+/*
+Package ratelimit contains a wrapper that limits the rate and concurrency of the operations on a `gokv.ContextStore`.
+
+Reads and writes have separate token buckets, which matches backends with provisioned capacity
+like DynamoDB (read and write capacity units) or Alibaba Cloud Table Store (reserved capacity).
+The number of operations in flight can be limited as well.
+
+When a limit is reached, operations either block until they're allowed or the context is done,
+or fail right away with `ratelimit.ErrLimited`.
+Blocked operations fail right away as well if they can't be allowed before the deadline of the context,
+and with `ratelimit.ErrLimited` when the deadline is exceeded while they wait for a free slot.
+The behaviour can be chosen per context with `ratelimit.WithFailFast()`,
+so that for example a batch job waits for capacity while online traffic fails fast.
+*/
+package ratelimit
@@ -0,0 +1,190 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"math"
+
+	"golang.org/x/time/rate"
+
+	"github.com/SpeedyCoder/gokv"
+	"github.com/SpeedyCoder/gokv/internal/iterator"
+)
+
+// ErrLimited is returned when an operation isn't allowed by the limits,
+// either right away in fail-fast mode or before the deadline of the context.
+var ErrLimited = errors.New("the rate limit of the store is exceeded")
+
+// Options are the options for the rate limited store.
+type Options struct {
+	// Maximum average number of reads (Get and Keys) per second. 0 means unlimited.
+	// Optional (0 by default).
+	ReadsPerSecond float64
+	// Maximum number of reads in a burst.
+	// Optional (ReadsPerSecond, but at least 1 by default).
+	ReadBurst int
+	// Maximum average number of writes (Set and Delete) per second. 0 means unlimited.
+	// Optional (0 by default).
+	WritesPerSecond float64
+	// Maximum number of writes in a burst.
+	// Optional (WritesPerSecond, but at least 1 by default).
+	WriteBurst int
+	// Maximum number of operations in flight. 0 means unlimited.
+	// Iterating over the keys isn't counted, only the call of Keys() itself.
+	// Optional (0 by default).
+	MaxInFlight int
+	// FailFast makes operations fail right away with ErrLimited when a limit is reached,
+	// instead of blocking. It can be overridden per context with WithFailFast.
+	// Optional (false by default).
+	FailFast bool
+}
+
+type failFastKey struct{}
+
+// WithFailFast returns a context that overrides Options.FailFast for operations with this context.
+func WithFailFast(ctx context.Context, failFast bool) context.Context {
+	return context.WithValue(ctx, failFastKey{}, failFast)
+}
+
+// NewStore creates a new gokv.ContextStore that limits the rate of reads and writes
+// and the number of operations in flight of the given store.
+func NewStore(store gokv.ContextStore, options *Options) gokv.ContextStore {
+	if options == nil {
+		options = &Options{}
+	}
+
+	result := limitedStore{
+		store:    store,
+		reads:    newLimiter(options.ReadsPerSecond, options.ReadBurst),
+		writes:   newLimiter(options.WritesPerSecond, options.WriteBurst),
+		failFast: options.FailFast,
+	}
+	if options.MaxInFlight > 0 {
+		result.inFlight = make(chan struct{}, options.MaxInFlight)
+	}
+	return result
+}
+
+// newLimiter returns a token bucket for the given rate, or nil if it's unlimited.
+func newLimiter(perSecond float64, burst int) *rate.Limiter {
+	if perSecond <= 0 {
+		return nil
+	}
+	// Set default values
+	if burst <= 0 {
+		burst = int(math.Max(1, perSecond))
+	}
+	return rate.NewLimiter(rate.Limit(perSecond), burst)
+}
+
+type limitedStore struct {
+	store    gokv.ContextStore
+	reads    *rate.Limiter
+	writes   *rate.Limiter
+	inFlight chan struct{}
+	failFast bool
+}
+
+// acquire waits until a slot for the operation is free and the operation is allowed by the token bucket.
+// The slot is acquired first, so that operations that don't get a slot don't use up tokens.
+// If it returns nil, release must be called after the operation.
+func (s limitedStore) acquire(ctx context.Context, limiter *rate.Limiter) error {
+	failFast := s.failFast
+	if v, ok := ctx.Value(failFastKey{}).(bool); ok {
+		failFast = v
+	}
+
+	if s.inFlight != nil {
+		if failFast {
+			select {
+			case s.inFlight <- struct{}{}:
+			default:
+				return ErrLimited
+			}
+		} else {
+			select {
+			case s.inFlight <- struct{}{}:
+			case <-ctx.Done():
+				return limitErr(ctx)
+			}
+		}
+	}
+
+	if limiter != nil {
+		if failFast {
+			if !limiter.Allow() {
+				s.release()
+				return ErrLimited
+			}
+		} else if err := limiter.Wait(ctx); err != nil {
+			s.release()
+			return limitErr(ctx)
+		}
+	}
+	return nil
+}
+
+// limitErr returns the error for an operation that wasn't allowed before the context was done
+// or before its deadline would be exceeded.
+// Only cancelations are reported as such, an exceeded deadline means the limit was hit.
+func limitErr(ctx context.Context) error {
+	if ctx.Err() == context.Canceled {
+		return context.Canceled
+	}
+	return ErrLimited
+}
+
+func (s limitedStore) release() {
+	if s.inFlight != nil {
+		<-s.inFlight
+	}
+}
+
+// Set stores the given value for the given key, counted as write.
+// The key must not be "" and the value must not be nil.
+func (s limitedStore) Set(ctx context.Context, k string, v interface{}) error {
+	if err := s.acquire(ctx, s.writes); err != nil {
+		return err
+	}
+	defer s.release()
+	return s.store.Set(ctx, k, v)
+}
+
+// Get retrieves the stored value for the given key, counted as read.
+// If no value is found it returns (false, nil).
+// The key must not be "" and the pointer must not be nil.
+func (s limitedStore) Get(ctx context.Context, k string, v interface{}) (found bool, err error) {
+	if err := s.acquire(ctx, s.reads); err != nil {
+		return false, err
+	}
+	defer s.release()
+	return s.store.Get(ctx, k, v)
+}
+
+// Delete deletes the stored value for the given key, counted as write.
+// Deleting a non-existing key-value pair does NOT lead to an error.
+// The key must not be "".
+func (s limitedStore) Delete(ctx context.Context, k string) error {
+	if err := s.acquire(ctx, s.writes); err != nil {
+		return err
+	}
+	defer s.release()
+	return s.store.Delete(ctx, k)
+}
+
+// Keys returns an iterator over all keys in the store, counted as a single read.
+// If the operation isn't allowed the iterator fails with the error.
+func (s limitedStore) Keys(ctx context.Context) gokv.KeysIterator {
+	if err := s.acquire(ctx, s.reads); err != nil {
+		it := iterator.New(ctx)
+		it.Close(err)
+		return it
+	}
+	defer s.release()
+	return s.store.Keys(ctx)
+}
+
+// Close closes the wrapped store.
+func (s limitedStore) Close() error {
+	return s.store.Close()
+}
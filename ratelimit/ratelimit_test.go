@@ -0,0 +1,133 @@
+package ratelimit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/SpeedyCoder/gokv"
+	"github.com/SpeedyCoder/gokv/internal/ctxconv"
+	"github.com/SpeedyCoder/gokv/internal/test"
+	"github.com/SpeedyCoder/gokv/ratelimit"
+)
+
+// TestStore tests if reading from, writing to and deleting from the store works properly.
+func TestStore(t *testing.T) {
+	store, path := test.NewBboltStore(t, nil)
+	defer test.CleanUp(store, path)
+
+	test.Store(ctxconv.ToStore(ratelimit.NewStore(store, &ratelimit.Options{
+		ReadsPerSecond:  1000,
+		WritesPerSecond: 1000,
+		MaxInFlight:     10,
+	})), t)
+}
+
+// TestRates tests if reads and writes are limited separately,
+// blocking or failing fast depending on the options and the context.
+func TestRates(t *testing.T) {
+	assert := require.New(t)
+	inner, path := test.NewBboltStore(t, nil)
+	defer test.CleanUp(inner, path)
+	store := ratelimit.NewStore(inner, &ratelimit.Options{
+		ReadsPerSecond:  20,
+		ReadBurst:       2,
+		WritesPerSecond: 1,
+		FailFast:        true,
+	})
+	ctx := context.Background()
+
+	// Writes fail fast after the burst of 1
+	assert.NoError(store.Set(ctx, "foo", "bar"))
+	assert.Equal(ratelimit.ErrLimited, store.Set(ctx, "foo", "bar"))
+	assert.Equal(ratelimit.ErrLimited, store.Delete(ctx, "foo"))
+
+	// Reads have their own bucket
+	for i := 0; i < 2; i++ {
+		_, err := store.Get(ctx, "foo", new(string))
+		assert.NoError(err)
+	}
+	_, err := store.Get(ctx, "foo", new(string))
+	assert.Equal(ratelimit.ErrLimited, err)
+	it := store.Keys(ctx)
+	for range it.Ch() {
+	}
+	assert.Equal(ratelimit.ErrLimited, it.Err())
+
+	// Blocking reads wait for the next token
+	blocking := ratelimit.WithFailFast(ctx, false)
+	start := time.Now()
+	_, err = store.Get(blocking, "foo", new(string))
+	assert.NoError(err)
+	assert.True(time.Since(start) >= 40*time.Millisecond, "took %v", time.Since(start))
+
+	// Unless the deadline would be exceeded
+	deadlineCtx, cancel := context.WithTimeout(blocking, 100*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	assert.Equal(ratelimit.ErrLimited, store.Set(deadlineCtx, "foo", "bar"))
+	assert.True(time.Since(start) < 100*time.Millisecond, "took %v", time.Since(start))
+}
+
+// TestMaxInFlight tests if the number of operations in flight is limited.
+func TestMaxInFlight(t *testing.T) {
+	assert := require.New(t)
+	inner, path := test.NewBboltStore(t, nil)
+	defer test.CleanUp(inner, path)
+	slow := slowStore{ContextStore: inner, started: make(chan struct{}), proceed: make(chan struct{})}
+	store := ratelimit.NewStore(slow, &ratelimit.Options{
+		ReadsPerSecond: 0.1,
+		MaxInFlight:    1,
+	})
+	ctx := context.Background()
+
+	errs := make(chan error)
+	go func() {
+		errs <- store.Set(ctx, "foo", "bar")
+	}()
+	<-slow.started
+
+	// Fail fast
+	_, err := store.Get(ratelimit.WithFailFast(ctx, true), "foo", new(string))
+	assert.Equal(ratelimit.ErrLimited, err)
+
+	// Block until the deadline is exceeded
+	timeoutCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	_, err = store.Get(timeoutCtx, "foo", new(string))
+	assert.Equal(ratelimit.ErrLimited, err)
+
+	// Or the context is canceled
+	canceledCtx, cancelGet := context.WithCancel(ctx)
+	cancelGet()
+	_, err = store.Get(canceledCtx, "foo", new(string))
+	assert.Equal(context.Canceled, err)
+
+	// Block until the slot is free.
+	// The operations that didn't get a slot must not have used up the only read token,
+	// otherwise the next one would only be available after the deadline.
+	go func() {
+		deadlineCtx, cancel := context.WithTimeout(ctx, time.Second)
+		defer cancel()
+		_, err := store.Get(deadlineCtx, "foo", new(string))
+		errs <- err
+	}()
+	close(slow.proceed)
+	assert.NoError(<-errs)
+	assert.NoError(<-errs)
+}
+
+// slowStore signals when Set is called and blocks it until proceed is closed.
+type slowStore struct {
+	gokv.ContextStore
+	started chan struct{}
+	proceed chan struct{}
+}
+
+func (s slowStore) Set(ctx context.Context, k string, v interface{}) error {
+	close(s.started)
+	<-s.proceed
+	return s.ContextStore.Set(ctx, k, v)
+}
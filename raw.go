@@ -0,0 +1,18 @@
+package gokv
+
+import "context"
+
+// RawStore is a ContextStore that can store and retrieve values as they are,
+// bypassing the codec of the store.
+// This avoids marshalling already serialized payloads (e.g. protobuf messages or images) again.
+// For stores that don't implement it, encoding.Raw can be used as codec instead.
+type RawStore interface {
+	ContextStore
+	// SetBytes stores the given data for the given key without marshalling it.
+	// The key must not be "" and the data must not be nil.
+	SetBytes(ctx context.Context, k string, data []byte) error
+	// GetBytes retrieves the data for the given key without unmarshalling it.
+	// If no value is found it returns (nil, false, nil).
+	// The key must not be "".
+	GetBytes(ctx context.Context, k string) (data []byte, found bool, err error)
+}
@@ -0,0 +1,31 @@
+/*
+Package replica contains a store that replicates all values to multiple `gokv.ContextStore`s,
+for example a local `bbolt` store and two remote stores, and tolerates some of them being unavailable.
+
+Operations are sent to all replicas concurrently.
+Writes succeed when the write quorum W of replicas acknowledged them,
+reads when the read quorum R of replicas responded.
+Operations return as soon as the quorum is reached, so a replica that is unavailable
+or slow doesn't delay them. The remaining operations finish in the background.
+With W + R > N (the number of replicas) every read sees the latest successful write.
+
+Values are stored on the replicas in a `replica.Record` together with a version, the time of the write.
+The versions of a store are strictly increasing, even if the clock doesn't advance between two writes.
+Replicas never overwrite a record with an older one. If all replicas that acknowledged a write
+already had a newer record, e.g. one written by another process whose clock is ahead, the write fails with `replica.ErrStale`.
+When the replicas that responded to a read disagree, the ones with an older version
+or without the value are repaired with the newest one (read repair).
+Writes that failed on a replica are kept as hints in memory and delivered
+when the replica is available again (hinted handoff).
+An anti-entropy pass compares the keys of all replicas and repairs the ones that are missing or outdated,
+which also covers hints that got lost, e.g. because the process was restarted.
+
+Deletions write a tombstone, a record without value that's marked as deleted,
+so that a replica that missed the deletion can't bring the value back:
+The tombstone is newer than the value and replaces it during read repair, handoff and anti-entropy.
+Tombstones are removed by the anti-entropy pass once all replicas have them and they're older than
+`Options.TombstoneTTL`, so a replica that is unavailable for longer than that can still bring a value back.
+They're only removed if all replicas implement `gokv.ConditionalStore`,
+so that a value that's written while the tombstone is removed isn't deleted as well.
+*/
+package replica
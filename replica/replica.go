@@ -0,0 +1,712 @@
+package replica
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/SpeedyCoder/gokv"
+	"github.com/SpeedyCoder/gokv/encoding"
+	"github.com/SpeedyCoder/gokv/internal/check"
+	"github.com/SpeedyCoder/gokv/internal/iterator"
+)
+
+// ErrStale is returned by writes when all replicas that acknowledged the write already had a record
+// with the same or a higher version, so the write had no effect.
+// This can happen when a store in another process with a clock that's ahead wrote the key.
+var ErrStale = errors.New("the replicas already have a newer record for the key")
+
+// Record is what's stored on the replicas for each value.
+type Record struct {
+	// Version of the value, the time of the write in nanoseconds since the Unix epoch.
+	// If the clock didn't advance since the previous write of the store, or was set back,
+	// it's the version of the previous write + 1 instead.
+	// The record with the highest version is the newest one.
+	Version int64
+	// Data is the value, marshalled with Options.Encoding.
+	Data []byte
+	// Deleted marks a tombstone, which is written for deletions
+	// so that replicas that missed the deletion don't bring the value back.
+	Deleted bool
+}
+
+// Options are the options for the replicated store.
+type Options struct {
+	// Number of replicas that must acknowledge a write (W).
+	// Optional (the majority of replicas by default).
+	WriteQuorum int
+	// Number of replicas that must respond to a read (R).
+	// Optional (the majority of replicas by default).
+	ReadQuorum int
+	// Encoding for marshalling values into records.
+	// Optional (encoding.JSON by default).
+	Encoding encoding.Encoding
+	// Maximum number of hints per replica. When it's reached new hints are dropped,
+	// the anti-entropy pass repairs such keys.
+	// Optional (10000 by default).
+	MaxHints int
+	// Interval in which hints are delivered in the background.
+	// Optional (10s by default).
+	HandoffInterval time.Duration
+	// Interval in which the anti-entropy pass runs in the background. 0 disables it.
+	// Optional (0 by default).
+	AntiEntropyInterval time.Duration
+	// Age after which the anti-entropy pass removes tombstones that all replicas have.
+	// Without anti-entropy pass tombstones are never removed.
+	// They're also never removed if a replica doesn't implement gokv.ConditionalStore,
+	// because a value that's written concurrently could be deleted along with the tombstone otherwise.
+	// Optional (24h by default).
+	TombstoneTTL time.Duration
+	// ErrorHandler is called for errors that can't be returned to the caller,
+	// e.g. errors of read repairs or of the background tasks.
+	// Optional (errors are ignored by default).
+	ErrorHandler func(k string, err error)
+}
+
+// Default values for the options.
+const (
+	DefaultMaxHints        = 10000
+	DefaultHandoffInterval = 10 * time.Second
+	DefaultTombstoneTTL    = 24 * time.Hour
+)
+
+// Store is a gokv.ContextStore that replicates all values to multiple stores.
+type Store struct {
+	// lastVersion is the version of the last record that was created, accessed atomically.
+	// It's the first field so that it's 64-bit aligned on 32-bit platforms.
+	lastVersion int64
+
+	replicas []gokv.ContextStore
+	options  Options
+
+	hintsLock sync.Mutex
+	// hints contains the writes that failed for each replica, by key.
+	hints []map[string]hint
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	stopped  sync.WaitGroup
+	// pending tracks the operations that finish in the background after the quorum was reached.
+	pending sync.WaitGroup
+}
+
+// hint is a write that failed on a replica.
+type hint struct {
+	record Record
+}
+
+// NewStore creates a new replicated store with the given replicas.
+// Close() stops the background tasks and closes all replicas.
+func NewStore(replicas []gokv.ContextStore, options *Options) (*Store, error) {
+	result := &Store{
+		replicas: replicas,
+		stop:     make(chan struct{}),
+	}
+	if options != nil {
+		result.options = *options
+	}
+
+	n := len(replicas)
+	if n == 0 {
+		return nil, errors.New("at least one replica is required")
+	}
+
+	// Set default values
+	if result.options.WriteQuorum <= 0 {
+		result.options.WriteQuorum = n/2 + 1
+	}
+	if result.options.ReadQuorum <= 0 {
+		result.options.ReadQuorum = n/2 + 1
+	}
+	if result.options.Encoding == nil {
+		result.options.Encoding = encoding.JSON
+	}
+	if result.options.MaxHints <= 0 {
+		result.options.MaxHints = DefaultMaxHints
+	}
+	if result.options.HandoffInterval <= 0 {
+		result.options.HandoffInterval = DefaultHandoffInterval
+	}
+	if result.options.TombstoneTTL <= 0 {
+		result.options.TombstoneTTL = DefaultTombstoneTTL
+	}
+	if result.options.ErrorHandler == nil {
+		result.options.ErrorHandler = func(string, error) {}
+	}
+
+	if result.options.WriteQuorum > n || result.options.ReadQuorum > n {
+		return nil, fmt.Errorf("the quorums must not be greater than the number of replicas (%d)", n)
+	}
+
+	result.hints = make([]map[string]hint, n)
+	for i := range result.hints {
+		result.hints[i] = make(map[string]hint)
+	}
+	result.runEvery(result.options.HandoffInterval, func(ctx context.Context) error {
+		return result.Handoff(ctx)
+	})
+	if result.options.AntiEntropyInterval > 0 {
+		result.runEvery(result.options.AntiEntropyInterval, func(ctx context.Context) error {
+			_, err := result.AntiEntropy(ctx)
+			return err
+		})
+	}
+	return result, nil
+}
+
+// runEvery calls f in the given interval until the store is closed.
+func (s *Store) runEvery(interval time.Duration, f func(ctx context.Context) error) {
+	s.stopped.Add(1)
+	go func() {
+		defer s.stopped.Done()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			<-s.stop
+			cancel()
+		}()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+				if err := f(ctx); err != nil && ctx.Err() == nil {
+					s.options.ErrorHandler("", err)
+				}
+			}
+		}
+	}()
+}
+
+// Set stores the given value for the given key on all replicas.
+// It fails if less than the write quorum of replicas acknowledged the write.
+// The key must not be "" and the value must not be nil.
+func (s *Store) Set(ctx context.Context, k string, v interface{}) error {
+	if err := check.KeyAndValue(k, v); err != nil {
+		return err
+	}
+
+	data, err := s.options.Encoding.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.write(ctx, k, Record{
+		Version: s.nextVersion(),
+		Data:    data,
+	})
+}
+
+// Delete deletes the stored value for the given key by writing a tombstone to all replicas.
+// It fails if less than the write quorum of replicas acknowledged the deletion.
+// Deleting a non-existing key-value pair does NOT lead to an error.
+// The key must not be "".
+func (s *Store) Delete(ctx context.Context, k string) error {
+	if err := check.Key(k); err != nil {
+		return err
+	}
+
+	return s.write(ctx, k, Record{
+		Version: s.nextVersion(),
+		Deleted: true,
+	})
+}
+
+// nextVersion returns the version for a new record, which is the current time,
+// but always higher than the version of the previous record.
+// Otherwise two writes in the same clock tick or after the clock was set back
+// would get a version that isn't higher, and the replicas would reject the second one.
+func (s *Store) nextVersion() int64 {
+	for {
+		last := atomic.LoadInt64(&s.lastVersion)
+		version := time.Now().UnixNano()
+		if version <= last {
+			version = last + 1
+		}
+		if atomic.CompareAndSwapInt64(&s.lastVersion, last, version) {
+			return version
+		}
+	}
+}
+
+type writeResult struct {
+	i       int
+	written bool
+	err     error
+}
+
+// write writes the record to all replicas concurrently and returns when the write quorum acknowledged it
+// or it can't be reached anymore. The remaining writes finish in the background.
+// Failed writes are kept as hints.
+// If none of the replicas that acknowledged the write stored the record, because they already had a newer one,
+// ErrStale is returned.
+func (s *Store) write(ctx context.Context, k string, record Record) error {
+	// The writes that finish after the quorum was reached must not be canceled
+	// when the caller's context is done, which usually happens as soon as write returns.
+	// Until then they're canceled with the caller's context.
+	writeCtx, cancelWrite := context.WithCancel(context.Background())
+	quorumDone := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			cancelWrite()
+		case <-quorumDone:
+		}
+	}()
+
+	n := len(s.replicas)
+	results := make(chan writeResult, n)
+	for i, replica := range s.replicas {
+		go func(i int, replica gokv.ContextStore) {
+			written, err := setIfNewer(writeCtx, replica, k, record)
+			results <- writeResult{i: i, written: written, err: err}
+		}(i, replica)
+	}
+
+	handle := func(res writeResult) {
+		if res.err != nil {
+			s.addHint(res.i, k, record)
+		} else {
+			s.removeHint(res.i, k, record)
+		}
+	}
+	acks, writes, failures := 0, 0, 0
+	var firstErr error
+	for acks < s.options.WriteQuorum && failures <= n-s.options.WriteQuorum {
+		res := <-results
+		handle(res)
+		if res.err != nil {
+			failures++
+			if firstErr == nil {
+				firstErr = res.err
+			}
+			continue
+		}
+		acks++
+		if res.written {
+			writes++
+		}
+	}
+	close(quorumDone)
+
+	if remaining := n - acks - failures; remaining > 0 {
+		s.pending.Add(1)
+		go func() {
+			defer s.pending.Done()
+			defer cancelWrite()
+			for j := 0; j < remaining; j++ {
+				res := <-results
+				handle(res)
+				if res.err != nil {
+					s.options.ErrorHandler(k, res.err)
+				}
+			}
+		}()
+	} else {
+		cancelWrite()
+	}
+
+	if acks < s.options.WriteQuorum {
+		return fmt.Errorf("the write quorum wasn't reached, only %d of %d replicas acknowledged the write: %v",
+			acks, s.options.WriteQuorum, firstErr)
+	}
+	if writes == 0 {
+		return ErrStale
+	}
+	return nil
+}
+
+// setIfNewer writes the record to the replica, unless the replica already has a record
+// with the same or a higher version. It returns whether the record was written.
+// If the replica implements gokv.ConditionalStore the write is conditional,
+// so that a newer record that's written concurrently isn't overwritten.
+func setIfNewer(ctx context.Context, replica gokv.ContextStore, k string, record Record) (written bool, err error) {
+	conditional, ok := replica.(gokv.ConditionalStore)
+	if !ok {
+		var current Record
+		found, err := replica.Get(ctx, k, &current)
+		if err != nil {
+			return false, err
+		}
+		if found && current.Version >= record.Version {
+			return false, nil
+		}
+		return true, replica.Set(ctx, k, record)
+	}
+
+	for {
+		var current Record
+		found, version, err := conditional.GetWithVersion(ctx, k, &current)
+		if err != nil {
+			return false, err
+		}
+		if found && current.Version >= record.Version {
+			return false, nil
+		}
+		var stored bool
+		if found {
+			stored, err = conditional.CompareAndSwap(ctx, k, version, record)
+		} else {
+			stored, err = conditional.SetIfNotExists(ctx, k, record)
+		}
+		if err != nil || stored {
+			return stored, err
+		}
+		// The record was changed concurrently, compare again
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+	}
+}
+
+type readResult struct {
+	i      int
+	record Record
+	found  bool
+	err    error
+}
+
+// Get retrieves the newest value for the given key from the replicas.
+// It fails if less than the read quorum of replicas responded.
+// Replicas that responded with an older value, without value or without tombstone
+// are repaired with the newest record.
+// If no value is found or the newest record is a tombstone it returns (false, nil).
+// The key must not be "" and the pointer must not be nil.
+func (s *Store) Get(ctx context.Context, k string, v interface{}) (found bool, err error) {
+	if err := check.KeyAndValue(k, v); err != nil {
+		return false, err
+	}
+
+	record, found, err := s.read(ctx, k)
+	if err != nil || !found {
+		return false, err
+	}
+	return true, s.options.Encoding.Unmarshal(record.Data, v)
+}
+
+// read reads the record for the given key from the replicas and returns when the read quorum responded
+// or it can't be reached anymore. The replicas that responded and are outdated are repaired,
+// the remaining reads finish in the background and their replicas are repaired as well.
+// It returns false if there's no record or the newest one is a tombstone.
+func (s *Store) read(ctx context.Context, k string) (Record, bool, error) {
+	n := len(s.replicas)
+	results := make(chan readResult, n)
+	for i, replica := range s.replicas {
+		go func(i int, replica gokv.ContextStore) {
+			res := readResult{i: i}
+			res.found, res.err = replica.Get(ctx, k, &res.record)
+			results <- res
+		}(i, replica)
+	}
+
+	var responses []readResult
+	failures := 0
+	var firstErr error
+	for len(responses) < s.options.ReadQuorum && failures <= n-s.options.ReadQuorum {
+		res := <-results
+		if res.err != nil {
+			failures++
+			if firstErr == nil {
+				firstErr = res.err
+			}
+			continue
+		}
+		responses = append(responses, res)
+	}
+
+	newest, found := newestRecord(responses)
+	if found {
+		for _, res := range responses {
+			if _, err := s.repair(ctx, k, res, newest); err != nil {
+				s.options.ErrorHandler(k, err)
+			}
+		}
+	}
+
+	if remaining := n - len(responses) - failures; remaining > 0 {
+		s.pending.Add(1)
+		go func() {
+			defer s.pending.Done()
+			for j := 0; j < remaining; j++ {
+				res := <-results
+				if res.err != nil || !found {
+					continue
+				}
+				// The caller's context may be done already
+				if _, err := s.repair(context.Background(), k, res, newest); err != nil {
+					s.options.ErrorHandler(k, err)
+				}
+			}
+		}()
+	}
+
+	if len(responses) < s.options.ReadQuorum {
+		return Record{}, false, fmt.Errorf("the read quorum wasn't reached, only %d of %d replicas responded: %v",
+			len(responses), s.options.ReadQuorum, firstErr)
+	}
+	if !found {
+		return Record{}, false, nil
+	}
+	return newest, !newest.Deleted, nil
+}
+
+// newestRecord returns the record with the highest version of all responses that found one,
+// which can be a tombstone.
+func newestRecord(responses []readResult) (newest Record, found bool) {
+	for _, res := range responses {
+		if res.found && (!found || res.record.Version > newest.Version) {
+			newest = res.record
+			found = true
+		}
+	}
+	return newest, found
+}
+
+// repair writes the newest record, which can be a tombstone,
+// to the replica of the given response if its record is older or missing.
+// It returns whether the replica was repaired.
+func (s *Store) repair(ctx context.Context, k string, res readResult, newest Record) (bool, error) {
+	if res.found && res.record.Version >= newest.Version {
+		return false, nil
+	}
+	return setIfNewer(ctx, s.replicas[res.i], k, newest)
+}
+
+// Keys returns an iterator over the keys of all replicas, each key only once.
+// Keys whose newest record is a tombstone are skipped, which requires a read of each key.
+// Replicas that fail are skipped, as long as the read quorum of replicas succeeded.
+// All keys are collected in memory before the iteration starts.
+func (s *Store) Keys(ctx context.Context) gokv.KeysIterator {
+	it := iterator.New(ctx)
+	go func() {
+		// Collect the keys first, because some stores can't be modified during iteration,
+		// which happens when outdated replicas are repaired by the reads
+		var lock sync.Mutex
+		keys := make(map[string]struct{})
+		var errs []error
+		var wg sync.WaitGroup
+		for _, replica := range s.replicas {
+			wg.Add(1)
+			go func(replica gokv.ContextStore) {
+				defer wg.Done()
+				src := replica.Keys(ctx)
+				for k := range src.Ch() {
+					lock.Lock()
+					keys[k] = struct{}{}
+					lock.Unlock()
+				}
+				if err := src.Err(); err != nil {
+					lock.Lock()
+					errs = append(errs, err)
+					lock.Unlock()
+				}
+			}(replica)
+		}
+		wg.Wait()
+
+		if ctx.Err() != nil {
+			it.Close(ctx.Err())
+			return
+		}
+		if len(s.replicas)-len(errs) < s.options.ReadQuorum {
+			it.Close(errs[0])
+			return
+		}
+		for k := range keys {
+			_, found, err := s.read(ctx, k)
+			if err != nil {
+				it.Close(err)
+				return
+			}
+			if !found {
+				continue
+			}
+			if err := it.Write(k); err != nil {
+				it.Close(err)
+				return
+			}
+		}
+		it.Close(nil)
+	}()
+	return it
+}
+
+// addHint keeps the record as hint for the replica, unless there's a hint with a newer record.
+func (s *Store) addHint(i int, k string, record Record) {
+	s.hintsLock.Lock()
+	defer s.hintsLock.Unlock()
+	hints := s.hints[i]
+	existing, ok := hints[k]
+	if ok && existing.record.Version >= record.Version {
+		return
+	}
+	if !ok && len(hints) >= s.options.MaxHints {
+		return
+	}
+	hints[k] = hint{record: record}
+}
+
+// removeHint removes the hint for the replica after the given record was written to it,
+// unless the hint contains a newer record.
+func (s *Store) removeHint(i int, k string, record Record) {
+	s.hintsLock.Lock()
+	defer s.hintsLock.Unlock()
+	if existing, ok := s.hints[i][k]; ok && existing.record.Version <= record.Version {
+		delete(s.hints[i], k)
+	}
+}
+
+// Hints returns the number of hints for each replica, i.e. the number of writes that weren't delivered yet.
+func (s *Store) Hints() []int {
+	s.hintsLock.Lock()
+	defer s.hintsLock.Unlock()
+	result := make([]int, len(s.hints))
+	for i, hints := range s.hints {
+		result[i] = len(hints)
+	}
+	return result
+}
+
+// Handoff delivers the hints to their replicas.
+// It's called in the background in the interval of Options.HandoffInterval,
+// but can also be called when it's known that a replica is available again.
+// Records are only written if the replica doesn't have a newer version.
+// Hints that can't be delivered are kept and the first error is returned.
+func (s *Store) Handoff(ctx context.Context) error {
+	var firstErr error
+	for i, replica := range s.replicas {
+		s.hintsLock.Lock()
+		hints := make(map[string]hint, len(s.hints[i]))
+		for k, h := range s.hints[i] {
+			hints[k] = h
+		}
+		s.hintsLock.Unlock()
+
+		for k, h := range hints {
+			_, err := setIfNewer(ctx, replica, k, h.record)
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				// The replica is probably still unavailable
+				break
+			}
+			s.removeHint(i, k, h.record)
+		}
+	}
+	return firstErr
+}
+
+// AntiEntropy compares the keys of all replicas and repairs the replicas
+// where a record is missing or older than the newest one.
+// Tombstones that all replicas have and that are older than Options.TombstoneTTL are removed.
+// It returns the number of repaired records.
+// It's called in the background if Options.AntiEntropyInterval is set.
+// All replicas must be available.
+func (s *Store) AntiEntropy(ctx context.Context) (repaired int, err error) {
+	// Collect the keys first, because some stores can't be modified during iteration
+	keys := make(map[string]struct{})
+	for _, replica := range s.replicas {
+		it := replica.Keys(ctx)
+		for k := range it.Ch() {
+			keys[k] = struct{}{}
+		}
+		if err := it.Err(); err != nil {
+			return 0, err
+		}
+	}
+
+	for k := range keys {
+		responses := make([]readResult, len(s.replicas))
+		for i, replica := range s.replicas {
+			responses[i].i = i
+			responses[i].found, err = replica.Get(ctx, k, &responses[i].record)
+			if err != nil {
+				return repaired, err
+			}
+		}
+		newest, found := newestRecord(responses)
+		if !found {
+			// Removed in the meantime
+			continue
+		}
+		for _, res := range responses {
+			written, err := s.repair(ctx, k, res, newest)
+			if err != nil {
+				return repaired, err
+			}
+			if written {
+				repaired++
+			}
+		}
+		if newest.Deleted && time.Since(time.Unix(0, newest.Version)) > s.options.TombstoneTTL {
+			if err := s.removeTombstone(ctx, k, newest); err != nil {
+				return repaired, err
+			}
+		}
+	}
+	return repaired, nil
+}
+
+// removeTombstone removes the given tombstone from all replicas,
+// unless there are hints for the key, which could bring an older value back afterwards.
+// The tombstones are deleted with CompareAndDelete, so replicas where the record changed in the meantime are skipped.
+// If a replica doesn't implement gokv.ConditionalStore nothing is removed,
+// because the tombstone it keeps would be written to the other replicas again by the next repair.
+func (s *Store) removeTombstone(ctx context.Context, k string, tombstone Record) error {
+	conditionals := make([]gokv.ConditionalStore, len(s.replicas))
+	for i, replica := range s.replicas {
+		conditional, ok := replica.(gokv.ConditionalStore)
+		if !ok {
+			return nil
+		}
+		conditionals[i] = conditional
+	}
+
+	s.hintsLock.Lock()
+	for _, hints := range s.hints {
+		if _, ok := hints[k]; ok {
+			s.hintsLock.Unlock()
+			return nil
+		}
+	}
+	s.hintsLock.Unlock()
+
+	for _, conditional := range conditionals {
+		var current Record
+		found, version, err := conditional.GetWithVersion(ctx, k, &current)
+		if err != nil {
+			return err
+		}
+		if !found || current.Version != tombstone.Version || !current.Deleted {
+			continue
+		}
+		if _, err := conditional.CompareAndDelete(ctx, k, version); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close stops the background tasks, waits for the operations that are still finishing in the background
+// and closes all replicas.
+// The first error is returned, but all replicas are closed nonetheless.
+func (s *Store) Close() error {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+	s.stopped.Wait()
+	s.pending.Wait()
+
+	var firstErr error
+	for _, replica := range s.replicas {
+		if err := replica.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,495 @@
+package replica_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/SpeedyCoder/gokv"
+	"github.com/SpeedyCoder/gokv/internal/ctxconv"
+	"github.com/SpeedyCoder/gokv/internal/test"
+	"github.com/SpeedyCoder/gokv/replica"
+)
+
+// TestStore tests if reading from, writing to and deleting from the store works properly.
+func TestStore(t *testing.T) {
+	replicas, paths := createReplicas(t, 3)
+	store, err := replica.NewStore(replicas, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer test.CleanUp(store, paths...)
+
+	test.Store(ctxconv.ToStore(store), t)
+}
+
+// TestQuorum tests if operations succeed as long as the quorums are reached.
+func TestQuorum(t *testing.T) {
+	assert := require.New(t)
+	replicas, paths := createReplicas(t, 3)
+	store, err := replica.NewStore(replicas, nil)
+	assert.NoError(err)
+	defer test.CleanUp(store, paths...)
+	ctx := context.Background()
+
+	_, err = replica.NewStore(replicas, &replica.Options{WriteQuorum: 4})
+	assert.Error(err)
+
+	// One replica down
+	replicas[2].(*toggleStore).setDown(true)
+	assert.NoError(store.Set(ctx, "foo", test.Foo{Bar: "baz"}))
+	actual := new(test.Foo)
+	found, err := store.Get(ctx, "foo", actual)
+	assert.NoError(err)
+	assert.True(found)
+	assert.Equal("baz", actual.Bar)
+	waitForHints(t, store, []int{0, 0, 1})
+
+	// Two replicas down
+	replicas[1].(*toggleStore).setDown(true)
+	assert.Error(store.Set(ctx, "foo", test.Foo{Bar: "qux"}))
+	_, err = store.Get(ctx, "foo", actual)
+	assert.Error(err)
+	it := store.Keys(ctx)
+	for range it.Ch() {
+	}
+	assert.Error(it.Err())
+}
+
+// TestSlowReplica tests if operations return once the quorum is reached
+// and don't wait for a replica that doesn't respond.
+func TestSlowReplica(t *testing.T) {
+	assert := require.New(t)
+	replicas, paths := createReplicas(t, 3)
+	store, err := replica.NewStore(replicas, nil)
+	assert.NoError(err)
+	defer test.CleanUp(store, paths...)
+	ctx := context.Background()
+
+	slow := replicas[2].(*toggleStore)
+	release := slow.hang()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert.NoError(store.Set(ctx, "foo", "bar"))
+		var actual string
+		found, err := store.Get(ctx, "foo", &actual)
+		assert.NoError(err)
+		assert.True(found)
+		assert.Equal("bar", actual)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("The operations waited for the slow replica")
+	}
+	assert.Equal([]int{0, 0, 0}, store.Hints())
+
+	// The write to the slow replica fails in the background and becomes a hint
+	release()
+	waitForHints(t, store, []int{0, 0, 1})
+	assert.NoError(store.Handoff(ctx))
+	assert.Equal("bar", decode(t, record(t, slow, "foo")))
+}
+
+// TestReadRepair tests if replicas with older or missing values are repaired on read.
+func TestReadRepair(t *testing.T) {
+	assert := require.New(t)
+	replicas, paths := createReplicas(t, 3)
+	store, err := replica.NewStore(replicas, &replica.Options{ReadQuorum: 3})
+	assert.NoError(err)
+	defer test.CleanUp(store, paths...)
+	ctx := context.Background()
+
+	assert.NoError(store.Set(ctx, "foo", test.Foo{Bar: "baz"}))
+	newest := record(t, replicas[0], "foo")
+	assert.NoError(replicas[1].Set(ctx, "foo", replica.Record{Version: newest.Version - 1, Data: []byte(`{"Bar":"old"}`)}))
+	assert.NoError(replicas[2].Delete(ctx, "foo"))
+
+	actual := new(test.Foo)
+	found, err := store.Get(ctx, "foo", actual)
+	assert.NoError(err)
+	assert.True(found)
+	assert.Equal("baz", actual.Bar)
+	assert.Equal(newest, record(t, replicas[1], "foo"))
+	assert.Equal(newest, record(t, replicas[2], "foo"))
+}
+
+// TestHandoff tests if writes to an unavailable replica are delivered when it's available again.
+func TestHandoff(t *testing.T) {
+	assert := require.New(t)
+	replicas, paths := createReplicas(t, 3)
+	store, err := replica.NewStore(replicas, nil)
+	assert.NoError(err)
+	defer test.CleanUp(store, paths...)
+	ctx := context.Background()
+
+	assert.NoError(store.Set(ctx, "foo", "bar"))
+	down := replicas[2].(*toggleStore)
+	waitFor(t, func() bool {
+		return len(keys(t, down)) == 1
+	})
+	down.setDown(true)
+	assert.NoError(store.Set(ctx, "baz", "qux"))
+	assert.NoError(store.Delete(ctx, "foo"))
+	waitForHints(t, store, []int{0, 0, 2})
+	assert.Error(store.Handoff(ctx))
+	assert.Equal([]int{0, 0, 2}, store.Hints())
+
+	down.setDown(false)
+	assert.NoError(store.Handoff(ctx))
+	assert.Equal([]int{0, 0, 0}, store.Hints())
+	assert.Equal([]string{"baz", "foo"}, keys(t, down))
+	assert.Equal("qux", decode(t, record(t, down, "baz")))
+	assert.True(record(t, down, "foo").Deleted)
+	assert.Equal([]string{"baz"}, keys(t, store))
+}
+
+// TestDeleteOnRecoveredReplica tests if a value that's deleted while a replica is unavailable
+// doesn't come back when the replica is available again, before and after its hint was delivered.
+func TestDeleteOnRecoveredReplica(t *testing.T) {
+	assert := require.New(t)
+	replicas, paths := createReplicas(t, 3)
+	store, err := replica.NewStore(replicas, nil)
+	assert.NoError(err)
+	defer test.CleanUp(store, paths...)
+	ctx := context.Background()
+
+	assert.NoError(store.Set(ctx, "foo", "bar"))
+	down := replicas[2].(*toggleStore)
+	waitFor(t, func() bool {
+		return len(keys(t, down)) == 1
+	})
+	down.setDown(true)
+	assert.NoError(store.Delete(ctx, "foo"))
+	waitForHints(t, store, []int{0, 0, 1})
+	down.setDown(false)
+
+	// The replica still has the value, but the hint wasn't delivered yet
+	assert.False(record(t, down, "foo").Deleted)
+	for i := 0; i < 3; i++ {
+		found, err := store.Get(ctx, "foo", new(string))
+		assert.NoError(err)
+		assert.False(found)
+	}
+	assert.Empty(keys(t, store))
+	// The read repaired the replica with the tombstone instead of the other replicas with the value
+	waitFor(t, func() bool {
+		return record(t, down, "foo").Deleted
+	})
+	for _, r := range replicas {
+		assert.True(record(t, r, "foo").Deleted)
+	}
+
+	assert.NoError(store.Handoff(ctx))
+	_, err = store.AntiEntropy(ctx)
+	assert.NoError(err)
+	found, err := store.Get(ctx, "foo", new(string))
+	assert.NoError(err)
+	assert.False(found)
+	for _, r := range replicas {
+		assert.True(record(t, r, "foo").Deleted)
+	}
+}
+
+// TestTombstoneRemoval tests if the anti-entropy pass removes old tombstones.
+func TestTombstoneRemoval(t *testing.T) {
+	assert := require.New(t)
+	replicas, paths := createReplicas(t, 3)
+	store, err := replica.NewStore(replicas, &replica.Options{WriteQuorum: 3, TombstoneTTL: 200 * time.Millisecond})
+	assert.NoError(err)
+	defer test.CleanUp(store, paths...)
+	ctx := context.Background()
+
+	assert.NoError(store.Set(ctx, "foo", "bar"))
+	assert.NoError(store.Delete(ctx, "foo"))
+	assert.NoError(store.Set(ctx, "baz", "qux"))
+	_, err = store.AntiEntropy(ctx)
+	assert.NoError(err)
+	// Not old enough yet
+	assert.Equal([]string{"baz", "foo"}, keys(t, replicas[0]))
+
+	time.Sleep(250 * time.Millisecond)
+	_, err = store.AntiEntropy(ctx)
+	assert.NoError(err)
+	for _, r := range replicas {
+		assert.Equal([]string{"baz"}, keys(t, r))
+	}
+}
+
+// TestTombstoneRemovalWithoutConditional tests if tombstones are kept
+// when a replica doesn't implement gokv.ConditionalStore.
+func TestTombstoneRemovalWithoutConditional(t *testing.T) {
+	assert := require.New(t)
+	replicas, paths := createReplicas(t, 3)
+	replicas[2] = plainStore{replicas[2]}
+	store, err := replica.NewStore(replicas, &replica.Options{WriteQuorum: 3, TombstoneTTL: 50 * time.Millisecond})
+	assert.NoError(err)
+	defer test.CleanUp(store, paths...)
+	ctx := context.Background()
+
+	assert.NoError(store.Set(ctx, "foo", "bar"))
+	assert.NoError(store.Delete(ctx, "foo"))
+	time.Sleep(100 * time.Millisecond)
+	_, err = store.AntiEntropy(ctx)
+	assert.NoError(err)
+	for _, r := range replicas {
+		assert.True(record(t, r, "foo").Deleted)
+	}
+}
+
+// TestVersions tests if the versions of consecutive writes are strictly increasing.
+func TestVersions(t *testing.T) {
+	assert := require.New(t)
+	replicas, paths := createReplicas(t, 3)
+	store, err := replica.NewStore(replicas, &replica.Options{WriteQuorum: 3})
+	assert.NoError(err)
+	defer test.CleanUp(store, paths...)
+	ctx := context.Background()
+
+	var last int64
+	for i := 0; i < 100; i++ {
+		assert.NoError(store.Set(ctx, "foo", i))
+		r := record(t, replicas[0], "foo")
+		assert.True(r.Version > last, "The version didn't increase")
+		last = r.Version
+		var actual int
+		found, err := store.Get(ctx, "foo", &actual)
+		assert.NoError(err)
+		assert.True(found)
+		assert.Equal(i, actual)
+	}
+}
+
+// TestStale tests if writes fail when the replicas already have a newer record.
+func TestStale(t *testing.T) {
+	assert := require.New(t)
+	replicas, paths := createReplicas(t, 3)
+	store, err := replica.NewStore(replicas, nil)
+	assert.NoError(err)
+	defer test.CleanUp(store, paths...)
+	ctx := context.Background()
+
+	// Written by a store with a clock that's ahead
+	future := replica.Record{Version: time.Now().Add(time.Hour).UnixNano(), Data: []byte(`"future"`)}
+	for _, r := range replicas {
+		assert.NoError(r.Set(ctx, "foo", future))
+	}
+	assert.Equal(replica.ErrStale, store.Set(ctx, "foo", "bar"))
+	assert.Equal(replica.ErrStale, store.Delete(ctx, "foo"))
+	var actual string
+	found, err := store.Get(ctx, "foo", &actual)
+	assert.NoError(err)
+	assert.True(found)
+	assert.Equal("future", actual)
+}
+
+// TestAntiEntropy tests if values that are missing on some replicas are repaired.
+func TestAntiEntropy(t *testing.T) {
+	assert := require.New(t)
+	replicas, paths := createReplicas(t, 3)
+	store, err := replica.NewStore(replicas, &replica.Options{WriteQuorum: 3})
+	assert.NoError(err)
+	defer test.CleanUp(store, paths...)
+	ctx := context.Background()
+
+	assert.NoError(store.Set(ctx, "foo", "bar"))
+	assert.NoError(replicas[0].Set(ctx, "baz", replica.Record{Version: 1, Data: []byte(`"qux"`)}))
+	assert.Equal([]string{"foo"}, keys(t, replicas[1]))
+
+	repaired, err := store.AntiEntropy(ctx)
+	assert.NoError(err)
+	assert.Equal(2, repaired)
+	for _, r := range replicas {
+		assert.Equal([]string{"baz", "foo"}, keys(t, r))
+		assert.Equal("qux", decode(t, record(t, r, "baz")))
+	}
+
+	repaired, err = store.AntiEntropy(ctx)
+	assert.NoError(err)
+	assert.Equal(0, repaired)
+}
+
+func record(t *testing.T, store gokv.ContextStore, k string) replica.Record {
+	var r replica.Record
+	found, err := store.Get(context.Background(), k, &r)
+	if err != nil || !found {
+		t.Fatalf("Expected to find a record for %q, but was: %v, %v", k, found, err)
+	}
+	return r
+}
+
+func decode(t *testing.T, r replica.Record) string {
+	if len(r.Data) < 2 {
+		t.Fatalf("Unexpected data: %s", r.Data)
+	}
+	// The data is a JSON string
+	return string(r.Data[1 : len(r.Data)-1])
+}
+
+func keys(t *testing.T, store gokv.ContextStore) []string {
+	var result []string
+	it := store.Keys(context.Background())
+	for k := range it.Ch() {
+		result = append(result, k)
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// waitFor waits until the condition is true and fails the test if that takes too long.
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the condition")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// waitForHints waits until the store has the expected number of hints for each replica.
+func waitForHints(t *testing.T, store *replica.Store, expected []int) {
+	t.Helper()
+	waitFor(t, func() bool {
+		return reflect.DeepEqual(expected, store.Hints())
+	})
+}
+
+// toggleStore fails all operations while it's down.
+// While it hangs, operations block until they are released and fail then.
+// It implements gokv.ConditionalStore, the wrapped store must implement it as well.
+type toggleStore struct {
+	gokv.ContextStore
+	lock    sync.Mutex
+	down    bool
+	release chan struct{}
+}
+
+var errDown = errors.New("the replica is down")
+
+func (s *toggleStore) setDown(down bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.down = down
+}
+
+// hang makes all operations block until the returned function is called.
+func (s *toggleStore) hang() func() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	release := make(chan struct{})
+	s.release = release
+	return func() {
+		s.lock.Lock()
+		s.release = nil
+		s.lock.Unlock()
+		close(release)
+	}
+}
+
+func (s *toggleStore) isDown() bool {
+	s.lock.Lock()
+	release := s.release
+	down := s.down
+	s.lock.Unlock()
+	if release != nil {
+		<-release
+		return true
+	}
+	return down
+}
+
+func (s *toggleStore) Set(ctx context.Context, k string, v interface{}) error {
+	if s.isDown() {
+		return errDown
+	}
+	return s.ContextStore.Set(ctx, k, v)
+}
+
+func (s *toggleStore) Get(ctx context.Context, k string, v interface{}) (bool, error) {
+	if s.isDown() {
+		return false, errDown
+	}
+	return s.ContextStore.Get(ctx, k, v)
+}
+
+func (s *toggleStore) Delete(ctx context.Context, k string) error {
+	if s.isDown() {
+		return errDown
+	}
+	return s.ContextStore.Delete(ctx, k)
+}
+
+func (s *toggleStore) Keys(ctx context.Context) gokv.KeysIterator {
+	if s.isDown() {
+		return failedIterator{}
+	}
+	return s.ContextStore.Keys(ctx)
+}
+
+func (s *toggleStore) GetWithVersion(ctx context.Context, k string, v interface{}) (bool, uint64, error) {
+	if s.isDown() {
+		return false, 0, errDown
+	}
+	return s.ContextStore.(gokv.ConditionalStore).GetWithVersion(ctx, k, v)
+}
+
+func (s *toggleStore) SetIfNotExists(ctx context.Context, k string, v interface{}) (bool, error) {
+	if s.isDown() {
+		return false, errDown
+	}
+	return s.ContextStore.(gokv.ConditionalStore).SetIfNotExists(ctx, k, v)
+}
+
+func (s *toggleStore) CompareAndSwap(ctx context.Context, k string, expectedVersion uint64, v interface{}) (bool, error) {
+	if s.isDown() {
+		return false, errDown
+	}
+	return s.ContextStore.(gokv.ConditionalStore).CompareAndSwap(ctx, k, expectedVersion, v)
+}
+
+func (s *toggleStore) CompareAndDelete(ctx context.Context, k string, expectedVersion uint64) (bool, error) {
+	if s.isDown() {
+		return false, errDown
+	}
+	return s.ContextStore.(gokv.ConditionalStore).CompareAndDelete(ctx, k, expectedVersion)
+}
+
+// plainStore hides the gokv.ConditionalStore methods of the wrapped store.
+type plainStore struct {
+	gokv.ContextStore
+}
+
+type failedIterator struct{}
+
+func (failedIterator) Ch() <-chan string {
+	ch := make(chan string)
+	close(ch)
+	return ch
+}
+
+func (failedIterator) Err() error {
+	return errDown
+}
+
+func createReplicas(t *testing.T, n int) ([]gokv.ContextStore, []string) {
+	var replicas []gokv.ContextStore
+	var paths []string
+	for i := 0; i < n; i++ {
+		store, path := test.NewBboltStore(t, nil)
+		replicas = append(replicas, &toggleStore{ContextStore: store})
+		paths = append(paths, path)
+	}
+	return replicas, paths
+}
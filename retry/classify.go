@@ -0,0 +1,228 @@
+package retry
+
+import (
+	"database/sql/driver"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+// Classifier reports whether an error is transient, so that the failed operation should be retried.
+type Classifier func(err error) bool
+
+// Any returns a Classifier that considers an error transient if any of the given classifiers does.
+func Any(classifiers ...Classifier) Classifier {
+	return func(err error) bool {
+		for _, classifier := range classifiers {
+			if classifier(err) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Transient classifies the errors of all backends with the classifiers of this package.
+var Transient = Any(Network, DynamoDB, Redis, SQL, TableStorage, Tablestore, Consul)
+
+// Network considers timeouts, temporary network errors and connection resets transient.
+// io.EOF and io.ErrUnexpectedEOF are only considered transient if they're wrapped in a *net.OpError,
+// because codecs return them for corrupt values as well, which would fail again.
+func Network(err error) bool {
+	return matchChain(err, func(err error) bool {
+		switch err := err.(type) {
+		case *net.OpError:
+			return matchChain(err.Err, func(err error) bool {
+				return err == io.EOF || err == io.ErrUnexpectedEOF
+			})
+		case syscall.Errno:
+			return err == syscall.ECONNRESET || err == syscall.ECONNREFUSED ||
+				err == syscall.ECONNABORTED || err == syscall.EPIPE
+		case net.Error:
+			return err.Timeout() || err.Temporary()
+		}
+		return false
+	})
+}
+
+// dynamoDBCodes are the codes of errors returned by the AWS SDK for DynamoDB that are transient.
+var dynamoDBCodes = map[string]bool{
+	"ProvisionedThroughputExceededException": true,
+	"ThrottlingException":                    true,
+	"RequestLimitExceeded":                   true,
+	"InternalServerError":                    true,
+	"ServiceUnavailable":                     true,
+}
+
+// DynamoDB considers throttling and internal server errors of DynamoDB transient.
+// It matches errors of the AWS SDK (awserr.Error) by their code.
+func DynamoDB(err error) bool {
+	return matchChain(err, func(err error) bool {
+		if awsErr, ok := err.(awserr.Error); ok {
+			return dynamoDBCodes[awsErr.Code()]
+		}
+		return false
+	})
+}
+
+// redisPrefixes are the prefixes of error replies of Redis that are transient.
+var redisPrefixes = []string{"LOADING ", "READONLY ", "CLUSTERDOWN ", "TRYAGAIN ", "MASTERDOWN "}
+
+// Redis considers connection resets, pool timeouts and error replies of Redis
+// that are sent while it's loading its data or failing over transient.
+func Redis(err error) bool {
+	if Network(err) {
+		return true
+	}
+	return matchChain(err, func(err error) bool {
+		msg := err.Error()
+		if _, ok := err.(interface{ RedisError() }); ok {
+			for _, prefix := range redisPrefixes {
+				if strings.HasPrefix(msg, prefix) {
+					return true
+				}
+			}
+			return false
+		}
+		return msg == "redis: connection pool timeout"
+	})
+}
+
+// sqlStates are the SQLSTATE codes that are transient.
+var sqlStates = map[string]bool{
+	"40001": true, // serialization_failure, also used by CockroachDB for transactions that must be retried
+	"40P01": true, // deadlock_detected
+	"55P03": true, // lock_not_available
+	"57P01": true, // admin_shutdown
+	"08000": true, // connection_exception
+	"08003": true, // connection_does_not_exist
+	"08006": true, // connection_failure
+}
+
+// SQL considers deadlocks, serialization failures, lock timeouts and bad connections
+// of the SQL backends (MySQL, PostgreSQL and CockroachDB) transient.
+// It matches errors of drivers with a SQLSTATE (like lib/pq and pgx)
+// and the deadlock (1213) and lock wait timeout (1205) errors of the MySQL driver.
+func SQL(err error) bool {
+	return matchChain(err, func(err error) bool {
+		if err == driver.ErrBadConn {
+			return true
+		}
+		switch err := err.(type) {
+		case interface{ SQLState() string }:
+			// pgx
+			return sqlStates[err.SQLState()]
+		case interface{ Get(k byte) string }:
+			// lib/pq, 'C' is the field of the SQLSTATE code
+			return sqlStates[err.Get('C')]
+		}
+		msg := err.Error()
+		return strings.HasPrefix(msg, "Error 1213") || strings.HasPrefix(msg, "Error 1205")
+	})
+}
+
+// TableStorage considers throttling and server errors of Azure Table Storage transient.
+// It matches errors of the Azure SDK by the status code in their message.
+func TableStorage(err error) bool {
+	return matchChain(err, func(err error) bool {
+		msg := err.Error()
+		if !strings.HasPrefix(msg, "storage: service returned error: ") {
+			return false
+		}
+		i := strings.Index(msg, "StatusCode=")
+		if i < 0 {
+			return false
+		}
+		code := msg[i+len("StatusCode="):]
+		if j := strings.IndexByte(code, ','); j >= 0 {
+			code = code[:j]
+		}
+		return transientStatusCode(code)
+	})
+}
+
+// tablestoreCodes are the codes of errors of Alibaba Cloud Table Store that are transient.
+var tablestoreCodes = []string{
+	"OTSServerBusy",
+	"OTSPartitionUnavailable",
+	"OTSTimeout",
+	"OTSServerUnavailable",
+	"OTSInternalServerError",
+	"OTSRowOperationConflict",
+	"OTSQuotaExhausted",
+	"OTSCapacityUnitExhausted",
+}
+
+// Tablestore considers throttling and server errors of Alibaba Cloud Table Store transient.
+// It matches errors of the Table Store SDK by the code at the beginning of their message.
+func Tablestore(err error) bool {
+	return matchChain(err, func(err error) bool {
+		msg := err.Error()
+		for _, code := range tablestoreCodes {
+			if strings.HasPrefix(msg, code+" ") {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Consul considers throttling and server errors of Consul transient.
+// It matches errors of the Consul API client by the response code in their message.
+func Consul(err error) bool {
+	if Network(err) {
+		return true
+	}
+	return matchChain(err, func(err error) bool {
+		msg := err.Error()
+		if !strings.HasPrefix(msg, "Unexpected response code: ") {
+			return false
+		}
+		code := strings.TrimPrefix(msg, "Unexpected response code: ")
+		if j := strings.IndexByte(code, ' '); j >= 0 {
+			code = code[:j]
+		}
+		return transientStatusCode(code)
+	})
+}
+
+// transientStatusCode reports whether the given HTTP status code is 429 or one of the 5xx codes that are transient.
+func transientStatusCode(code string) bool {
+	status, err := strconv.Atoi(code)
+	if err != nil {
+		return false
+	}
+	switch status {
+	case 429, 500, 502, 503, 504:
+		return true
+	}
+	return false
+}
+
+// matchChain calls match for the error and all errors that it wraps,
+// until match returns true.
+func matchChain(err error, match func(err error) bool) bool {
+	for err != nil {
+		if match(err) {
+			return true
+		}
+		switch wrapper := err.(type) {
+		case interface{ Unwrap() error }:
+			err = wrapper.Unwrap()
+		case interface{ Cause() error }:
+			err = wrapper.Cause()
+		case *net.OpError:
+			err = wrapper.Err
+		case *os.SyscallError:
+			err = wrapper.Err
+		default:
+			return false
+		}
+	}
+	return false
+}
@@ -0,0 +1,14 @@
+/*
+Package retry contains a wrapper that retries failed operations of a `gokv.ContextStore`
+with exponential backoff and jitter.
+
+Only errors that are classified as transient by a `retry.Classifier` are retried,
+for example throttling errors of DynamoDB, connection resets of Redis or deadlocks of SQL databases.
+Classifiers for the errors of the various backends are included and can be combined with `retry.Any()`.
+They match the errors via interfaces and error messages, so this package doesn't depend on any client library,
+except for the error type of the AWS SDK (`awserr.Error`).
+
+Backoffs are never longer than the remaining time until the deadline of the context:
+If the deadline would be exceeded the last error is returned right away.
+*/
+package retry
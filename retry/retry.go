@@ -0,0 +1,165 @@
+package retry
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/SpeedyCoder/gokv"
+)
+
+// Options are the options for the retry store.
+type Options struct {
+	// Maximum number of attempts per operation, including the first one.
+	// Optional (5 by default).
+	MaxAttempts int
+	// Backoff after the first failed attempt.
+	// Optional (50ms by default).
+	InitialBackoff time.Duration
+	// Upper bound for the backoff.
+	// Optional (5s by default).
+	MaxBackoff time.Duration
+	// Factor by which the backoff grows after each failed attempt.
+	// Optional (2 by default).
+	Multiplier float64
+	// Fraction of the backoff that's randomized, between 0 and 1.
+	// A backoff b is turned into a random duration between b*(1-Jitter) and b.
+	// A negative value disables jitter.
+	// Optional (0.5 by default).
+	Jitter float64
+	// Classifier decides which errors are retried.
+	// Optional (Transient by default).
+	Classifier Classifier
+	// OnRetry is called before each retry with the key, the number of the failed attempt and its error,
+	// e.g. for logging.
+	// Optional (nothing is done by default).
+	OnRetry func(k string, attempt int, err error)
+}
+
+// Default values for the options.
+const (
+	DefaultMaxAttempts    = 5
+	DefaultInitialBackoff = 50 * time.Millisecond
+	DefaultMaxBackoff     = 5 * time.Second
+	DefaultMultiplier     = 2
+	DefaultJitter         = 0.5
+)
+
+// NewStore creates a new gokv.ContextStore that retries Set, Get and Delete of the given store
+// when they fail with an error that the classifier considers transient.
+// Keys() isn't retried, because the iterator may already have returned some keys when it fails.
+func NewStore(store gokv.ContextStore, options *Options) gokv.ContextStore {
+	result := retryStore{
+		store: store,
+	}
+	if options != nil {
+		result.options = *options
+	}
+
+	// Set default values
+	if result.options.MaxAttempts <= 0 {
+		result.options.MaxAttempts = DefaultMaxAttempts
+	}
+	if result.options.InitialBackoff <= 0 {
+		result.options.InitialBackoff = DefaultInitialBackoff
+	}
+	if result.options.MaxBackoff <= 0 {
+		result.options.MaxBackoff = DefaultMaxBackoff
+	}
+	if result.options.Multiplier < 1 {
+		result.options.Multiplier = DefaultMultiplier
+	}
+	if result.options.Jitter == 0 {
+		result.options.Jitter = DefaultJitter
+	} else if result.options.Jitter < 0 {
+		result.options.Jitter = 0
+	} else if result.options.Jitter > 1 {
+		result.options.Jitter = 1
+	}
+	if result.options.Classifier == nil {
+		result.options.Classifier = Transient
+	}
+	if result.options.OnRetry == nil {
+		result.options.OnRetry = func(string, int, error) {}
+	}
+
+	return result
+}
+
+type retryStore struct {
+	store   gokv.ContextStore
+	options Options
+}
+
+// do calls f until it succeeds, fails with an error that isn't retryable,
+// the maximum number of attempts is reached or the next backoff would exceed the deadline of the context.
+func (s retryStore) do(ctx context.Context, k string, f func() error) error {
+	backoff := s.options.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		err := f()
+		if err == nil || attempt >= s.options.MaxAttempts || !s.options.Classifier(err) {
+			return err
+		}
+
+		wait := s.jitter(backoff)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return err
+		}
+		s.options.OnRetry(k, attempt, err)
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+
+		backoff = time.Duration(float64(backoff) * s.options.Multiplier)
+		if backoff > s.options.MaxBackoff {
+			backoff = s.options.MaxBackoff
+		}
+	}
+}
+
+func (s retryStore) jitter(backoff time.Duration) time.Duration {
+	return backoff - time.Duration(rand.Float64()*s.options.Jitter*float64(backoff))
+}
+
+// Set stores the given value for the given key, retrying transient errors.
+// The key must not be "" and the value must not be nil.
+func (s retryStore) Set(ctx context.Context, k string, v interface{}) error {
+	return s.do(ctx, k, func() error {
+		return s.store.Set(ctx, k, v)
+	})
+}
+
+// Get retrieves the stored value for the given key, retrying transient errors.
+// If no value is found it returns (false, nil).
+// The key must not be "" and the pointer must not be nil.
+func (s retryStore) Get(ctx context.Context, k string, v interface{}) (found bool, err error) {
+	err = s.do(ctx, k, func() error {
+		var err error
+		found, err = s.store.Get(ctx, k, v)
+		return err
+	})
+	return found, err
+}
+
+// Delete deletes the stored value for the given key, retrying transient errors.
+// Deleting a non-existing key-value pair does NOT lead to an error.
+// The key must not be "".
+func (s retryStore) Delete(ctx context.Context, k string) error {
+	return s.do(ctx, k, func() error {
+		return s.store.Delete(ctx, k)
+	})
+}
+
+// Keys returns an iterator over all keys in the store. It isn't retried.
+func (s retryStore) Keys(ctx context.Context) gokv.KeysIterator {
+	return s.store.Keys(ctx)
+}
+
+// Close closes the wrapped store.
+func (s retryStore) Close() error {
+	return s.store.Close()
+}
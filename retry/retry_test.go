@@ -0,0 +1,225 @@
+package retry_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SpeedyCoder/gokv"
+	"github.com/SpeedyCoder/gokv/internal/ctxconv"
+	"github.com/SpeedyCoder/gokv/internal/test"
+	"github.com/SpeedyCoder/gokv/retry"
+)
+
+// TestStore tests if reading from, writing to and deleting from the store works properly.
+func TestStore(t *testing.T) {
+	store, path := test.NewBboltStore(t, nil)
+	defer test.CleanUp(store, path)
+
+	test.Store(ctxconv.ToStore(retry.NewStore(store, nil)), t)
+}
+
+// TestRetry tests if transient errors are retried until the operation succeeds
+// and other errors are returned right away.
+func TestRetry(t *testing.T) {
+	assert := require.New(t)
+	inner, path := test.NewBboltStore(t, nil)
+	defer test.CleanUp(inner, path)
+	flaky := &flakyStore{ContextStore: inner}
+	var retries []int
+	store := retry.NewStore(flaky, &retry.Options{
+		InitialBackoff: time.Millisecond,
+		OnRetry: func(k string, attempt int, err error) {
+			retries = append(retries, attempt)
+		},
+	})
+	ctx := context.Background()
+
+	// Transient errors are retried
+	flaky.fail(2, throttlingError)
+	assert.NoError(store.Set(ctx, "foo", test.Foo{Bar: "baz"}))
+	assert.Equal([]int{1, 2}, retries)
+	assert.Equal(3, flaky.calls())
+
+	flaky.fail(1, syscall.ECONNRESET)
+	actual := new(test.Foo)
+	found, err := store.Get(ctx, "foo", actual)
+	assert.NoError(err)
+	assert.True(found)
+	assert.Equal("baz", actual.Bar)
+	assert.Equal(5, flaky.calls())
+
+	// Until the maximum number of attempts is reached
+	flaky.fail(10, throttlingError)
+	assert.Equal(throttlingError, store.Delete(ctx, "foo"))
+	assert.Equal(10, flaky.calls())
+
+	// Other errors aren't retried
+	flaky.fail(10, errors.New("foo"))
+	assert.EqualError(store.Delete(ctx, "foo"), "foo")
+	assert.Equal(11, flaky.calls())
+}
+
+// TestDeadline tests if no backoff exceeds the deadline of the context.
+func TestDeadline(t *testing.T) {
+	assert := require.New(t)
+	inner, path := test.NewBboltStore(t, nil)
+	defer test.CleanUp(inner, path)
+	flaky := &flakyStore{ContextStore: inner}
+	store := retry.NewStore(flaky, &retry.Options{
+		MaxAttempts:    100,
+		InitialBackoff: 20 * time.Millisecond,
+		Jitter:         -1,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	flaky.fail(100, throttlingError)
+	start := time.Now()
+	assert.Equal(throttlingError, store.Set(ctx, "foo", "bar"))
+	assert.True(time.Since(start) < 50*time.Millisecond, "took %v", time.Since(start))
+	// Attempts after 0ms and 20ms, the next one would be after 60ms
+	assert.Equal(2, flaky.calls())
+}
+
+// TestClassifiers tests the classification of errors of the various backends.
+func TestClassifiers(t *testing.T) {
+	testCases := []struct {
+		name       string
+		classifier retry.Classifier
+		err        error
+		expected   bool
+	}{
+		{"network reset", retry.Network, &net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)}, true},
+		{"network timeout", retry.Network, timeoutError{}, true},
+		{"network EOF", retry.Network, &net.OpError{Op: "read", Err: io.EOF}, true},
+		{"network other", retry.Network, io.EOF, false},
+		{"DynamoDB throttling", retry.DynamoDB, throttlingError, true},
+		{"DynamoDB validation", retry.DynamoDB, awserr.New("ValidationException", "Invalid key", nil), false},
+		{"DynamoDB non-AWS", retry.DynamoDB, codeError("ThrottlingException"), false},
+		{"Redis loading", retry.Redis, redisError("LOADING Redis is loading the dataset in memory"), true},
+		{"Redis wrong type", retry.Redis, redisError("WRONGTYPE Operation against a key holding the wrong kind of value"), false},
+		{"Redis EOF", retry.Redis, &net.OpError{Op: "read", Err: io.EOF}, true},
+		{"corrupt value", retry.Transient, io.ErrUnexpectedEOF, false},
+		{"Redis reset", retry.Redis, syscall.ECONNRESET, true},
+		{"SQL bad connection", retry.SQL, driver.ErrBadConn, true},
+		{"SQL pgx deadlock", retry.SQL, sqlStateError("40P01"), true},
+		{"SQL pgx unique violation", retry.SQL, sqlStateError("23505"), false},
+		{"SQL pq serialization failure", retry.SQL, pqError{'C': "40001"}, true},
+		{"SQL MySQL deadlock", retry.SQL, errors.New("Error 1213: Deadlock found when trying to get lock; try restarting transaction"), true},
+		{"SQL MySQL syntax", retry.SQL, errors.New("Error 1064: You have an error in your SQL syntax"), false},
+		{"Table Storage busy", retry.TableStorage, errors.New("storage: service returned error: StatusCode=503, ErrorCode=ServerBusy, ErrorMessage=The server is busy."), true},
+		{"Table Storage not found", retry.TableStorage, errors.New("storage: service returned error: StatusCode=404, ErrorCode=ResourceNotFound, ErrorMessage=Not found."), false},
+		{"Tablestore busy", retry.Tablestore, errors.New("OTSServerBusy Server is busy. 0005-abc"), true},
+		{"Consul throttled", retry.Consul, errors.New("Unexpected response code: 429 (rate limited)"), true},
+		{"Consul forbidden", retry.Consul, errors.New("Unexpected response code: 403 (Permission denied)"), false},
+		{"wrapped", retry.Transient, wrappedError{throttlingError}, true},
+		{"nil", retry.Transient, nil, false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := tc.classifier(tc.err); actual != tc.expected {
+				t.Errorf("Expected %v, but was %v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+type codeError string
+
+func (e codeError) Error() string { return string(e) }
+func (e codeError) Code() string  { return string(e) }
+
+var throttlingError = awserr.New("ProvisionedThroughputExceededException", "Rate exceeded", nil)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+type redisError string
+
+func (e redisError) Error() string { return string(e) }
+func (redisError) RedisError()     {}
+
+type sqlStateError string
+
+func (e sqlStateError) Error() string    { return "ERROR: " + string(e) }
+func (e sqlStateError) SQLState() string { return string(e) }
+
+type pqError map[byte]string
+
+func (e pqError) Error() string     { return "pq: " + e['C'] }
+func (e pqError) Get(k byte) string { return e[k] }
+
+type wrappedError struct {
+	err error
+}
+
+func (e wrappedError) Error() string { return "wrapped: " + e.err.Error() }
+func (e wrappedError) Unwrap() error { return e.err }
+
+// flakyStore fails the given number of next calls with the given error.
+type flakyStore struct {
+	gokv.ContextStore
+	lock     sync.Mutex
+	failures int
+	err      error
+	count    int
+}
+
+func (s *flakyStore) fail(n int, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.failures = n
+	s.err = err
+}
+
+func (s *flakyStore) calls() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.count
+}
+
+func (s *flakyStore) next() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.count++
+	if s.failures > 0 {
+		s.failures--
+		return s.err
+	}
+	return nil
+}
+
+func (s *flakyStore) Set(ctx context.Context, k string, v interface{}) error {
+	if err := s.next(); err != nil {
+		return err
+	}
+	return s.ContextStore.Set(ctx, k, v)
+}
+
+func (s *flakyStore) Get(ctx context.Context, k string, v interface{}) (bool, error) {
+	if err := s.next(); err != nil {
+		return false, err
+	}
+	return s.ContextStore.Get(ctx, k, v)
+}
+
+func (s *flakyStore) Delete(ctx context.Context, k string) error {
+	if err := s.next(); err != nil {
+		return err
+	}
+	return s.ContextStore.Delete(ctx, k)
+}
@@ -0,0 +1,9 @@
+/*
+Package scan contains wrappers that add filtered key iteration and key-value pair iteration
+to any `gokv.ContextStore`.
+
+Stores that implement `gokv.RangeStore` or `gokv.ItemsStore` natively are used as they are.
+For all other stores all keys are iterated and filtered on the client side,
+and values are retrieved with additional calls to the store.
+*/
+package scan
@@ -0,0 +1,125 @@
+package scan
+
+import (
+	"context"
+
+	"github.com/SpeedyCoder/gokv"
+	"github.com/SpeedyCoder/gokv/internal/iterator"
+)
+
+// Options are the options for the items store.
+type Options struct {
+	// Number of keys whose values are retrieved with a single GetMany() call
+	// if the wrapped store implements gokv.BatchStore.
+	// Optional (100 by default).
+	PageSize int
+}
+
+const (
+	DefaultPageSize = 100
+)
+
+// NewItemsStore creates a new gokv.ItemsStore.
+// If the given store already implements gokv.ItemsStore, it's returned unchanged.
+// Otherwise Items iterates over all keys of the store and retrieves their values.
+// If the store implements gokv.BatchStore the values are retrieved in pages via GetMany(),
+// otherwise one by one via Get().
+// Keys that are deleted during the iteration are skipped.
+func NewItemsStore(store gokv.ContextStore, options *Options) gokv.ItemsStore {
+	if s, ok := store.(gokv.ItemsStore); ok {
+		return s
+	}
+
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+
+	// Set default values
+	if opts.PageSize <= 0 {
+		opts.PageSize = DefaultPageSize
+	}
+
+	return itemsStore{
+		ContextStore: store,
+		pageSize:     opts.PageSize,
+	}
+}
+
+type itemsStore struct {
+	gokv.ContextStore
+	pageSize int
+}
+
+// Items returns an iterator over all key-value pairs in the store.
+// For each key-value pair newValue is called to create the pointer that the value gets unmarshalled into.
+func (s itemsStore) Items(ctx context.Context, newValue func() interface{}) gokv.ItemsIterator {
+	it := iterator.NewItems(ctx)
+	go func() {
+		keys := s.Keys(ctx)
+		err := s.writeItems(ctx, it, keys.Ch(), newValue)
+		if err != nil {
+			// Drain the keys so the iterator doesn't block forever.
+			go func() {
+				for range keys.Ch() {
+				}
+			}()
+			it.Close(err)
+			return
+		}
+		it.Close(keys.Err())
+	}()
+	return it
+}
+
+func (s itemsStore) writeItems(ctx context.Context, it *iterator.ItemsIterator, keys <-chan string, newValue func() interface{}) error {
+	batchStore, isBatchStore := s.ContextStore.(gokv.BatchStore)
+	if !isBatchStore {
+		for k := range keys {
+			v := newValue()
+			found, err := s.Get(ctx, k, v)
+			if err != nil {
+				return err
+			}
+			if !found {
+				continue
+			}
+			if err := it.Write(gokv.Item{Key: k, Value: v}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	page := make([]string, 0, s.pageSize)
+	writePage := func() error {
+		values, err := batchStore.GetMany(ctx, page, newValue)
+		if err != nil {
+			return err
+		}
+		// Keep the order of the keys
+		for _, k := range page {
+			v, found := values[k]
+			if !found {
+				continue
+			}
+			if err := it.Write(gokv.Item{Key: k, Value: v}); err != nil {
+				return err
+			}
+		}
+		page = page[:0]
+		return nil
+	}
+	for k := range keys {
+		page = append(page, k)
+		if len(page) == s.pageSize {
+			if err := writePage(); err != nil {
+				return err
+			}
+		}
+	}
+	if len(page) > 0 {
+		return writePage()
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package scan
+
+import (
+	"context"
+	"strings"
+
+	"github.com/SpeedyCoder/gokv"
+	"github.com/SpeedyCoder/gokv/internal/iterator"
+)
+
+// NewStore creates a new gokv.RangeStore.
+// If the given store already implements gokv.RangeStore, it's returned unchanged.
+// Otherwise KeysWithPrefix and KeysInRange iterate over all keys of the store
+// and only pass on the matching ones.
+func NewStore(store gokv.ContextStore) gokv.RangeStore {
+	if s, ok := store.(gokv.RangeStore); ok {
+		return s
+	}
+
+	return scanStore{
+		ContextStore: store,
+	}
+}
+
+type scanStore struct {
+	gokv.ContextStore
+}
+
+// KeysWithPrefix returns an iterator over all keys that start with the given prefix.
+func (s scanStore) KeysWithPrefix(ctx context.Context, prefix string) gokv.KeysIterator {
+	return iterator.Filter(ctx, s.Keys(ctx), func(k string) bool {
+		return strings.HasPrefix(k, prefix)
+	})
+}
+
+// KeysInRange returns an iterator over all keys k with start <= k < end.
+// An empty end means there's no upper bound.
+func (s scanStore) KeysInRange(ctx context.Context, start, end string) gokv.KeysIterator {
+	return iterator.Filter(ctx, s.Keys(ctx), func(k string) bool {
+		return k >= start && (end == "" || k < end)
+	})
+}
@@ -0,0 +1,64 @@
+package scan_test
+
+import (
+	"testing"
+
+	"github.com/SpeedyCoder/gokv"
+	"github.com/SpeedyCoder/gokv/batch"
+	"github.com/SpeedyCoder/gokv/internal/ctxconv"
+	"github.com/SpeedyCoder/gokv/internal/test"
+	"github.com/SpeedyCoder/gokv/scan"
+)
+
+// TestStore tests if reading from, writing to and deleting from the store works properly.
+func TestStore(t *testing.T) {
+	store, path := createStore(t)
+	defer test.CleanUp(store, path)
+	test.Store(ctxconv.ToStore(store), t)
+}
+
+// TestRange tests the client side filtering of keys.
+func TestRange(t *testing.T) {
+	store, path := createStore(t)
+	defer test.CleanUp(store, path)
+	test.RangeStore(store, t)
+}
+
+// TestItems tests the key-value pair iteration of the fallback implementation.
+func TestItems(t *testing.T) {
+	t.Run("single", func(t *testing.T) {
+		store, path := createStore(t)
+		defer test.CleanUp(store, path)
+		test.ItemsStore(scan.NewItemsStore(store, nil), t)
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		store, path := createStore(t)
+		defer test.CleanUp(store, path)
+		options := scan.Options{
+			PageSize: 7,
+		}
+		test.ItemsStore(scan.NewItemsStore(batch.NewStore(store, nil), &options), t)
+	})
+}
+
+// TestNative tests if a store that already implements gokv.RangeStore is returned as is.
+func TestNative(t *testing.T) {
+	store, path := createStore(t)
+	defer test.CleanUp(store, path)
+	if scan.NewStore(store) != store {
+		t.Error("Expected the store to be returned unchanged")
+	}
+	itemsStore := scan.NewItemsStore(store, nil)
+	if scan.NewItemsStore(itemsStore, nil) != itemsStore {
+		t.Error("Expected the store to be returned unchanged")
+	}
+}
+
+// createStore creates a store that doesn't implement gokv.RangeStore or gokv.ItemsStore natively.
+func createStore(t *testing.T) (gokv.RangeStore, string) {
+	inner, path := test.NewBboltStore(t, nil)
+	// The bbolt store implements both interfaces, so hide its native methods.
+	inner = ctxconv.ToContextStore(ctxconv.ToStore(inner))
+	return scan.NewStore(inner), path
+}
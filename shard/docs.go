@@ -0,0 +1,14 @@
+/*
+Package shard contains a store that spreads one keyspace across multiple `gokv.ContextStore`s,
+for example several Redis or Memcached instances without a cluster.
+
+Keys are assigned to the shards with a consistent-hash ring.
+Each shard has a number of virtual nodes on the ring, proportional to its weight,
+so adding a shard only moves about 1/N of the keys.
+
+After a shard was added with `AddShard()`, the keys that now belong to it can be moved with `Rebalance()`.
+Until then values that weren't moved yet are still found on their previous shards, also if multiple shards were added.
+
+`Health()` reports the state of each shard, based on a probe and on the errors of previous operations.
+*/
+package shard
@@ -0,0 +1,68 @@
+package shard
+
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+)
+
+// ring is a consistent-hash ring. It's immutable after it was created.
+type ring struct {
+	hash   func(k string) uint64
+	points []uint64
+	// owners contains the index of the shard of each point.
+	owners []int
+}
+
+// newRing creates a ring with virtualNodes*weight points for each shard.
+func newRing(shards []Shard, virtualNodes int, hash func(k string) uint64) *ring {
+	r := &ring{hash: hash}
+	type point struct {
+		hash  uint64
+		owner int
+	}
+	var points []point
+	for i, shard := range shards {
+		for j := 0; j < virtualNodes*shard.Weight; j++ {
+			points = append(points, point{
+				hash:  hash(shard.Name + "#" + strconv.Itoa(j)),
+				owner: i,
+			})
+		}
+	}
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].hash < points[j].hash
+	})
+	for _, p := range points {
+		r.points = append(r.points, p.hash)
+		r.owners = append(r.owners, p.owner)
+	}
+	return r
+}
+
+// owner returns the index of the shard for the given key,
+// which owns the first point at or after the hash of the key.
+func (r *ring) owner(k string) int {
+	h := r.hash(k)
+	i := sort.Search(len(r.points), func(i int) bool {
+		return r.points[i] >= h
+	})
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.owners[i]
+}
+
+// DefaultHash is the FNV-64a hash of the key, with the bits mixed by the finalizer of MurmurHash3,
+// so that similar keys are spread across the ring.
+func DefaultHash(k string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(k))
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
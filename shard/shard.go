@@ -0,0 +1,468 @@
+package shard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/SpeedyCoder/gokv"
+	"github.com/SpeedyCoder/gokv/internal/check"
+	"github.com/SpeedyCoder/gokv/internal/iterator"
+)
+
+// ErrNotRaw is returned by Rebalance if not all shards implement gokv.RawStore,
+// which is required to move values without knowing their type.
+var ErrNotRaw = errors.New("all shards must implement gokv.RawStore to move values")
+
+// probeKey is the key that's read from each shard to check its health.
+const probeKey = "gokv-shard-health-probe"
+
+// Shard is one of the stores that the keys are spread across.
+type Shard struct {
+	// Name of the shard, which determines its points on the ring.
+	// It must be unique and shouldn't change, because keys would be assigned to different shards otherwise.
+	Name string
+	// Store of the shard.
+	Store gokv.ContextStore
+	// Weight of the shard, relative to the other shards.
+	// A shard with weight 2 gets about twice as many keys as a shard with weight 1.
+	// Optional (1 by default).
+	Weight int
+}
+
+// Options are the options for the sharded store.
+type Options struct {
+	// Number of virtual nodes on the ring per shard with weight 1.
+	// More virtual nodes spread the keys more evenly.
+	// Optional (100 by default).
+	VirtualNodes int
+	// Hash function for keys and virtual nodes.
+	// Optional (DefaultHash by default).
+	Hash func(k string) uint64
+}
+
+// DefaultOptions is an Options object with default values.
+// VirtualNodes: 100, Hash: DefaultHash
+var DefaultOptions = Options{
+	VirtualNodes: 100,
+	Hash:         DefaultHash,
+}
+
+// Health is the health of a shard.
+type Health struct {
+	// Name of the shard.
+	Name string
+	// Error of the probe, nil if the shard is healthy.
+	Err error
+	// Latency of the probe.
+	Latency time.Duration
+	// Number of operations that failed since the store was created.
+	Errors uint64
+	// Error of the last operation that failed, nil if there was none.
+	LastErr error
+}
+
+// Healthy reports whether the probe of the shard succeeded.
+func (h Health) Healthy() bool {
+	return h.Err == nil
+}
+
+// Store is a gokv.ContextStore that spreads the keys across multiple shards.
+type Store struct {
+	options Options
+
+	lock   sync.RWMutex
+	shards []*shardState
+	ring   *ring
+	// previous contains the rings before each AddShard, oldest first, until Rebalance moved all keys.
+	previous []*ring
+}
+
+type shardState struct {
+	Shard
+
+	lock    sync.Mutex
+	errors  uint64
+	lastErr error
+}
+
+// record counts the error of an operation on the shard.
+func (s *shardState) record(err error) {
+	if err == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.errors++
+	s.lastErr = err
+}
+
+// NewStore creates a new sharded store with the given shards.
+// Close() closes all shards.
+func NewStore(shards []Shard, options *Options) (*Store, error) {
+	result := &Store{}
+	if options != nil {
+		result.options = *options
+	}
+
+	// Set default values
+	if result.options.VirtualNodes <= 0 {
+		result.options.VirtualNodes = DefaultOptions.VirtualNodes
+	}
+	if result.options.Hash == nil {
+		result.options.Hash = DefaultOptions.Hash
+	}
+
+	if len(shards) == 0 {
+		return nil, errors.New("at least one shard is required")
+	}
+	for _, shard := range shards {
+		if err := result.add(shard); err != nil {
+			return nil, err
+		}
+	}
+	result.ring = result.newRing()
+	return result, nil
+}
+
+// add validates the shard and appends it. The lock must be held.
+func (s *Store) add(shard Shard) error {
+	if shard.Name == "" || shard.Store == nil {
+		return errors.New("the name and the store of a shard must not be empty")
+	}
+	for _, existing := range s.shards {
+		if existing.Name == shard.Name {
+			return fmt.Errorf("a shard with the name %q already exists", shard.Name)
+		}
+	}
+	if shard.Weight <= 0 {
+		shard.Weight = 1
+	}
+	s.shards = append(s.shards, &shardState{Shard: shard})
+	return nil
+}
+
+// newRing creates the ring for the current shards. The lock must be held.
+func (s *Store) newRing() *ring {
+	shards := make([]Shard, len(s.shards))
+	for i, shard := range s.shards {
+		shards[i] = shard.Shard
+	}
+	return newRing(shards, s.options.VirtualNodes, s.options.Hash)
+}
+
+// AddShard adds a shard to the ring.
+// The keys that belong to the new shard from now on aren't moved,
+// but are still read from and deleted on their previous shards until Rebalance moved them.
+// It can be called multiple times before Rebalance.
+func (s *Store) AddShard(shard Shard) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := s.add(shard); err != nil {
+		return err
+	}
+	s.previous = append(s.previous, s.ring)
+	s.ring = s.newRing()
+	return nil
+}
+
+// route returns the shard of the given key and its previous shards,
+// if a rebalance is pending and the key belonged to different shards before.
+func (s *Store) route(k string) (current *shardState, previous []*shardState) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	owners := owners(k, s.ring, s.previous)
+	current = s.shards[owners[0]]
+	for _, i := range owners[1:] {
+		previous = append(previous, s.shards[i])
+	}
+	return current, previous
+}
+
+// owners returns the indexes of the shards the given key belongs to in the current ring
+// and in the previous rings, without duplicates.
+// The previous rings are ordered newest first, because a value written later is on the shard of a newer ring.
+func owners(k string, current *ring, previous []*ring) []int {
+	result := []int{current.owner(k)}
+	for i := len(previous) - 1; i >= 0; i-- {
+		owner := previous[i].owner(k)
+		seen := false
+		for _, j := range result {
+			if j == owner {
+				seen = true
+				break
+			}
+		}
+		if !seen {
+			result = append(result, owner)
+		}
+	}
+	return result
+}
+
+// Set stores the given value for the given key on its shard.
+// The key must not be "" and the value must not be nil.
+func (s *Store) Set(ctx context.Context, k string, v interface{}) error {
+	if err := check.KeyAndValue(k, v); err != nil {
+		return err
+	}
+
+	shard, _ := s.route(k)
+	err := shard.Store.Set(ctx, k, v)
+	shard.record(err)
+	return err
+}
+
+// Get retrieves the stored value for the given key from its shard,
+// or from its previous shards if it wasn't moved yet.
+// If no value is found it returns (false, nil).
+// The key must not be "" and the pointer must not be nil.
+func (s *Store) Get(ctx context.Context, k string, v interface{}) (found bool, err error) {
+	if err := check.KeyAndValue(k, v); err != nil {
+		return false, err
+	}
+
+	shard, previous := s.route(k)
+	found, err = shard.Store.Get(ctx, k, v)
+	shard.record(err)
+	for _, p := range previous {
+		if found || err != nil {
+			break
+		}
+		found, err = p.Store.Get(ctx, k, v)
+		p.record(err)
+	}
+	return found, err
+}
+
+// Delete deletes the stored value for the given key from its shard,
+// and from its previous shards if it wasn't moved yet.
+// Deleting a non-existing key-value pair does NOT lead to an error.
+// The key must not be "".
+func (s *Store) Delete(ctx context.Context, k string) error {
+	if err := check.Key(k); err != nil {
+		return err
+	}
+
+	shard, previous := s.route(k)
+	err := shard.Store.Delete(ctx, k)
+	shard.record(err)
+	for _, p := range previous {
+		if err != nil {
+			break
+		}
+		err = p.Store.Delete(ctx, k)
+		p.record(err)
+	}
+	return err
+}
+
+// Keys returns an iterator over the keys of all shards, in no particular order.
+// Keys that are stored on a shard they don't belong to are skipped,
+// except for keys on their previous shards while a rebalance is pending,
+// so a key can be returned twice until Rebalance is done.
+// The iteration fails with the first error of any shard.
+func (s *Store) Keys(ctx context.Context) gokv.KeysIterator {
+	s.lock.RLock()
+	shards := s.shards
+	current, previous := s.ring, s.previous
+	s.lock.RUnlock()
+
+	it := iterator.New(ctx)
+	go func() {
+		var wg sync.WaitGroup
+		var errLock sync.Mutex
+		var firstErr error
+		for i, shard := range shards {
+			i, shard := i, shard
+			src := iterator.Filter(ctx, shard.Store.Keys(ctx), func(k string) bool {
+				if current.owner(k) == i {
+					return true
+				}
+				for _, p := range previous {
+					if p.owner(k) == i {
+						return true
+					}
+				}
+				return false
+			})
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				var err error
+				for k := range src.Ch() {
+					if err = it.Write(k); err != nil {
+						break
+					}
+				}
+				if err == nil {
+					err = src.Err()
+				}
+				shard.record(err)
+				if err != nil {
+					errLock.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					errLock.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		it.Close(firstErr)
+	}()
+	return it
+}
+
+// Rebalance moves all keys that are stored on a shard they don't belong to, to their shard.
+// It's usually called after AddShard and returns the number of moved keys.
+// If a key already exists on its new shard, the value was written after AddShard
+// and the value on the previous shard is deleted instead of moved.
+// If a key exists on multiple previous shards because AddShard was called multiple times,
+// the value of the newest ring is moved and the others are deleted.
+// Values are moved as raw bytes, so all shards must implement gokv.RawStore.
+// Writes to a key while it's being moved can be lost.
+func (s *Store) Rebalance(ctx context.Context) (moved int, err error) {
+	s.lock.RLock()
+	shards := s.shards
+	current, previous := s.ring, s.previous
+	s.lock.RUnlock()
+
+	rawShards := make([]gokv.RawStore, len(shards))
+	for i, shard := range shards {
+		rawStore, ok := shard.Store.(gokv.RawStore)
+		if !ok {
+			return 0, ErrNotRaw
+		}
+		rawShards[i] = rawStore
+	}
+
+	// Collect the keys first, because some stores can't be modified during iteration
+	misplaced := make(map[string][]bool)
+	for i, src := range rawShards {
+		it := src.Keys(ctx)
+		for k := range it.Ch() {
+			if current.owner(k) == i {
+				continue
+			}
+			if misplaced[k] == nil {
+				misplaced[k] = make([]bool, len(rawShards))
+			}
+			misplaced[k][i] = true
+		}
+		if err := it.Err(); err != nil {
+			return moved, err
+		}
+	}
+
+	for k, stored := range misplaced {
+		// Starting with the newest ring, so only the newest value is moved
+		order := owners(k, current, previous)
+		for i := range stored {
+			order = append(order, i)
+		}
+		dst := rawShards[current.owner(k)]
+		for _, i := range order {
+			if !stored[i] {
+				continue
+			}
+			stored[i] = false
+			if err := move(ctx, rawShards[i], dst, k); err != nil {
+				return moved, fmt.Errorf("moving %q from shard %q failed: %v", k, shards[i].Name, err)
+			}
+			moved++
+		}
+	}
+
+	s.lock.Lock()
+	// All keys are on their shard in the ring the rebalance was done for,
+	// so only the rings of shards that were added in the meantime are still needed
+	for i, p := range s.previous {
+		if p == current {
+			s.previous = s.previous[i:]
+			break
+		}
+	}
+	if s.ring == current {
+		s.previous = nil
+	}
+	s.lock.Unlock()
+	return moved, nil
+}
+
+// move moves the value of the given key from src to dst, unless dst already contains a value.
+func move(ctx context.Context, src, dst gokv.RawStore, k string) error {
+	_, found, err := dst.GetBytes(ctx, k)
+	if err != nil {
+		return err
+	}
+	if !found {
+		data, found, err := src.GetBytes(ctx, k)
+		if err != nil {
+			return err
+		}
+		if !found {
+			// Deleted in the meantime
+			return nil
+		}
+		if err := dst.SetBytes(ctx, k, data); err != nil {
+			return err
+		}
+	}
+	return src.Delete(ctx, k)
+}
+
+// Health probes all shards concurrently by reading a key and reports their health.
+func (s *Store) Health(ctx context.Context) []Health {
+	s.lock.RLock()
+	shards := s.shards
+	s.lock.RUnlock()
+
+	result := make([]Health, len(shards))
+	var wg sync.WaitGroup
+	for i, shard := range shards {
+		wg.Add(1)
+		go func(i int, shard *shardState) {
+			defer wg.Done()
+			start := time.Now()
+			var err error
+			if rawStore, ok := shard.Store.(gokv.RawStore); ok {
+				_, _, err = rawStore.GetBytes(ctx, probeKey)
+			} else {
+				_, err = shard.Store.Get(ctx, probeKey, new(interface{}))
+			}
+			latency := time.Since(start)
+
+			shard.lock.Lock()
+			defer shard.lock.Unlock()
+			result[i] = Health{
+				Name:    shard.Name,
+				Err:     err,
+				Latency: latency,
+				Errors:  shard.errors,
+				LastErr: shard.lastErr,
+			}
+		}(i, shard)
+	}
+	wg.Wait()
+	return result
+}
+
+// Close closes all shards.
+// The first error is returned, but all shards are closed nonetheless.
+func (s *Store) Close() error {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	var firstErr error
+	for _, shard := range s.shards {
+		if err := shard.Store.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,263 @@
+package shard_test
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/SpeedyCoder/gokv"
+	"github.com/SpeedyCoder/gokv/internal/ctxconv"
+	"github.com/SpeedyCoder/gokv/internal/test"
+	"github.com/SpeedyCoder/gokv/shard"
+)
+
+// TestStore tests if reading from, writing to and deleting from the store works properly.
+func TestStore(t *testing.T) {
+	store, paths := createStore(t, 3)
+	defer test.CleanUp(store, paths...)
+
+	test.Store(ctxconv.ToStore(store), t)
+}
+
+// TestDistribution tests if keys are spread across the shards according to their weights.
+func TestDistribution(t *testing.T) {
+	assert := require.New(t)
+	shards, paths := createShards(t, 3)
+	shards[2].Weight = 2
+	store, err := shard.NewStore(shards, nil)
+	assert.NoError(err)
+	defer test.CleanUp(store, paths...)
+	ctx := context.Background()
+
+	for i := 0; i < 400; i++ {
+		assert.NoError(store.Set(ctx, "key"+strconv.Itoa(i), i))
+	}
+	counts := make([]int, len(shards))
+	for i, s := range shards {
+		counts[i] = len(keys(t, s.Store))
+	}
+	// Expected are about 100, 100 and 200
+	assert.Equal(400, counts[0]+counts[1]+counts[2])
+	assert.InDelta(100, counts[0], 40)
+	assert.InDelta(100, counts[1], 40)
+	assert.InDelta(200, counts[2], 50)
+	assert.Len(keys(t, store), 400)
+}
+
+// TestRebalance tests if values are still found after adding a shard
+// and moved to the new shard by Rebalance.
+func TestRebalance(t *testing.T) {
+	assert := require.New(t)
+	shards, paths := createShards(t, 3)
+	store, err := shard.NewStore(shards[:2], nil)
+	assert.NoError(err)
+	defer test.CleanUp(store, paths...)
+	ctx := context.Background()
+
+	for i := 0; i < 200; i++ {
+		assert.NoError(store.Set(ctx, "key"+strconv.Itoa(i), i))
+	}
+	assert.Error(store.AddShard(shards[0]))
+	assert.NoError(store.AddShard(shards[2]))
+
+	// Values that weren't moved are found on their previous shard
+	for i := 0; i < 200; i++ {
+		var actual int
+		found, err := store.Get(ctx, "key"+strconv.Itoa(i), &actual)
+		assert.NoError(err)
+		assert.True(found)
+		assert.Equal(i, actual)
+	}
+	// Values that are written after AddShard aren't overwritten by the rebalance
+	// and deleted values aren't moved
+	for i := 0; i < 200; i++ {
+		k := "key" + strconv.Itoa(i)
+		if i%2 == 0 {
+			assert.NoError(store.Set(ctx, k, -i))
+		} else if i%10 == 1 {
+			assert.NoError(store.Delete(ctx, k))
+		}
+	}
+	for _, k := range keys(t, shards[2].Store) {
+		i, err := strconv.Atoi(k[len("key"):])
+		assert.NoError(err)
+		assert.Equal(0, i%2)
+	}
+
+	moved, err := store.Rebalance(ctx)
+	assert.NoError(err)
+	newKeys := keys(t, shards[2].Store)
+	assert.InDelta(60, len(newKeys), 30)
+	assert.True(moved >= len(newKeys)/2)
+
+	for i := 0; i < 200; i++ {
+		var actual int
+		found, err := store.Get(ctx, "key"+strconv.Itoa(i), &actual)
+		assert.NoError(err)
+		switch {
+		case i%2 == 0:
+			assert.True(found)
+			assert.Equal(-i, actual)
+		case i%10 == 1:
+			assert.False(found)
+		default:
+			assert.True(found)
+			assert.Equal(i, actual)
+		}
+	}
+	assert.Len(keys(t, store), 180)
+	assert.Equal(180, len(keys(t, shards[0].Store))+len(keys(t, shards[1].Store))+len(newKeys))
+
+	// Nothing to move anymore
+	moved, err = store.Rebalance(ctx)
+	assert.NoError(err)
+	assert.Equal(0, moved)
+}
+
+// TestAddShardTwice tests if values are still found after adding two shards before a rebalance
+// and if the newest values are moved by Rebalance.
+func TestAddShardTwice(t *testing.T) {
+	assert := require.New(t)
+	shards, paths := createShards(t, 4)
+	store, err := shard.NewStore(shards[:2], nil)
+	assert.NoError(err)
+	defer test.CleanUp(store, paths...)
+	ctx := context.Background()
+
+	for i := 0; i < 200; i++ {
+		assert.NoError(store.Set(ctx, "key"+strconv.Itoa(i), i))
+	}
+	assert.NoError(store.AddShard(shards[2]))
+	for i := 0; i < 200; i += 2 {
+		assert.NoError(store.Set(ctx, "key"+strconv.Itoa(i), -i))
+	}
+	assert.NoError(store.AddShard(shards[3]))
+	for i := 0; i < 200; i += 3 {
+		assert.NoError(store.Set(ctx, "key"+strconv.Itoa(i), 1000+i))
+	}
+	for i := 1; i < 200; i += 10 {
+		assert.NoError(store.Delete(ctx, "key"+strconv.Itoa(i)))
+	}
+
+	check := func() {
+		for i := 0; i < 200; i++ {
+			var actual int
+			found, err := store.Get(ctx, "key"+strconv.Itoa(i), &actual)
+			assert.NoError(err)
+			switch {
+			case i%10 == 1:
+				assert.False(found)
+			case i%3 == 0:
+				assert.True(found)
+				assert.Equal(1000+i, actual)
+			case i%2 == 0:
+				assert.True(found)
+				assert.Equal(-i, actual)
+			default:
+				assert.True(found)
+				assert.Equal(i, actual)
+			}
+		}
+	}
+	check()
+	// Keys can be returned twice until the rebalance is done
+	unique := make(map[string]bool)
+	for _, k := range keys(t, store) {
+		unique[k] = true
+	}
+	assert.Len(unique, 180)
+
+	moved, err := store.Rebalance(ctx)
+	assert.NoError(err)
+	assert.True(moved > 0)
+	assert.NotEmpty(keys(t, shards[3].Store))
+	check()
+	assert.Len(keys(t, store), 180)
+	total := 0
+	for _, s := range shards {
+		total += len(keys(t, s.Store))
+	}
+	assert.Equal(180, total)
+
+	// Nothing to move anymore
+	moved, err = store.Rebalance(ctx)
+	assert.NoError(err)
+	assert.Equal(0, moved)
+}
+
+// TestHealth tests if the health of the shards is reported.
+func TestHealth(t *testing.T) {
+	assert := require.New(t)
+	shards, paths := createShards(t, 2)
+	shards[1].Store = failingStore{ContextStore: shards[1].Store}
+	store, err := shard.NewStore(shards, nil)
+	assert.NoError(err)
+	defer test.CleanUp(store, paths...)
+	ctx := context.Background()
+
+	for i := 0; i < 20; i++ {
+		_ = store.Set(ctx, "key"+strconv.Itoa(i), i)
+	}
+	health := store.Health(ctx)
+	assert.Len(health, 2)
+	assert.Equal("shard0", health[0].Name)
+	assert.True(health[0].Healthy())
+	assert.Zero(health[0].Errors)
+	assert.NoError(health[0].LastErr)
+	assert.Equal("shard1", health[1].Name)
+	assert.False(health[1].Healthy())
+	assert.NotZero(health[1].Errors)
+	assert.EqualError(health[1].LastErr, "unavailable")
+
+	_, err = store.Rebalance(ctx)
+	assert.Equal(shard.ErrNotRaw, err)
+}
+
+type failingStore struct {
+	gokv.ContextStore
+}
+
+func (failingStore) Set(context.Context, string, interface{}) error {
+	return errors.New("unavailable")
+}
+
+func (failingStore) Get(context.Context, string, interface{}) (bool, error) {
+	return false, errors.New("unavailable")
+}
+
+func keys(t *testing.T, store gokv.ContextStore) []string {
+	var result []string
+	it := store.Keys(context.Background())
+	for k := range it.Ch() {
+		result = append(result, k)
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func createStore(t *testing.T, n int) (*shard.Store, []string) {
+	shards, paths := createShards(t, n)
+	store, err := shard.NewStore(shards, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store, paths
+}
+
+func createShards(t *testing.T, n int) ([]shard.Shard, []string) {
+	var shards []shard.Shard
+	var paths []string
+	for i := 0; i < n; i++ {
+		store, path := test.NewBboltStore(t, nil)
+		shards = append(shards, shard.Shard{Name: "shard" + strconv.Itoa(i), Store: store})
+		paths = append(paths, path)
+	}
+	return shards, paths
+}
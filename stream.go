@@ -0,0 +1,27 @@
+package gokv
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// ErrNotFound is returned by StreamStore.Open when no value is stored for the key.
+var ErrNotFound = errors.New("no value found for the key")
+
+// StreamStore is a ContextStore that can store and retrieve large values as streams of bytes,
+// without keeping them in memory and without the codec of the store.
+type StreamStore interface {
+	ContextStore
+	// Put stores the data that's read from r until io.EOF for the given key,
+	// replacing any value that was streamed for the key before.
+	// size is the number of bytes that r returns, or -1 if it's unknown.
+	// If r returns a different number of bytes than size, nothing is stored and an error is returned.
+	// The key must not be "" and the reader must not be nil.
+	Put(ctx context.Context, k string, r io.Reader, size int64) error
+	// Open returns a reader for the value that was streamed for the given key.
+	// The reader must be closed when done.
+	// If no value is found it returns ErrNotFound.
+	// The key must not be "".
+	Open(ctx context.Context, k string) (io.ReadCloser, error)
+}
@@ -0,0 +1,27 @@
+/*
+Package tracing contains a wrapper that creates a span for every operation of a `gokv.ContextStore`.
+
+Spans are created by a Tracer, with an implementation for OpenCensus in package `tracing/opencensus`
+and an in-memory implementation for tests (`NewMemoryTracer()`).
+The spans are children of the span in the context that's passed to the operation
+and have the following attributes:
+
+	gokv.backend    - the configured name of the backend
+	gokv.key_hash   - a hash of the key, to correlate the operations of a key without the key itself
+	gokv.value_size - the size of the marshalled value (only if the backend reports it)
+	gokv.found      - whether Get found a value
+	gokv.keys_count - the number of keys returned by Keys
+
+By default the key hash is an FNV-64a hash, which doesn't keep keys private:
+Keys with little entropy, like user IDs or email addresses, can be recovered by hashing all candidates.
+If keys are sensitive, set `Options.KeyHashKey` to a secret, which makes the key hash an HMAC-SHA-256.
+
+The value size is reported by the backend via `gokv.ReportValueSize()`,
+which `bbolt`, `mongodb`, `postgresql`, `redis`, `dynamodb` and `etcd` do.
+The context that's passed to the backend also contains the span of the Tracer's library
+(e.g. the OpenCensus span), for clients of backends that are instrumented with the same library.
+The backends that implement `gokv.ContextStore` pass the context to their client calls.
+The clients of `redis`, `dynamodb` and `etcd` only implement `gokv.Store`,
+but have the methods `SetContext()`, `GetContext()` and `DeleteContext()`, which do the same.
+*/
+package tracing
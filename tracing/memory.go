@@ -0,0 +1,100 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RecordedSpan is a finished span of a MemoryTracer.
+type RecordedSpan struct {
+	// ID of the span, starting at 1.
+	ID uint64
+	// ID of the parent span, 0 if the span doesn't have a parent.
+	ParentID uint64
+	Name     string
+	// Attributes by their key.
+	Attributes map[string]interface{}
+	// Error the span was ended with.
+	Err      error
+	Duration time.Duration
+}
+
+// MemoryTracer is a Tracer that keeps all finished spans in memory, which is useful for tests.
+// It's safe for concurrent use.
+type MemoryTracer struct {
+	lock   *sync.Mutex
+	lastID uint64
+	spans  []RecordedSpan
+}
+
+// NewMemoryTracer creates a new MemoryTracer.
+func NewMemoryTracer() *MemoryTracer {
+	return &MemoryTracer{
+		lock: new(sync.Mutex),
+	}
+}
+
+type memorySpanKey struct{}
+
+// Start starts a span with the given name as child of the span of this tracer in ctx (if there's one).
+func (t *MemoryTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	t.lock.Lock()
+	t.lastID++
+	span := &memorySpan{
+		tracer: t,
+		start:  time.Now(),
+		data: RecordedSpan{
+			ID:         t.lastID,
+			Name:       name,
+			Attributes: make(map[string]interface{}),
+		},
+	}
+	t.lock.Unlock()
+
+	if parent, ok := ctx.Value(memorySpanKey{}).(*memorySpan); ok && parent.tracer == t {
+		span.data.ParentID = parent.data.ID
+	}
+	return context.WithValue(ctx, memorySpanKey{}, span), span
+}
+
+// Spans returns all finished spans in the order they were finished.
+func (t *MemoryTracer) Spans() []RecordedSpan {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return append([]RecordedSpan{}, t.spans...)
+}
+
+// Reset removes all finished spans.
+func (t *MemoryTracer) Reset() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.spans = nil
+}
+
+type memorySpan struct {
+	tracer *MemoryTracer
+	start  time.Time
+	// data is protected by the lock of the tracer.
+	data RecordedSpan
+}
+
+func (s *memorySpan) SetAttribute(key string, value interface{}) {
+	s.tracer.lock.Lock()
+	defer s.tracer.lock.Unlock()
+	s.data.Attributes[key] = value
+}
+
+func (s *memorySpan) End(err error) {
+	s.tracer.lock.Lock()
+	defer s.tracer.lock.Unlock()
+	s.data.Err = err
+	s.data.Duration = time.Since(s.start)
+	attributes := make(map[string]interface{}, len(s.data.Attributes))
+	for k, v := range s.data.Attributes {
+		attributes[k] = v
+	}
+	recorded := s.data
+	recorded.Attributes = attributes
+	s.tracer.spans = append(s.tracer.spans, recorded)
+}
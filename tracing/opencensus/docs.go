@@ -0,0 +1,6 @@
+/*
+Package opencensus contains a `tracing.Tracer` for [OpenCensus](https://opencensus.io/).
+
+The spans of store operations become children of the OpenCensus span in the context.
+*/
+package opencensus
@@ -0,0 +1,52 @@
+package opencensus
+
+import (
+	"context"
+	"fmt"
+
+	"go.opencensus.io/trace"
+
+	"github.com/SpeedyCoder/gokv/tracing"
+)
+
+// NewTracer creates a tracing.Tracer that starts OpenCensus spans.
+// The spans are children of the OpenCensus span in the context and have the kind "client".
+func NewTracer() tracing.Tracer {
+	return tracer{}
+}
+
+type tracer struct{}
+
+func (tracer) Start(ctx context.Context, name string) (context.Context, tracing.Span) {
+	ctx, s := trace.StartSpan(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+	return ctx, span{s: s}
+}
+
+type span struct {
+	s *trace.Span
+}
+
+func (s span) SetAttribute(key string, value interface{}) {
+	switch v := value.(type) {
+	case string:
+		s.s.AddAttributes(trace.StringAttribute(key, v))
+	case bool:
+		s.s.AddAttributes(trace.BoolAttribute(key, v))
+	case int:
+		s.s.AddAttributes(trace.Int64Attribute(key, int64(v)))
+	case int64:
+		s.s.AddAttributes(trace.Int64Attribute(key, v))
+	default:
+		s.s.AddAttributes(trace.StringAttribute(key, fmt.Sprint(v)))
+	}
+}
+
+func (s span) End(err error) {
+	if err != nil {
+		s.s.SetStatus(trace.Status{
+			Code:    trace.StatusCodeUnknown,
+			Message: err.Error(),
+		})
+	}
+	s.s.End()
+}
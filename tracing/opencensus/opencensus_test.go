@@ -0,0 +1,57 @@
+package opencensus_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opencensus.io/trace"
+
+	"github.com/SpeedyCoder/gokv/tracing/opencensus"
+)
+
+type exporter struct {
+	lock  *sync.Mutex
+	spans []*trace.SpanData
+}
+
+func (e *exporter) ExportSpan(s *trace.SpanData) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.spans = append(e.spans, s)
+}
+
+// TestTracer tests if spans are created as children of the OpenCensus span in the context.
+func TestTracer(t *testing.T) {
+	assert := require.New(t)
+	e := &exporter{lock: new(sync.Mutex)}
+	trace.RegisterExporter(e)
+	defer trace.UnregisterExporter(e)
+
+	ctx, parent := trace.StartSpan(context.Background(), "caller", trace.WithSampler(trace.AlwaysSample()))
+	tracer := opencensus.NewTracer()
+	_, span := tracer.Start(ctx, "gokv.Get")
+	span.SetAttribute("string", "foo")
+	span.SetAttribute("bool", true)
+	span.SetAttribute("int", 1)
+	span.SetAttribute("other", 1.5)
+	span.End(errors.New("foo"))
+	parent.End()
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	assert.Len(e.spans, 2)
+	child := e.spans[0]
+	assert.Equal("gokv.Get", child.Name)
+	assert.Equal(parent.SpanContext().SpanID, child.ParentSpanID)
+	assert.Equal(trace.SpanKindClient, child.SpanKind)
+	assert.Equal(map[string]interface{}{
+		"string": "foo",
+		"bool":   true,
+		"int":    int64(1),
+		"other":  "1.5",
+	}, child.Attributes)
+	assert.Equal("foo", child.Status.Message)
+}
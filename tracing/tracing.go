@@ -0,0 +1,154 @@
+package tracing
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash/fnv"
+	"strconv"
+
+	"github.com/SpeedyCoder/gokv"
+	"github.com/SpeedyCoder/gokv/internal/iterator"
+)
+
+// Attributes of the spans.
+const (
+	AttrBackend   = "gokv.backend"
+	AttrKeyHash   = "gokv.key_hash"
+	AttrValueSize = "gokv.value_size"
+	AttrFound     = "gokv.found"
+	AttrKeysCount = "gokv.keys_count"
+)
+
+// Tracer starts spans.
+// Implementations must be safe for concurrent use.
+type Tracer interface {
+	// Start starts a span with the given name as child of the span in ctx (if there's one)
+	// and returns a context that contains the new span.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a span of a Tracer.
+type Span interface {
+	// SetAttribute sets an attribute of the span.
+	// The value is a string, bool, int or int64.
+	SetAttribute(key string, value interface{})
+	// End ends the span. If err isn't nil the span is marked as failed.
+	End(err error)
+}
+
+// Options are the options for the tracing store.
+type Options struct {
+	// Name of the backend, which is added to all spans as AttrBackend.
+	// Optional ("default" by default).
+	Backend string
+	// KeyHashKey is the secret key for computing AttrKeyHash as HMAC-SHA-256 instead of FNV-64a.
+	// A plain hash of a key with little entropy, e.g. a user ID or an email address,
+	// can be reversed by hashing all candidates, so the key must be set to keep the keys out of traces.
+	// Optional (nil by default).
+	KeyHashKey []byte
+}
+
+// DefaultBackend is the default name of the backend of traced stores.
+const DefaultBackend = "default"
+
+// NewStore creates a new gokv.ContextStore that creates a span with the given tracer
+// for each operation of the given store.
+// The returned store only implements gokv.ContextStore,
+// so other interfaces that the wrapped store implements are hidden.
+func NewStore(store gokv.ContextStore, tracer Tracer, options *Options) gokv.ContextStore {
+	if options == nil {
+		options = &Options{}
+	}
+	// Set default values
+	backend := options.Backend
+	if backend == "" {
+		backend = DefaultBackend
+	}
+
+	return tracingStore{
+		store:      store,
+		tracer:     tracer,
+		backend:    backend,
+		keyHashKey: options.KeyHashKey,
+	}
+}
+
+type tracingStore struct {
+	store      gokv.ContextStore
+	tracer     Tracer
+	backend    string
+	keyHashKey []byte
+}
+
+// start starts a span for an operation and returns a context that contains it.
+// The context also contains a gokv.ValueSizeReporter that sets AttrValueSize of the span.
+func (s tracingStore) start(ctx context.Context, name string) (context.Context, Span) {
+	ctx, span := s.tracer.Start(ctx, name)
+	span.SetAttribute(AttrBackend, s.backend)
+	return gokv.WithValueSizeReporter(ctx, func(size int) {
+		span.SetAttribute(AttrValueSize, size)
+	}), span
+}
+
+// Set stores the given value for the given key.
+// The key must not be "" and the value must not be nil.
+func (s tracingStore) Set(ctx context.Context, k string, v interface{}) error {
+	ctx, span := s.start(ctx, "gokv.Set")
+	span.SetAttribute(AttrKeyHash, s.keyHash(k))
+	err := s.store.Set(ctx, k, v)
+	span.End(err)
+	return err
+}
+
+// Get retrieves the stored value for the given key.
+// If no value is found it returns (false, nil).
+// The key must not be "" and the pointer must not be nil.
+func (s tracingStore) Get(ctx context.Context, k string, v interface{}) (found bool, err error) {
+	ctx, span := s.start(ctx, "gokv.Get")
+	span.SetAttribute(AttrKeyHash, s.keyHash(k))
+	found, err = s.store.Get(ctx, k, v)
+	span.SetAttribute(AttrFound, found)
+	span.End(err)
+	return found, err
+}
+
+// Delete deletes the stored value for the given key.
+// Deleting a non-existing key-value pair does NOT lead to an error.
+// The key must not be "".
+func (s tracingStore) Delete(ctx context.Context, k string) error {
+	ctx, span := s.start(ctx, "gokv.Delete")
+	span.SetAttribute(AttrKeyHash, s.keyHash(k))
+	err := s.store.Delete(ctx, k)
+	span.End(err)
+	return err
+}
+
+// Keys returns an iterator over all keys in the store.
+// The span ends when the iteration is finished.
+func (s tracingStore) Keys(ctx context.Context) gokv.KeysIterator {
+	spanCtx, span := s.start(ctx, "gokv.Keys")
+	return iterator.Observe(ctx, s.store.Keys(spanCtx), func(count int, err error) {
+		span.SetAttribute(AttrKeysCount, count)
+		span.End(err)
+	})
+}
+
+// Close closes the wrapped store.
+func (s tracingStore) Close() error {
+	return s.store.Close()
+}
+
+// keyHash returns the hex representation of the HMAC-SHA-256 of a key if Options.KeyHashKey is set,
+// and of its FNV-64a hash otherwise.
+func (s tracingStore) keyHash(k string) string {
+	if s.keyHashKey == nil {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(k))
+		return strconv.FormatUint(h.Sum64(), 16)
+	}
+	mac := hmac.New(sha256.New, s.keyHashKey)
+	_, _ = mac.Write([]byte(k))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,105 @@
+package tracing_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/SpeedyCoder/gokv"
+	"github.com/SpeedyCoder/gokv/internal/ctxconv"
+	"github.com/SpeedyCoder/gokv/internal/test"
+	"github.com/SpeedyCoder/gokv/tracing"
+)
+
+// TestStore tests if reading from, writing to and deleting from the store works properly.
+func TestStore(t *testing.T) {
+	store, path := test.NewBboltStore(t, nil)
+	defer test.CleanUp(store, path)
+
+	test.Store(ctxconv.ToStore(tracing.NewStore(store, tracing.NewMemoryTracer(), nil)), t)
+}
+
+// TestSpans tests if a span with the expected attributes is created for each operation.
+func TestSpans(t *testing.T) {
+	assert := require.New(t)
+	inner, path := test.NewBboltStore(t, nil)
+	defer test.CleanUp(inner, path)
+	tracer := tracing.NewMemoryTracer()
+	store := tracing.NewStore(inner, tracer, &tracing.Options{Backend: "bbolt"})
+
+	ctx, parent := tracer.Start(context.Background(), "caller")
+	err := store.Set(ctx, "foo", test.Foo{Bar: "baz"})
+	assert.NoError(err)
+	_, err = store.Get(ctx, "foo", new(test.Foo))
+	assert.NoError(err)
+	_, err = store.Get(ctx, "bar", new(test.Foo))
+	assert.NoError(err)
+	err = store.Delete(ctx, "")
+	assert.Error(err)
+	it := store.Keys(ctx)
+	for range it.Ch() {
+	}
+	assert.NoError(it.Err())
+	parent.End(nil)
+
+	spans := tracer.Spans()
+	assert.Len(spans, 6)
+	parentSpan := spans[5]
+	assert.Equal("caller", parentSpan.Name)
+	for _, span := range spans[:5] {
+		assert.Equal(parentSpan.ID, span.ParentID)
+		assert.Equal("bbolt", span.Attributes[tracing.AttrBackend])
+	}
+
+	assert.Equal("gokv.Set", spans[0].Name)
+	assert.Equal(13, spans[0].Attributes[tracing.AttrValueSize])
+	assert.NotEmpty(spans[0].Attributes[tracing.AttrKeyHash])
+	assert.NotEqual("foo", spans[0].Attributes[tracing.AttrKeyHash])
+
+	assert.Equal("gokv.Get", spans[1].Name)
+	assert.Equal(spans[0].Attributes[tracing.AttrKeyHash], spans[1].Attributes[tracing.AttrKeyHash])
+	assert.Equal(true, spans[1].Attributes[tracing.AttrFound])
+	assert.Equal(13, spans[1].Attributes[tracing.AttrValueSize])
+
+	assert.Equal("gokv.Get", spans[2].Name)
+	assert.Equal(false, spans[2].Attributes[tracing.AttrFound])
+	assert.NotContains(spans[2].Attributes, tracing.AttrValueSize)
+
+	assert.Equal("gokv.Delete", spans[3].Name)
+	assert.Error(spans[3].Err)
+
+	assert.Equal("gokv.Keys", spans[4].Name)
+	assert.Equal(1, spans[4].Attributes[tracing.AttrKeysCount])
+
+	// Without traced operation reporting a value size does nothing
+	gokv.ReportValueSize(context.Background(), 1)
+	tracer.Reset()
+	assert.Empty(tracer.Spans())
+}
+
+// TestKeyHashKey tests if the key hash depends on the configured secret.
+func TestKeyHashKey(t *testing.T) {
+	assert := require.New(t)
+	inner, path := test.NewBboltStore(t, nil)
+	defer test.CleanUp(inner, path)
+	tracer := tracing.NewMemoryTracer()
+	ctx := context.Background()
+
+	for _, secret := range []string{"foo", "bar", ""} {
+		options := &tracing.Options{}
+		if secret != "" {
+			options.KeyHashKey = []byte(secret)
+		}
+		_, err := tracing.NewStore(inner, tracer, options).Get(ctx, "foo", new(test.Foo))
+		assert.NoError(err)
+	}
+
+	spans := tracer.Spans()
+	assert.Len(spans, 3)
+	hashes := make(map[interface{}]bool)
+	for _, span := range spans {
+		hashes[span.Attributes[tracing.AttrKeyHash]] = true
+	}
+	assert.Len(hashes, 3)
+}
@@ -0,0 +1,33 @@
+package gokv
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrTxConflict is returned by Transactional.Update when the transaction
+// still conflicts with concurrent transactions after all retries.
+var ErrTxConflict = errors.New("the transaction conflicted with concurrent transactions")
+
+// Transactional is a ContextStore that can run multiple operations atomically.
+type Transactional interface {
+	ContextStore
+	// Update runs fn in a read-write transaction.
+	// If fn returns nil the transaction is committed, otherwise it's rolled back
+	// and the error is returned.
+	// Implementations with optimistic concurrency control retry fn when the
+	// transaction conflicts with a concurrent one, so fn can be called multiple times
+	// and must not have side effects besides the calls to tx.
+	// If it still conflicts after all retries, ErrTxConflict is returned.
+	// The tx must not be used after fn returned.
+	Update(ctx context.Context, fn func(tx Tx) error) error
+}
+
+// Tx is a transaction of a Transactional store.
+// Its methods behave like the ones of Store, with the difference that
+// all changes are only visible outside the transaction after it was committed.
+type Tx interface {
+	Set(k string, v interface{}) error
+	Get(k string, v interface{}) (found bool, err error)
+	Delete(k string) error
+}
@@ -0,0 +1,9 @@
+/*
+Package upgrade contains a wrapper that writes values back to a `gokv.ContextStore`
+after they were upgraded to the current schema version on read.
+
+Upgrading values is done by the store's codec, which must be created with `encoding.NewVersioned()`.
+Writing the upgraded values back means the migrations only run once per value
+and the old schema versions can eventually be dropped.
+*/
+package upgrade
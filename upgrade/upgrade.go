@@ -0,0 +1,82 @@
+package upgrade
+
+import (
+	"context"
+
+	"github.com/SpeedyCoder/gokv"
+	"github.com/SpeedyCoder/gokv/encoding"
+	"github.com/SpeedyCoder/gokv/internal/check"
+)
+
+// Options are the options for the upgrading store.
+type Options struct {
+	// ErrorHandler is called for errors when writing back upgraded values.
+	// The value is still returned to the caller of Get in that case.
+	// Optional (errors are ignored by default).
+	ErrorHandler func(k string, err error)
+}
+
+// NewStore creates a new gokv.ContextStore that writes values back
+// after they were upgraded to the current schema version of their type.
+// The codec of the wrapped store must be an Encoding created with encoding.NewVersioned
+// and the same schemas.
+//
+// If the wrapped store implements gokv.ConditionalStore, values are only written back
+// if they weren't modified in the meantime, otherwise a concurrent write of the same key can be overwritten
+// with the upgraded old value.
+func NewStore(store gokv.ContextStore, schemas *encoding.Schemas, options *Options) gokv.ContextStore {
+	if options == nil {
+		options = &Options{}
+	}
+	// Set default values
+	errorHandler := options.ErrorHandler
+	if errorHandler == nil {
+		errorHandler = func(string, error) {}
+	}
+
+	return upgradeStore{
+		ContextStore: store,
+		schemas:      schemas,
+		errorHandler: errorHandler,
+	}
+}
+
+type upgradeStore struct {
+	gokv.ContextStore
+	schemas      *encoding.Schemas
+	errorHandler func(k string, err error)
+}
+
+// Get retrieves the stored value for the given key and upgrades it to the current schema version.
+// If the value had an older version, the upgraded value is written back.
+// The key must not be "" and the pointer must not be nil.
+func (s upgradeStore) Get(ctx context.Context, k string, v interface{}) (found bool, err error) {
+	if err := check.KeyAndValue(k, v); err != nil {
+		return false, err
+	}
+	current, ok := s.schemas.Version(v)
+	if !ok {
+		return s.ContextStore.Get(ctx, k, v)
+	}
+
+	versioned := &encoding.VersionedValue{Value: v}
+	if store, ok := s.ContextStore.(gokv.ConditionalStore); ok {
+		found, version, err := store.GetWithVersion(ctx, k, versioned)
+		if err != nil || !found || versioned.Version >= current {
+			return found, err
+		}
+		if _, err := store.CompareAndSwap(ctx, k, version, v); err != nil {
+			s.errorHandler(k, err)
+		}
+		return true, nil
+	}
+
+	found, err = s.ContextStore.Get(ctx, k, versioned)
+	if err != nil || !found || versioned.Version >= current {
+		return found, err
+	}
+	if err := s.ContextStore.Set(ctx, k, v); err != nil {
+		s.errorHandler(k, err)
+	}
+	return true, nil
+}
@@ -0,0 +1,110 @@
+package upgrade_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/SpeedyCoder/gokv"
+	"github.com/SpeedyCoder/gokv/backends/bbolt"
+	"github.com/SpeedyCoder/gokv/encoding"
+	"github.com/SpeedyCoder/gokv/internal/ctxconv"
+	"github.com/SpeedyCoder/gokv/internal/test"
+	"github.com/SpeedyCoder/gokv/upgrade"
+)
+
+type fooV1 struct {
+	Name string
+}
+
+// TestStore tests if reading from, writing to and deleting from the store works properly.
+func TestStore(t *testing.T) {
+	schemas := newSchemas(t)
+	store, path := createStore(t, schemas)
+	defer test.CleanUp(store, path)
+
+	test.Store(ctxconv.ToStore(upgrade.NewStore(store, schemas, nil)), t)
+}
+
+// TestWriteBack tests if upgraded values are written back,
+// with and without native gokv.ConditionalStore.
+func TestWriteBack(t *testing.T) {
+	schemas := newSchemas(t)
+	store, path := createStore(t, schemas)
+	defer test.CleanUp(store, path)
+
+	t.Run("native", func(t *testing.T) {
+		testWriteBack(t, store, schemas)
+	})
+	t.Run("fallback", func(t *testing.T) {
+		testWriteBack(t, ctxconv.ToContextStore(ctxconv.ToStore(store)), schemas)
+	})
+}
+
+func testWriteBack(t *testing.T, store gokv.ContextStore, schemas *encoding.Schemas) {
+	assert := require.New(t)
+	ctx := context.Background()
+	options := &upgrade.Options{
+		ErrorHandler: func(k string, err error) {
+			t.Errorf("Writing back %v failed: %v", k, err)
+		},
+	}
+	upgradeStore := upgrade.NewStore(store, schemas, options)
+
+	// Write a value of version 1, as it would have been written by an old version of the code.
+	// fooV1 isn't registered, so it's stored without version.
+	err := store.Set(ctx, "foo", fooV1{Name: "baz"})
+	assert.NoError(err)
+	versioned := &encoding.VersionedValue{Value: new(test.Foo)}
+	_, err = store.Get(ctx, "foo", versioned)
+	assert.NoError(err)
+	assert.Equal(uint64(1), versioned.Version)
+
+	// Reading upgrades the value and writes it back
+	actual := new(test.Foo)
+	found, err := upgradeStore.Get(ctx, "foo", actual)
+	assert.NoError(err)
+	assert.True(found)
+	assert.Equal(test.Foo{Bar: "baz"}, *actual)
+
+	versioned = &encoding.VersionedValue{Value: new(test.Foo)}
+	found, err = store.Get(ctx, "foo", versioned)
+	assert.NoError(err)
+	assert.True(found)
+	assert.Equal(uint64(2), versioned.Version)
+	assert.Equal(test.Foo{Bar: "baz"}, *versioned.Value.(*test.Foo))
+
+	// Non-existing values
+	found, err = upgradeStore.Get(ctx, "bar", new(test.Foo))
+	assert.NoError(err)
+	assert.False(found)
+}
+
+func newSchemas(t *testing.T) *encoding.Schemas {
+	schemas := encoding.NewSchemas()
+	err := schemas.Register(test.Foo{}, 2, encoding.Migration{
+		From: 1,
+		Old:  fooV1{},
+		Upgrade: func(old interface{}) (interface{}, error) {
+			return test.Foo{Bar: old.(fooV1).Name}, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return schemas
+}
+
+func createStore(t *testing.T, schemas *encoding.Schemas) (gokv.ContextStore, string) {
+	codec, err := encoding.NewVersioned(encoding.VersionedOptions{
+		Schemas: schemas,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return test.NewBboltStore(t, &bbolt.Options{
+		Encoding: codec,
+	})
+}
@@ -0,0 +1,31 @@
+package gokv
+
+import "context"
+
+// ValueSizeReporter is called with the size of the marshalled value of a store operation.
+// Only the backend knows the size, so wrappers that record it, like the ones in the packages
+// `instrumented` and `tracing`, add a reporter to the context of the operation with WithValueSizeReporter,
+// and backends report the size with ReportValueSize.
+type ValueSizeReporter func(size int)
+
+type valueSizeReportersKey struct{}
+
+// WithValueSizeReporter returns a context that contains the given reporter
+// in addition to the reporters that ctx already contains,
+// so wrappers of wrappers get the size reported as well.
+func WithValueSizeReporter(ctx context.Context, reporter ValueSizeReporter) context.Context {
+	reporters, _ := ctx.Value(valueSizeReportersKey{}).([]ValueSizeReporter)
+	// Limiting the capacity makes append copy the slice, so contexts derived from the same context don't share reporters.
+	reporters = append(reporters[:len(reporters):len(reporters)], reporter)
+	return context.WithValue(ctx, valueSizeReportersKey{}, reporters)
+}
+
+// ReportValueSize calls the reporters in ctx with the size of the marshalled value
+// that the backend stores in Set or found in Get.
+// It does nothing if ctx doesn't contain any reporters.
+func ReportValueSize(ctx context.Context, size int) {
+	reporters, _ := ctx.Value(valueSizeReportersKey{}).([]ValueSizeReporter)
+	for _, reporter := range reporters {
+		reporter(size)
+	}
+}
@@ -0,0 +1,38 @@
+package gokv
+
+import "context"
+
+// Watcher is a ContextStore that notifies about changes of its key-value pairs.
+// For stores that can't do this natively the watch package provides an in-process wrapper.
+type Watcher interface {
+	ContextStore
+	// Watch returns a channel to which an event is written for every change
+	// of a key-value pair whose key starts with the given prefix.
+	// An empty prefix matches all keys.
+	// Only changes that happen after the call are reported.
+	// Deleting a non-existing key-value pair doesn't change anything, so it isn't reported.
+	// The channel is closed when ctx is done or the store is closed.
+	Watch(ctx context.Context, prefix string) <-chan Event
+}
+
+// EventType is the type of change that an Event reports.
+type EventType int
+
+const (
+	// EventPut reports that a value was stored for a key.
+	EventPut EventType = iota + 1
+	// EventDelete reports that a key-value pair was deleted.
+	EventDelete
+)
+
+// Event reports a change of a key-value pair.
+type Event struct {
+	Type EventType
+	Key  string
+	// Value is the new value as it's stored, so it's still marshalled.
+	// It's nil for EventDelete and for values that were stored with StreamStore.Put.
+	Value []byte
+	// Revision increases with every change in the store.
+	// What the revision is exactly depends on the implementation.
+	Revision uint64
+}
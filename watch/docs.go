@@ -0,0 +1,8 @@
+/*
+Package watch contains a wrapper that adds in-process change notifications to any `gokv.ContextStore`.
+
+Every Set and Delete that's made via the wrapper is published to all watchers with a matching prefix.
+Changes made by other processes or via other instances aren't reported,
+so it's mostly useful for in-memory and embedded stores, for example in tests.
+*/
+package watch
@@ -0,0 +1,156 @@
+package watch
+
+import (
+	"context"
+	"sync"
+
+	"github.com/SpeedyCoder/gokv"
+	"github.com/SpeedyCoder/gokv/encoding"
+	"github.com/SpeedyCoder/gokv/internal/check"
+	"github.com/SpeedyCoder/gokv/internal/pubsub"
+)
+
+// Options are the options for the watch store.
+type Options struct {
+	// Encoding format of the values in the events.
+	// It should be the same as the one of the wrapped store.
+	// Optional (encoding.JSON by default).
+	Encoding encoding.Encoding
+}
+
+const (
+	DefaultEncoding = encoding.JSON
+)
+
+// NewStore creates a new gokv.Watcher.
+// If the given store already implements gokv.Watcher, it's returned unchanged.
+// Otherwise all writes via the returned store are serialized, so that events
+// are published in the same order in which the changes were made.
+func NewStore(store gokv.ContextStore, options *Options) gokv.Watcher {
+	if s, ok := store.(gokv.Watcher); ok {
+		return s
+	}
+
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+
+	// Set default values
+	if opts.Encoding == nil {
+		opts.Encoding = DefaultEncoding
+	}
+
+	return &watchStore{
+		store: store,
+		codec: opts.Encoding,
+		hub:   pubsub.NewHub(),
+	}
+}
+
+type watchStore struct {
+	store gokv.ContextStore
+	codec encoding.Encoding
+	hub   *pubsub.Hub
+	// writeLock serializes writes and protects revision.
+	writeLock sync.Mutex
+	revision  uint64
+}
+
+// Set stores the given value for the given key and publishes the change.
+// The key must not be "" and the value must not be nil.
+func (s *watchStore) Set(ctx context.Context, k string, v interface{}) error {
+	if err := check.KeyAndValue(k, v); err != nil {
+		return err
+	}
+
+	data, err := s.codec.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+	if err := s.store.Set(ctx, k, v); err != nil {
+		return err
+	}
+	s.revision++
+	s.hub.Publish(gokv.Event{
+		Type:     gokv.EventPut,
+		Key:      k,
+		Value:    data,
+		Revision: s.revision,
+	})
+	return nil
+}
+
+// Get retrieves the stored value for the given key.
+// The key must not be "" and the pointer must not be nil.
+func (s *watchStore) Get(ctx context.Context, k string, v interface{}) (found bool, err error) {
+	return s.store.Get(ctx, k, v)
+}
+
+// Delete deletes the stored value for the given key and publishes the change.
+// Like with native implementations of gokv.Watcher,
+// deleting a non-existing key-value pair isn't published.
+// The key must not be "".
+func (s *watchStore) Delete(ctx context.Context, k string) error {
+	if err := check.Key(k); err != nil {
+		return err
+	}
+
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+	found, err := s.exists(ctx, k)
+	if err != nil {
+		return err
+	}
+	if err := s.store.Delete(ctx, k); err != nil {
+		return err
+	}
+	if !found {
+		return nil
+	}
+	s.revision++
+	s.hub.Publish(gokv.Event{
+		Type:     gokv.EventDelete,
+		Key:      k,
+		Revision: s.revision,
+	})
+	return nil
+}
+
+// exists reports whether a value is stored for the given key.
+// The type of the value is unknown, so it's read as raw bytes if the wrapped store implements gokv.RawStore.
+// Otherwise it's decoded into an interface{}, which might fail, e.g. with gob.
+// Stores return found == true together with errors of decoding a value that was found,
+// so only these errors are ignored and the other errors, e.g. of the connection, are returned.
+func (s *watchStore) exists(ctx context.Context, k string) (bool, error) {
+	if rawStore, ok := s.store.(gokv.RawStore); ok {
+		_, found, err := rawStore.GetBytes(ctx, k)
+		return found, err
+	}
+	found, err := s.store.Get(ctx, k, new(interface{}))
+	if found {
+		return true, nil
+	}
+	return false, err
+}
+
+// Keys returns an iterator over all keys of the wrapped store.
+func (s *watchStore) Keys(ctx context.Context) gokv.KeysIterator {
+	return s.store.Keys(ctx)
+}
+
+// Watch returns a channel to which an event is written for every change
+// of a key-value pair whose key starts with the given prefix.
+// The channel is closed when ctx is done or the store is closed.
+func (s *watchStore) Watch(ctx context.Context, prefix string) <-chan gokv.Event {
+	return s.hub.Subscribe(ctx, prefix)
+}
+
+// Close closes all watch channels and the wrapped store.
+func (s *watchStore) Close() error {
+	s.hub.Close()
+	return s.store.Close()
+}
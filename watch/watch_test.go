@@ -0,0 +1,90 @@
+package watch_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/SpeedyCoder/gokv"
+	"github.com/SpeedyCoder/gokv/internal/ctxconv"
+	"github.com/SpeedyCoder/gokv/internal/test"
+	"github.com/SpeedyCoder/gokv/watch"
+)
+
+// TestStore tests if reading from, writing to and deleting from the store works properly.
+func TestStore(t *testing.T) {
+	store, path := createStore(t)
+	defer test.CleanUp(store, path)
+	test.Store(ctxconv.ToStore(store), t)
+}
+
+// TestStoreConcurrent launches a bunch of goroutines that concurrently work with one store.
+// All writes are serialized by the wrapper, so testing this is important.
+func TestStoreConcurrent(t *testing.T) {
+	store, path := createStore(t)
+	defer test.CleanUp(store, path)
+
+	goroutineCount := 1000
+
+	test.ConcurrentInteractions(t, goroutineCount, ctxconv.ToStore(store))
+}
+
+// TestWatch tests if changes are published to watchers.
+func TestWatch(t *testing.T) {
+	store, path := createStore(t)
+	defer test.CleanUp(store, path)
+	test.Watcher(store, t)
+}
+
+// TestNative tests if a store that already implements gokv.Watcher is returned as is.
+func TestNative(t *testing.T) {
+	store, path := createStore(t)
+	defer test.CleanUp(store, path)
+	if watch.NewStore(store, nil) != store {
+		t.Error("Expected the store to be returned unchanged")
+	}
+}
+
+// TestDeleteErrors tests if values that can't be decoded are deleted and the deletion is published,
+// while other errors of checking whether the value exists are returned.
+func TestDeleteErrors(t *testing.T) {
+	assert := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	inner, path := test.NewBboltStore(t, nil)
+	failing := &failingStore{ContextStore: ctxconv.ToContextStore(ctxconv.ToStore(inner))}
+	store := watch.NewStore(failing, nil)
+	defer test.CleanUp(store, path)
+	events := store.Watch(ctx, "")
+
+	assert.NoError(store.Set(ctx, "foo", "bar"))
+	<-events
+	failing.found, failing.err = false, errors.New("connection refused")
+	assert.Equal(failing.err, store.Delete(ctx, "foo"))
+	failing.found, failing.err = true, errors.New("can't decode the value")
+	assert.NoError(store.Delete(ctx, "foo"))
+	event := <-events
+	assert.Equal(gokv.EventDelete, event.Type)
+	assert.Equal("foo", event.Key)
+}
+
+// failingStore returns the configured result from Get.
+type failingStore struct {
+	gokv.ContextStore
+	found bool
+	err   error
+}
+
+func (s *failingStore) Get(ctx context.Context, k string, v interface{}) (bool, error) {
+	return s.found, s.err
+}
+
+// createStore creates a store that doesn't implement gokv.Watcher natively.
+func createStore(t *testing.T) (gokv.Watcher, string) {
+	inner, path := test.NewBboltStore(t, nil)
+	// The bbolt store implements gokv.Watcher, so hide its Watch method.
+	inner = ctxconv.ToContextStore(ctxconv.ToStore(inner))
+	return watch.NewStore(inner, nil), path
+}